@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gcmd"
+	"github.com/gogf/gf/v2/os/gfile"
+	"os"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"strconv"
+	"strings"
+)
+
+var (
+	Backtest = gcmd.Command{
+		Name:  "backtest",
+		Usage: "backtest -file=journal.log -prices=prices.log -users=binance:100:1,gate:50:2",
+		Brief: "simulate copy-trading sizing for users against a recorded journal",
+		Arguments: []gcmd.Argument{
+			{Name: "file", Short: "f", Brief: "journal file"},
+			{Name: "prices", Brief: "mark price file, one json {time,symbol,price} per line"},
+			{Name: "symbols", Brief: "symbol file, json array of {symbol,quantityPrecision,quantoMultiplier}"},
+			{Name: "users", Brief: "users as plat:money:num, comma separated"},
+			{Name: "traderMoney", Brief: "trader margin, default 10000"},
+			{Name: "fee", Brief: "taker fee rate, default 0.0005"},
+			{Name: "precision", Brief: "quantity precision for unknown symbols, default 3"},
+			{Name: "quanto", Brief: "gate quanto multiplier for unknown symbols, default 0.001"},
+		},
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			req := &entity.BacktestReq{
+				Journal:           parser.GetOpt("file").String(),
+				Prices:            parser.GetOpt("prices").String(),
+				TraderMoney:       parser.GetOpt("traderMoney", 10000).Float64(),
+				FeeRate:           parser.GetOpt("fee", 0.0005).Float64(),
+				QuantityPrecision: parser.GetOpt("precision", 3).Int(),
+				QuantoMultiplier:  parser.GetOpt("quanto", 0.001).Float64(),
+			}
+			if 0 >= len(req.Journal) {
+				return gerror.New("缺少参数file")
+			}
+
+			req.Users, err = parseBacktestUsers(parser.GetOpt("users").String())
+			if nil != err {
+				return err
+			}
+
+			if symbols := parser.GetOpt("symbols").String(); 0 < len(symbols) {
+				err = json.Unmarshal(gfile.GetBytes(symbols), &req.Symbols)
+				if nil != err {
+					return gerror.Newf("交易对文件解析错误：%v", err)
+				}
+			}
+
+			res, err := service.ListenAndOrder().Backtest(ctx, req)
+			if nil != err {
+				return err
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetEscapeHTML(false)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(res)
+		},
+	}
+)
+
+// parseBacktestUsers 解析plat:money:num，逗号分隔
+func parseBacktestUsers(value string) ([]*entity.BacktestUser, error) {
+	res := make([]*entity.BacktestUser, 0)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if 0 >= len(v) {
+			continue
+		}
+
+		tmp := strings.Split(v, ":")
+		if 3 != len(tmp) {
+			return nil, gerror.Newf("用户格式错误，应为plat:money:num：%s", v)
+		}
+
+		money, err := strconv.ParseFloat(tmp[1], 64)
+		if nil != err {
+			return nil, gerror.Newf("用户保证金错误：%s", v)
+		}
+
+		num, err := strconv.ParseFloat(tmp[2], 64)
+		if nil != err {
+			return nil, gerror.Newf("用户系数错误：%s", v)
+		}
+
+		res = append(res, &entity.BacktestUser{
+			Plat:  tmp[0],
+			Money: money,
+			Num:   num,
+		})
+	}
+
+	if 0 >= len(res) {
+		return nil, gerror.New("缺少参数users")
+	}
+
+	return res, nil
+}
+
+func init() {
+	err := Main.AddCommand(&Backtest)
+	if nil != err {
+		panic(err)
+	}
+}
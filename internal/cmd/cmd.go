@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gogf/gf/v2/os/gcmd"
+	"plat_order/internal/model/entity"
 	"plat_order/internal/service"
 )
 
@@ -21,13 +22,24 @@ var (
 		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
 			lao := service.ListenAndOrder()
 
+			// 同步交易对和交易规则，需在加载币种信息前
+			err = lao.SyncSymbols(ctx)
+			if nil != err {
+				log.Println("启动错误，同步交易对：", err)
+			}
+
 			err = lao.SetSymbol(ctx)
 			if nil != err {
 				log.Println("启动错误，币种信息：", err)
 			}
 
-			// 300秒/次，币种信息
+			// 300秒/次，同步交易对和交易规则，更新币种信息
 			handle := func(ctx context.Context) {
+				err = lao.SyncSymbols(ctx)
+				if nil != err {
+					log.Println("任务错误，同步交易对：", err)
+				}
+
 				err = lao.SetSymbol(ctx)
 				if nil != err {
 					log.Println("任务错误，币种信息：", err)
@@ -35,6 +47,47 @@ var (
 			}
 			gtimer.AddSingleton(ctx, time.Minute*5, handle)
 
+			// 1小时/次，清理已执行和过期的队列信号
+			gtimer.AddSingleton(ctx, time.Hour, func(ctx context.Context) {
+				service.OrderQueue().CleanQueue(ctx)
+			})
+
+			// 用户仓位，需在用户开始跟单前恢复
+			err = lao.LoadOrderMap(ctx)
+			if nil != err {
+				log.Println("启动错误，用户仓位：", err)
+				return err
+			}
+
+			// 推送去重，需在开启监听前恢复
+			lao.InitSignalDedup(ctx)
+
+			// 信号日志
+			err = lao.InitJournal(ctx)
+			if nil != err {
+				log.Println("启动错误，信号日志：", err)
+				return err
+			}
+
+			// 模拟盘，需在加载用户前恢复模拟账户
+			lao.InitPaper(ctx)
+
+			// 交易员，新增的开启监听，api校验失败直接退出
+			err = lao.SetTraders(ctx)
+			if nil != err {
+				log.Println("启动错误，交易员：", err)
+				return err
+			}
+
+			// 30秒/次，更新交易员信息
+			handleTraders := func(ctx context.Context) {
+				err = lao.SetTraders(ctx)
+				if nil != err {
+					log.Println("任务错误，交易员：", err)
+				}
+			}
+			gtimer.AddSingleton(ctx, time.Second*30, handleTraders)
+
 			err = lao.PullAndSetTraderUserPositionSide(ctx)
 			if nil != err {
 				log.Println("启动错误，同步交易员和用户持仓方向：", err)
@@ -65,15 +118,24 @@ var (
 			}
 			gtimer.AddSingleton(ctx, time.Second*30, handle4)
 
+			// 1分钟/次，对比交易员接口仓位
+			handleReconcile := func(ctx context.Context) {
+				lao.ReconcileTraders(ctx)
+			}
+			gtimer.AddSingleton(ctx, time.Minute*1, handleReconcile)
+
+			// 5分钟/次，对比用户交易所仓位
+			handleReconcileUsers := func(ctx context.Context) {
+				lao.ReconcileUsers(ctx)
+			}
+			gtimer.AddSingleton(ctx, time.Minute*5, handleReconcileUsers)
+
 			//// 5分钟/次，更新用户信息 todo
 			//handle5 := func(ctx context.Context) {
 			//	lao.HandleBothPositions(ctx)
 			//}
 			//gtimer.AddSingleton(ctx, time.Minute*5, handle5)
 
-			// 启动
-			go lao.Run(ctx)
-
 			// 开启http管理服务
 			s := g.Server()
 			s.Group("/api", func(group *ghttp.RouterGroup) {
@@ -144,6 +206,7 @@ var (
 						parseErr error
 						setErr   error
 						needInit uint64
+						paper    uint64
 						num      float64
 					)
 					needInit, parseErr = strconv.ParseUint(r.PostFormValue("need_init"), 10, 64)
@@ -164,6 +227,18 @@ var (
 						return
 					}
 
+					// 模拟盘，可选
+					if 0 < len(r.PostFormValue("paper")) {
+						paper, parseErr = strconv.ParseUint(r.PostFormValue("paper"), 10, 64)
+						if nil != parseErr {
+							r.Response.WriteJson(g.Map{
+								"code": -1,
+							})
+
+							return
+						}
+					}
+
 					setErr = lao.CreateUser(
 						ctx,
 						r.PostFormValue("address"),
@@ -172,6 +247,7 @@ var (
 						"binance",
 						needInit,
 						num,
+						paper,
 					)
 					if nil != setErr {
 						r.Response.WriteJson(g.Map{
@@ -280,6 +356,24 @@ var (
 					return
 				})
 
+				// 查询模拟盘用户资金和盈亏
+				group.GET("/user/paper", func(r *ghttp.Request) {
+					res, getErr := lao.GetPaperAccount(ctx, r.Get("apiKey").String())
+					if nil != getErr || nil == res {
+						r.Response.WriteJson(g.Map{
+							"code": -1,
+						})
+
+						return
+					}
+
+					r.Response.WriteJson(g.Map{
+						"code": 1,
+						"data": res,
+					})
+					return
+				})
+
 				// 用户全平仓位
 				group.POST("/user/close/positions", func(r *ghttp.Request) {
 					r.Response.WriteJson(g.Map{
@@ -289,6 +383,189 @@ var (
 					return
 				})
 
+				// webhook信号
+				group.POST("/webhook", func(r *ghttp.Request) {
+					var alert *entity.WebhookAlert
+					parseErr := r.Parse(&alert)
+					if nil != parseErr || nil == alert {
+						r.Response.WriteJson(g.Map{
+							"code": -1,
+						})
+
+						return
+					}
+
+					if 0 >= len(alert.Token) {
+						alert.Token = r.GetHeader("X-Webhook-Token")
+					}
+
+					// 错误详情只记录日志，不返回给请求方
+					handleErr := lao.HandleWebhook(ctx, alert)
+					if nil != handleErr {
+						log.Println("webhook信号处理失败：", alert.TraderId, alert.Symbol, alert.Side, alert.Action, handleErr)
+						r.Response.WriteJson(g.Map{
+							"code": -2,
+							"msg":  "信号处理失败",
+						})
+
+						return
+					}
+
+					r.Response.WriteJson(g.Map{
+						"code": 1,
+					})
+
+					return
+				})
+
+				// 查询交易员仓位差异
+				group.GET("/trader/mismatches", func(r *ghttp.Request) {
+					r.Response.WriteJson(lao.GetTraderMismatches(ctx))
+					return
+				})
+
+				// 管理接口，需管理token
+				group.Group("/", func(group *ghttp.RouterGroup) {
+					group.Middleware(middlewareAdminAuth)
+
+					// 查询币安请求权重和下单数用量
+					group.GET("/binance/limits", func(r *ghttp.Request) {
+						r.Response.WriteJson(service.Binance().GetRateLimits())
+						return
+					})
+
+					// 查询用户下单失败统计
+					group.GET("/order/failures", func(r *ghttp.Request) {
+						r.Response.WriteJson(lao.GetOrderFailures(ctx))
+						return
+					})
+
+					// 查询用户仓位差异
+					group.GET("/user/mismatches", func(r *ghttp.Request) {
+						r.Response.WriteJson(lao.GetUserMismatches(ctx))
+						return
+					})
+
+					// 校正用户仓位，mode：report只报告，map校正系统仓位，order下单补齐交易所仓位
+					group.POST("/user/reconcile", func(r *ghttp.Request) {
+						mode := r.PostFormValue("mode")
+						if 0 >= len(mode) {
+							mode = "report"
+						}
+
+						res, reconcileErr := lao.ReconcileUser(ctx, r.PostFormValue("apiKey"), mode)
+						if nil != reconcileErr {
+							r.Response.WriteJson(g.Map{
+								"code": -1,
+								"msg":  reconcileErr.Error(),
+							})
+
+							return
+						}
+
+						r.Response.WriteJson(g.Map{
+							"code": 1,
+							"data": res,
+						})
+
+						return
+					})
+
+					// 更换交易员api
+					group.POST("/trader/rotate", func(r *ghttp.Request) {
+						var (
+							parseErr error
+							setErr   error
+							traderId uint64
+						)
+						traderId, parseErr = strconv.ParseUint(r.PostFormValue("traderId"), 10, 64)
+						if nil != parseErr || 0 >= traderId {
+							r.Response.WriteJson(g.Map{
+								"code": -1,
+							})
+
+							return
+						}
+
+						if 0 >= len(r.PostFormValue("api_key")) || 0 >= len(r.PostFormValue("api_secret")) {
+							r.Response.WriteJson(g.Map{
+								"code": -1,
+							})
+
+							return
+						}
+
+						setErr = lao.RotateTraderKey(ctx, traderId, r.PostFormValue("api_key"), r.PostFormValue("api_secret"))
+						if nil != setErr {
+							log.Println("更换交易员api失败：", traderId, setErr)
+							r.Response.WriteJson(g.Map{
+								"code": -2,
+								"msg":  "更换交易员api失败",
+							})
+
+							return
+						}
+
+						r.Response.WriteJson(g.Map{
+							"code": 1,
+						})
+
+						return
+					})
+				})
+
+				// 用户跟单交易员
+				group.POST("/user/trader", func(r *ghttp.Request) {
+					var (
+						parseErr error
+						setErr   error
+						traderId uint64
+						status   uint64
+						num      float64
+					)
+					traderId, parseErr = strconv.ParseUint(r.PostFormValue("traderId"), 10, 64)
+					if nil != parseErr || 0 >= traderId {
+						r.Response.WriteJson(g.Map{
+							"code": -1,
+						})
+
+						return
+					}
+
+					status, parseErr = strconv.ParseUint(r.PostFormValue("status"), 10, 64)
+					if nil != parseErr || 0 >= status {
+						r.Response.WriteJson(g.Map{
+							"code": -1,
+						})
+
+						return
+					}
+
+					num, parseErr = strconv.ParseFloat(r.PostFormValue("num"), 64)
+					if nil != parseErr || 0 >= num {
+						r.Response.WriteJson(g.Map{
+							"code": -1,
+						})
+
+						return
+					}
+
+					setErr = lao.SetUserTrader(ctx, r.PostFormValue("apiKey"), traderId, num, status)
+					if nil != setErr {
+						r.Response.WriteJson(g.Map{
+							"code": -2,
+						})
+
+						return
+					}
+
+					r.Response.WriteJson(g.Map{
+						"code": 1,
+					})
+
+					return
+				})
+
 				// 用户设置仓位
 				group.POST("/user/update/position", func(r *ghttp.Request) {
 					var (
@@ -296,6 +573,7 @@ var (
 						num          float64
 						system       uint64
 						allCloseGate uint64
+						traderId     uint64
 					)
 					num, parseErr = strconv.ParseFloat(r.PostFormValue("num"), 64)
 					if nil != parseErr || 0 >= num {
@@ -324,6 +602,18 @@ var (
 						return
 					}
 
+					// 可选，不传时用户只跟单一个交易员
+					if 0 < len(r.PostFormValue("traderId")) {
+						traderId, parseErr = strconv.ParseUint(r.PostFormValue("traderId"), 10, 64)
+						if nil != parseErr {
+							r.Response.WriteJson(g.Map{
+								"code": -1,
+							})
+
+							return
+						}
+					}
+
 					r.Response.WriteJson(g.Map{
 						"code": lao.SetSystemUserPosition(
 							ctx,
@@ -334,6 +624,7 @@ var (
 							r.PostFormValue("side"),
 							r.PostFormValue("positionSide"),
 							num,
+							traderId,
 						),
 					})
 
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"net/http"
+)
+
+// middlewareAdminAuth 管理接口校验请求头X-Admin-Token与配置admin.token一致，未配置token时拒绝访问
+func middlewareAdminAuth(r *ghttp.Request) {
+	token := g.Cfg().MustGet(r.Context(), "admin.token").String()
+	if 0 >= len(token) || 1 != subtle.ConstantTimeCompare([]byte(token), []byte(r.GetHeader("X-Admin-Token"))) {
+		r.Response.WriteHeader(http.StatusUnauthorized)
+		r.Response.WriteJsonExit(g.Map{
+			"code": -401,
+		})
+	}
+
+	r.Middleware.Next()
+}
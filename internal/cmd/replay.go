@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gcmd"
+	"os"
+	"plat_order/internal/service"
+)
+
+var (
+	Replay = gcmd.Command{
+		Name:  "replay",
+		Usage: "replay -file=journal.log -traderMoney=10000 -userMoney=1000",
+		Brief: "replay a signal journal against a simulated exchange",
+		Arguments: []gcmd.Argument{
+			{Name: "file", Short: "f", Brief: "journal file"},
+			{Name: "traderMoney", Brief: "trader margin, default 10000"},
+			{Name: "userMoney", Brief: "user margin, default 1000"},
+			{Name: "precision", Brief: "quantity precision for unknown symbols, default 3"},
+		},
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			file := parser.GetOpt("file").String()
+			if 0 >= len(file) {
+				return gerror.New("缺少参数file")
+			}
+
+			res, err := service.ListenAndOrder().Replay(
+				ctx,
+				file,
+				parser.GetOpt("traderMoney", 10000).Float64(),
+				parser.GetOpt("userMoney", 1000).Float64(),
+				parser.GetOpt("precision", 3).Int(),
+			)
+			if nil != err {
+				return err
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetEscapeHTML(false)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(res)
+		},
+	}
+)
+
+func init() {
+	err := Main.AddCommand(&Replay)
+	if nil != err {
+		panic(err)
+	}
+}
@@ -0,0 +1,51 @@
+package consts
+
+// 币安下单错误分类，跟单按分类决定是否重试及如何重试
+const (
+	BinanceErrUnknown      = "UNKNOWN"       // 未归类
+	BinanceErrNetwork      = "NETWORK"       // 请求未得到响应，订单状态未知
+	BinanceErrServer       = "SERVER"        // 服务端超时或内部错误，订单状态未知
+	BinanceErrTimestamp    = "TIMESTAMP"     // 时间戳超出接收窗口
+	BinanceErrRateLimit    = "RATE_LIMIT"    // 请求或下单频率超限
+	BinanceErrAuth         = "AUTH"          // api key无效、无权限或签名错误
+	BinanceErrSymbol       = "SYMBOL"        // 交易对无效或不可交易
+	BinanceErrPrecision    = "PRECISION"     // 数量精度超过步长
+	BinanceErrQuantity     = "QUANTITY"      // 数量或名义价值不满足交易规则
+	BinanceErrMargin       = "MARGIN"        // 保证金不足或超过当前杠杆最大仓位
+	BinanceErrReduceOnly   = "REDUCE_ONLY"   // 只减仓单被拒，交易所仓位不足
+	BinanceErrPositionSide = "POSITION_SIDE" // 订单持仓方向与账户持仓模式不符
+	BinanceErrNotFound     = "NOT_FOUND"     // 订单不存在
+	BinanceErrDuplicate    = "DUPLICATE"     // clientOrderId重复，订单已存在
+)
+
+// BinanceErrorClasses 币安错误码对应的分类，未列出的为BinanceErrUnknown
+var BinanceErrorClasses = map[int64]string{
+	-1001: BinanceErrServer,
+	-1007: BinanceErrServer,
+	-1021: BinanceErrTimestamp,
+	-1003: BinanceErrRateLimit,
+	-1008: BinanceErrRateLimit,
+	-1015: BinanceErrRateLimit,
+	-1002: BinanceErrAuth,
+	-1022: BinanceErrAuth,
+	-2014: BinanceErrAuth,
+	-2015: BinanceErrAuth,
+	-1121: BinanceErrSymbol,
+	-4140: BinanceErrSymbol,
+	-1111: BinanceErrPrecision,
+	-1013: BinanceErrQuantity,
+	-4003: BinanceErrQuantity,
+	-4004: BinanceErrQuantity,
+	-4005: BinanceErrQuantity,
+	-4164: BinanceErrQuantity,
+	-2018: BinanceErrMargin,
+	-2019: BinanceErrMargin,
+	-2027: BinanceErrMargin,
+	-2028: BinanceErrMargin,
+	-2022: BinanceErrReduceOnly,
+	-4118: BinanceErrReduceOnly,
+	-4061: BinanceErrPositionSide,
+	-2011: BinanceErrNotFound,
+	-2013: BinanceErrNotFound,
+	-4116: BinanceErrDuplicate,
+}
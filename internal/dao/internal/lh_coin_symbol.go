@@ -34,6 +34,7 @@ type LhCoinSymbolColumns struct {
 	VolumePlace       string //
 	SizeMultiplier    string //
 	QuantoMultiplier  string //
+	Status            string // 交易所状态，同步写入，空为手动维护
 }
 
 // lhCoinSymbolColumns holds the columns for table lh_coin_symbol.
@@ -52,6 +53,7 @@ var lhCoinSymbolColumns = LhCoinSymbolColumns{
 	VolumePlace:       "volume_place",
 	SizeMultiplier:    "size_multiplier",
 	QuantoMultiplier:  "quanto_multiplier",
+	Status:            "status",
 }
 
 // NewLhCoinSymbolDao creates and returns a new DAO object for table data access.
@@ -33,6 +33,7 @@ type UserColumns struct {
 	Plat       string //
 	Dai        string //
 	Ip         string //
+	Paper      string // 模拟盘：1
 }
 
 // userColumns holds the columns for table user.
@@ -50,6 +51,7 @@ var userColumns = UserColumns{
 	Plat:       "plat",
 	Dai:        "dai",
 	Ip:         "ip",
+	Paper:      "paper",
 }
 
 // NewUserDao creates and returns a new DAO object for table data access.
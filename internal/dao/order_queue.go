@@ -0,0 +1,27 @@
+// =================================================================================
+// This is auto-generated by GoFrame CLI tool only once. Fill this file as you wish.
+// =================================================================================
+
+package dao
+
+import (
+	"plat_order/internal/dao/internal"
+)
+
+// internalOrderQueueDao is internal type for wrapping internal DAO implements.
+type internalOrderQueueDao = *internal.OrderQueueDao
+
+// orderQueueDao is the data access object for table order_queue.
+// You can define custom methods on it to extend its functionality as you wish.
+type orderQueueDao struct {
+	internalOrderQueueDao
+}
+
+var (
+	// OrderQueue is globally public accessible object for table order_queue operations.
+	OrderQueue = orderQueueDao{
+		internal.NewOrderQueueDao(),
+	}
+)
+
+// Fill with you ideas below.
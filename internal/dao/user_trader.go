@@ -0,0 +1,27 @@
+// =================================================================================
+// This is auto-generated by GoFrame CLI tool only once. Fill this file as you wish.
+// =================================================================================
+
+package dao
+
+import (
+	"plat_order/internal/dao/internal"
+)
+
+// internalUserTraderDao is internal type for wrapping internal DAO implements.
+type internalUserTraderDao = *internal.UserTraderDao
+
+// userTraderDao is the data access object for table user_trader.
+// You can define custom methods on it to extend its functionality as you wish.
+type userTraderDao struct {
+	internalUserTraderDao
+}
+
+var (
+	// UserTrader is globally public accessible object for table user_trader operations.
+	UserTrader = userTraderDao{
+		internal.NewUserTraderDao(),
+	}
+)
+
+// Fill with you ideas below.
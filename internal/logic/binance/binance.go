@@ -5,64 +5,69 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"github.com/gogf/gf/v2/container/gvar"
+	"github.com/gogf/gf/v2/container/gtype"
 	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gorilla/websocket"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"plat_order/internal/model/entity"
 	"plat_order/internal/service"
-	"strconv"
 	"strings"
-	"time"
 )
 
 type (
-	sBinance struct{}
+	sBinance struct {
+		futuresURL string // 合约接口地址
+		spotURL    string // 现货接口地址
+		wsURL      string // 用户数据流地址，以/结尾
+
+		client   *http.Client // 共用客户端
+		timeSync *timeSync    // 服务器时间差
+		limiter  *rateLimiter // 请求权重和下单数限频
+	}
 )
 
 func init() {
 	service.RegisterBinance(New())
 }
 
+// New 按配置的环境选择接口地址
 func New() *sBinance {
-	return &sBinance{}
+	e := configEndpoint()
+	return NewWithBaseURL(e.futuresURL, e.spotURL, e.wsURL)
+}
+
+// NewWithBaseURL 指定接口地址，用于测试网或本地模拟服务
+func NewWithBaseURL(futuresURL, spotURL, wsURL string) *sBinance {
+	return &sBinance{
+		futuresURL: strings.TrimSuffix(futuresURL, "/"),
+		spotURL:    strings.TrimSuffix(spotURL, "/"),
+		wsURL:      strings.TrimSuffix(wsURL, "/") + "/",
+		client:     newHTTPClient(),
+		timeSync:   &timeSync{offset: gtype.NewInt64()},
+		limiter:    newRateLimiter(),
+	}
 }
 
 const (
-	apiBaseURL   = "https://fapi.binance.com"
-	wsBaseURL    = "wss://fstream.binance.com/ws/"
-	listenKeyURL = "/fapi/v1/listenKey"
+	futuresBaseURL = "https://fapi.binance.com"
+	spotBaseURL    = "https://api.binance.com"
+	wsBaseURL      = "wss://fstream.binance.com/ws/"
+	listenKeyURL   = "/fapi/v1/listenKey"
 )
 
 // 获取币安服务器时间
-func getBinanceServerTime() int64 {
-	urlTmp := "https://api.binance.com/api/v3/time"
-	resp, err := http.Get(urlTmp)
+func (s *sBinance) getServerTime() int64 {
+	body, err := s.publicRequest(s.futuresURL + "/fapi/v1/time")
 	if err != nil {
 		log.Println("Error getting server time:", err)
 		return 0
 	}
 
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
-
 	var serverTimeResponse struct {
 		ServerTime int64 `json:"serverTime"`
 	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Error reading response body:", err)
-		return 0
-	}
 	if err := json.Unmarshal(body, &serverTimeResponse); err != nil {
 		log.Println("Error unmarshaling server time:", err)
 		return 0
@@ -84,69 +89,16 @@ func generateSignature(apiS string, params url.Values) string {
 
 // GetBinancePositionSide 获取账户信息
 func (s *sBinance) GetBinancePositionSide(apiK, apiS string) string {
-	// 请求的API地址
-	endpoint := "/fapi/v1/positionSide/dual"
-	baseURL := "https://fapi.binance.com"
-
-	// 获取当前时间戳（使用服务器时间避免时差问题）
-	serverTime := getBinanceServerTime()
-	if serverTime == 0 {
-		return ""
-	}
-	timestamp := strconv.FormatInt(serverTime, 10)
-
-	// 设置请求参数
-	params := url.Values{}
-	params.Set("timestamp", timestamp)
-	params.Set("recvWindow", "5000") // 设置接收窗口
-
-	// 生成签名
-	signature := generateSignature(apiS, params)
-
-	// 将签名添加到请求参数中
-	params.Set("signature", signature)
-
-	// 构建完整的请求URL
-	requestURL := baseURL + endpoint + "?" + params.Encode()
-
-	// 创建请求
-	req, err := http.NewRequest("GET", requestURL, nil)
-	if err != nil {
-		log.Println("Error creating request:", err)
-		return ""
-	}
-
-	// 添加请求头
-	req.Header.Add("X-MBX-APIKEY", apiK)
-
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	body, _, err := s.signedRequest(http.MethodGet, s.futuresURL, "/fapi/v1/positionSide/dual", nil, apiK, apiS, requestTimeout)
 	if err != nil {
 		log.Println("Error sending request:", err)
 		return ""
 	}
 
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
-
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Error reading response:", err)
-		return ""
-	}
-
 	// 解析响应
 	var o *entity.PositionSide
 	err = json.Unmarshal(body, &o)
-	if err != nil {
+	if err != nil || nil == o {
 		log.Println("Error unmarshalling response:", err)
 		return ""
 	}
@@ -163,29 +115,18 @@ func (s *sBinance) GetBinancePositionSide(apiK, apiS string) string {
 
 // GetLatestPrice 获取价格
 func (s *sBinance) GetLatestPrice(symbol string) string {
-	baseURL := "https://api.binance.com/api/v3/ticker/price"
 	query := url.Values{}
 	query.Add("symbol", symbol)
 
-	// 构建请求 URL
-	requestURL := baseURL + "?" + query.Encode()
-	resp, err := http.Get(requestURL)
+	body, err := s.publicRequest(s.spotURL + "/api/v3/ticker/price?" + query.Encode())
 	if err != nil {
 		log.Println("获取价格错误：", err)
 		return ""
 	}
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
 
 	// 读取响应数据
 	var data *entity.LatestPrice
-	err = json.NewDecoder(resp.Body).Decode(&data)
+	err = json.Unmarshal(body, &data)
 	if err != nil {
 		log.Println("解析 JSON 错误：", err)
 		return ""
@@ -199,69 +140,37 @@ func (s *sBinance) GetLatestPrice(symbol string) string {
 	return data.Price
 }
 
-// GetWalletInfo 获取钱包信息
-func (s *sBinance) GetWalletInfo(apiK, apiS string) []*entity.WalletInfo {
-	// 请求的API地址
-	endpoint := "/sapi/v1/asset/wallet/balance"
-	baseURL := "https://api.binance.com"
-
-	res := make([]*entity.WalletInfo, 0)
-	// 获取当前时间戳（使用服务器时间避免时差问题）
-	serverTime := getBinanceServerTime()
-	if serverTime == 0 {
-		return res
-	}
-	timestamp := strconv.FormatInt(serverTime, 10)
-
-	// 设置请求参数
-	params := url.Values{}
-	params.Set("timestamp", timestamp)
-	params.Set("recvWindow", "5000") // 设置接收窗口
-
-	// 生成签名
-	signature := generateSignature(apiS, params)
-
-	// 将签名添加到请求参数中
-	params.Set("signature", signature)
-
-	// 构建完整的请求URL
-	requestURL := baseURL + endpoint + "?" + params.Encode()
+// GetMarkPrice 获取合约标记价格
+func (s *sBinance) GetMarkPrice(symbol string) string {
+	query := url.Values{}
+	query.Add("symbol", symbol)
 
-	// 创建请求
-	req, err := http.NewRequest("GET", requestURL, nil)
+	body, err := s.publicRequest(s.futuresURL + "/fapi/v1/premiumIndex?" + query.Encode())
 	if err != nil {
-		log.Println("Error creating request:", err)
-		return res
+		log.Println("获取标记价格错误：", err)
+		return ""
 	}
 
-	// 添加请求头
-	req.Header.Add("X-MBX-APIKEY", apiK)
-
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error sending request:", err)
-		return res
+	var data *entity.MarkPrice
+	err = json.Unmarshal(body, &data)
+	if err != nil || nil == data {
+		log.Println("解析标记价格错误：", err)
+		return ""
 	}
 
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
+	return data.MarkPrice
+}
 
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
+// GetWalletInfo 获取钱包信息
+func (s *sBinance) GetWalletInfo(apiK, apiS string) []*entity.WalletInfo {
+	res := make([]*entity.WalletInfo, 0)
+	body, _, err := s.signedRequest(http.MethodGet, s.spotURL, "/sapi/v1/asset/wallet/balance", nil, apiK, apiS, requestTimeout)
 	if err != nil {
-		log.Println("Error reading response:", err)
+		log.Println("Error sending request:", err)
 		return res
 	}
 
-	// 解析响应\
+	// 解析响应
 	err = json.Unmarshal(body, &res)
 	if err != nil {
 		log.Println("Error unmarshalling response:", err)
@@ -274,131 +183,53 @@ func (s *sBinance) GetWalletInfo(apiK, apiS string) []*entity.WalletInfo {
 
 // GetBinanceInfo 获取账户信息
 func (s *sBinance) GetBinanceInfo(apiK, apiS string) string {
-	// 请求的API地址
-	endpoint := "/fapi/v2/account"
-	baseURL := "https://fapi.binance.com"
-
-	// 获取当前时间戳（使用服务器时间避免时差问题）
-	serverTime := getBinanceServerTime()
-	if serverTime == 0 {
-		return ""
-	}
-	timestamp := strconv.FormatInt(serverTime, 10)
-
-	// 设置请求参数
-	params := url.Values{}
-	params.Set("timestamp", timestamp)
-	params.Set("recvWindow", "5000") // 设置接收窗口
-
-	// 生成签名
-	signature := generateSignature(apiS, params)
-
-	// 将签名添加到请求参数中
-	params.Set("signature", signature)
-
-	// 构建完整的请求URL
-	requestURL := baseURL + endpoint + "?" + params.Encode()
-
-	// 创建请求
-	req, err := http.NewRequest("GET", requestURL, nil)
+	body, _, err := s.signedRequest(http.MethodGet, s.futuresURL, "/fapi/v2/account", nil, apiK, apiS, requestTimeout)
 	if err != nil {
-		log.Println("Error creating request:", err)
+		log.Println("Error sending request:", err)
 		return ""
 	}
 
-	// 添加请求头
-	req.Header.Add("X-MBX-APIKEY", apiK)
-
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error sending request:", err)
+	// 解析响应
+	var o *entity.Asset
+	err = json.Unmarshal(body, &o)
+	if err != nil || nil == o {
+		log.Println("Error unmarshalling response:", err)
 		return ""
 	}
 
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
+	// 返回资产余额
+	return o.TotalMarginBalance
+}
 
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
+// GetBinanceAvailableBalance 获取可用于开仓的保证金
+func (s *sBinance) GetBinanceAvailableBalance(apiK, apiS string) string {
+	body, _, err := s.signedRequest(http.MethodGet, s.futuresURL, "/fapi/v2/account", nil, apiK, apiS, requestTimeout)
 	if err != nil {
-		log.Println("Error reading response:", err)
+		log.Println("Error sending request:", err)
 		return ""
 	}
 
-	// 解析响应
 	var o *entity.Asset
 	err = json.Unmarshal(body, &o)
-	if err != nil {
+	if err != nil || nil == o {
 		log.Println("Error unmarshalling response:", err)
 		return ""
 	}
 
-	// 返回资产余额
-	return o.TotalMarginBalance
+	return o.AvailableBalance
 }
 
 func (s *sBinance) RequestBinancePositionSide(positionSide string, apiKey string, secretKey string) (error, string, bool) {
 	var (
-		client       *http.Client
-		req          *http.Request
-		resp         *http.Response
 		resOrderInfo *entity.BinanceOrderInfo
-		data         string
-		b            []byte
-		err          error
-		apiUrl       = "https://fapi.binance.com/fapi/v1/positionSide/dual"
+		params       = url.Values{}
 	)
+	params.Set("dualSidePosition", positionSide)
 
-	//log.Println(symbol, side, orderType, positionSide, quantity, apiKey, secretKey)
-	// 时间
-	now := strconv.FormatInt(time.Now().UTC().UnixMilli(), 10)
-	// 拼请求数据
-	data = "dualSidePosition=" + positionSide + "&timestamp=" + now
-
-	// 加密
-	h := hmac.New(sha256.New, []byte(secretKey))
-	h.Write([]byte(data))
-	signature := hex.EncodeToString(h.Sum(nil))
-	// 构造请求
-
-	req, err = http.NewRequest("POST", apiUrl, strings.NewReader(data+"&signature="+signature))
+	b, _, err := s.signedRequest(http.MethodPost, s.futuresURL, "/fapi/v1/positionSide/dual", params, apiKey, secretKey, orderTimeout)
 	if err != nil {
 		return err, "", false
 	}
-	// 添加头信息
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("X-MBX-APIKEY", apiKey)
-
-	// 请求执行
-	client = &http.Client{Timeout: 3 * time.Second}
-	resp, err = client.Do(req)
-	if err != nil {
-		return err, "", false
-	}
-
-	// 结果
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
-
-	b, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		//log.Println(string(b), err)
-		return err, string(b), false
-	}
 
 	err = json.Unmarshal(b, &resOrderInfo)
 	if err != nil {
@@ -417,24 +248,7 @@ func (s *sBinance) RequestBinancePositionSide(positionSide string, apiKey string
 
 // GetBinanceFuturesPairs 获取 Binance U 本位合约交易对信息
 func (s *sBinance) GetBinanceFuturesPairs() ([]*entity.BinanceSymbolInfo, error) {
-	apiUrl := "https://fapi.binance.com/fapi/v1/exchangeInfo"
-
-	// 发送 HTTP GET 请求
-	resp, err := http.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
-
-	// 读取响应体
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := s.publicRequest(s.futuresURL + "/fapi/v1/exchangeInfo")
 	if err != nil {
 		return nil, err
 	}
@@ -449,72 +263,43 @@ func (s *sBinance) GetBinanceFuturesPairs() ([]*entity.BinanceSymbolInfo, error)
 	return exchangeInfo.Symbols, nil
 }
 
-// RequestBinanceOrder 请求下单
-func (s *sBinance) RequestBinanceOrder(symbol string, side string, orderType string, positionSide string, quantity string, apiKey string, secretKey string, reduceOnly bool) (*entity.BinanceOrder, *entity.BinanceOrderInfo, error) {
+// RequestBinanceOrder 请求下单，clientOrderId非空时作为newClientOrderId，被拒时返回按错误码归类的*entity.BinanceError
+func (s *sBinance) RequestBinanceOrder(symbol string, side string, orderType string, positionSide string, quantity string, apiKey string, secretKey string, reduceOnly bool, clientOrderId string) (*entity.BinanceOrder, *entity.BinanceOrderInfo, error) {
 	var (
-		client       *http.Client
-		req          *http.Request
-		resp         *http.Response
 		res          *entity.BinanceOrder
 		resOrderInfo *entity.BinanceOrderInfo
-		data         string
-		b            []byte
-		err          error
-		apiUrl       = "https://fapi.binance.com/fapi/v1/order"
+		params       = url.Values{}
 	)
 
 	//log.Println(symbol, side, orderType, positionSide, quantity, apiKey, secretKey)
-	// 时间
-	now := strconv.FormatInt(time.Now().UTC().UnixMilli(), 10)
 	// 拼请求数据
+	params.Set("symbol", symbol)
+	params.Set("side", side)
+	params.Set("type", orderType)
+	params.Set("positionSide", positionSide)
+	params.Set("newOrderRespType", "RESULT")
+	params.Set("quantity", quantity)
 	if reduceOnly {
-		data = "symbol=" + symbol + "&side=" + side + "&type=" + orderType + "&positionSide=" + positionSide + "&newOrderRespType=" + "RESULT" + "&reduceOnly=true&quantity=" + quantity + "&timestamp=" + now
-	} else {
-		data = "symbol=" + symbol + "&side=" + side + "&type=" + orderType + "&positionSide=" + positionSide + "&newOrderRespType=" + "RESULT" + "&quantity=" + quantity + "&timestamp=" + now
-	}
-
-	// 加密
-	h := hmac.New(sha256.New, []byte(secretKey))
-	h.Write([]byte(data))
-	signature := hex.EncodeToString(h.Sum(nil))
-	// 构造请求
-
-	req, err = http.NewRequest("POST", apiUrl, strings.NewReader(data+"&signature="+signature))
-	if err != nil {
-		return nil, nil, err
+		params.Set("reduceOnly", "true")
 	}
-	// 添加头信息
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("X-MBX-APIKEY", apiKey)
-
-	// 请求执行
-	client = &http.Client{Timeout: 3 * time.Second}
-	resp, err = client.Do(req)
-	if err != nil {
-		return nil, nil, err
+	if 0 < len(clientOrderId) {
+		params.Set("newClientOrderId", clientOrderId)
 	}
 
-	// 结果
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
-
-	b, err = ioutil.ReadAll(resp.Body)
+	b, status, err := s.signedRequest(http.MethodPost, s.futuresURL, "/fapi/v1/order", params, apiKey, secretKey, orderTimeout)
 	if err != nil {
-		log.Println(string(b), err)
 		return nil, nil, err
 	}
 
 	var o *entity.BinanceOrder
 	err = json.Unmarshal(b, &o)
-	if err != nil {
+	if err != nil || nil == o {
 		log.Println(string(b), err)
-		return nil, nil, err
+		if http.StatusInternalServerError <= status {
+			return nil, nil, serverError(status, b)
+		}
+
+		return nil, nil, gerror.Newf("unmarshal order: %v %s", err, string(b))
 	}
 
 	res = &entity.BinanceOrder{
@@ -528,6 +313,7 @@ func (s *sBinance) RequestBinanceOrder(symbol string, side string, orderType str
 		PositionSide:  o.PositionSide,
 		ClosePosition: o.ClosePosition,
 		Type:          o.Type,
+		Status:        o.Status,
 	}
 
 	if 0 >= res.OrderId {
@@ -537,6 +323,9 @@ func (s *sBinance) RequestBinanceOrder(symbol string, side string, orderType str
 			log.Println(string(b), err)
 			return nil, nil, err
 		}
+
+		// 下单被拒，按错误码归类
+		return res, resOrderInfo, NewError(resOrderInfo.Code, resOrderInfo.Msg)
 	}
 
 	return res, resOrderInfo, nil
@@ -544,68 +333,16 @@ func (s *sBinance) RequestBinanceOrder(symbol string, side string, orderType str
 
 // GetBinancePositionInfo 获取账户信息
 func (s *sBinance) GetBinancePositionInfo(apiK, apiS string) []*entity.BinancePosition {
-	// 请求的API地址
-	endpoint := "/fapi/v2/account"
-	baseURL := "https://fapi.binance.com"
-
-	// 获取当前时间戳（使用服务器时间避免时差问题）
-	serverTime := getBinanceServerTime()
-	if serverTime == 0 {
-		return nil
-	}
-	timestamp := strconv.FormatInt(serverTime, 10)
-
-	// 设置请求参数
-	params := url.Values{}
-	params.Set("timestamp", timestamp)
-	params.Set("recvWindow", "5000") // 设置接收窗口
-
-	// 生成签名
-	signature := generateSignature(apiS, params)
-
-	// 将签名添加到请求参数中
-	params.Set("signature", signature)
-
-	// 构建完整的请求URL
-	requestURL := baseURL + endpoint + "?" + params.Encode()
-
-	// 创建请求
-	req, err := http.NewRequest("GET", requestURL, nil)
-	if err != nil {
-		log.Println("Error creating request:", err)
-		return nil
-	}
-
-	// 添加请求头
-	req.Header.Add("X-MBX-APIKEY", apiK)
-
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	body, _, err := s.signedRequest(http.MethodGet, s.futuresURL, "/fapi/v2/account", nil, apiK, apiS, requestTimeout)
 	if err != nil {
 		log.Println("Error sending request:", err)
 		return nil
 	}
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
-
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Error reading response:", err)
-		return nil
-	}
 
 	// 解析响应
 	var o *entity.BinanceResponse
 	err = json.Unmarshal(body, &o)
-	if err != nil {
+	if err != nil || nil == o {
 		log.Println("Error unmarshalling response:", err)
 		return nil
 	}
@@ -614,99 +351,75 @@ func (s *sBinance) GetBinancePositionInfo(apiK, apiS string) []*entity.BinancePo
 	return o.Positions
 }
 
-var (
-	ListenKey = gvar.New("", true)
-	Conn      *websocket.Conn // WebSocket connection
-)
-
 // ListenKeyResponse represents the response from Binance API when creating or renewing a ListenKey
 type ListenKeyResponse struct {
 	ListenKey string `json:"listenKey"`
 }
 
 // CreateListenKey creates a new ListenKey for user data stream
-func (s *sBinance) CreateListenKey(apiKey string) error {
-	req, err := http.NewRequest("POST", apiBaseURL+listenKeyURL, nil)
+func (s *sBinance) CreateListenKey(apiKey string) (string, error) {
+	req, err := http.NewRequest("POST", s.futuresURL+listenKeyURL, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
 	req.Header.Set("X-MBX-APIKEY", apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	body, status, err := s.doRequest(req, requestTimeout)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gerror.Newf("API error: %s", string(body))
+	if status != http.StatusOK {
+		return "", gerror.Newf("API error: %s", string(body))
 	}
 
 	var response *ListenKeyResponse
-	err = json.NewDecoder(resp.Body).Decode(&response)
+	err = json.Unmarshal(body, &response)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	ListenKey.Set(response.ListenKey)
-	return nil
+	return response.ListenKey, nil
 }
 
 // RenewListenKey renews the ListenKey for user data stream
 func (s *sBinance) RenewListenKey(apiKey string) error {
-	req, err := http.NewRequest("PUT", apiBaseURL+listenKeyURL, nil)
+	req, err := http.NewRequest("PUT", s.futuresURL+listenKeyURL, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("X-MBX-APIKEY", apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	body, status, err := s.doRequest(req, requestTimeout)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			err := resp.Body.Close()
-			if err != nil {
-				log.Println("关闭响应体错误：", err)
-			}
-		}
-	}()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
+	if status != http.StatusOK {
 		return gerror.Newf("API error: %s", string(body))
 	}
 
 	return nil
 }
 
-// ConnectWebSocket safely connects to the WebSocket and updates conn
-func (s *sBinance) ConnectWebSocket() error {
-	// Close the existing connection if open
-	if Conn != nil {
-		err := Conn.Close()
-		if err != nil {
-			log.Println("Failed to close old connection:", err)
-		}
-	}
-
+// ConnectWebSocket connects to the user data stream of the listen key
+func (s *sBinance) ConnectWebSocket(listenKey string) (*websocket.Conn, error) {
 	// Create a new WebSocket connection
-	wsURL := wsBaseURL + ListenKey.String()
-	var err error
-	Conn, _, err = websocket.DefaultDialer.Dial(wsURL, nil)
+	wsURL := s.wsURL + listenKey
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: requestTimeout,
+	}
+	conn, _, err := dialer.Dial(wsURL, nil)
 	if err != nil {
-		return gerror.Newf("failed to connect to WebSocket: %v", err)
+		return nil, gerror.Newf("failed to connect to WebSocket: %v", err)
 	}
 
 	log.Println("WebSocket connection established.")
-	return nil
+	return conn, nil
+}
+
+// GetRateLimits 当前请求权重和下单数用量
+func (s *sBinance) GetRateLimits() []*entity.BinanceRateLimit {
+	return s.limiter.usage(s.serverNow())
 }
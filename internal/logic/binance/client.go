@@ -0,0 +1,178 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gogf/gf/v2/container/gtype"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/gtimer"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	requestTimeout  = 10 * time.Second // 默认请求超时
+	orderTimeout    = 3 * time.Second  // 下单请求超时
+	recvWindow      = "5000"           // 签名请求的接收窗口，毫秒
+	timeSyncPeriod  = time.Minute      // 服务器时间同步间隔
+	timestampErrors = -1021            // 时间戳超出接收窗口
+)
+
+// timeSync 本地与币安服务器的时间差，后台定时刷新，签名请求不再每次请求服务器时间
+type timeSync struct {
+	once   sync.Once
+	offset *gtype.Int64 // 服务器时间-本地时间，毫秒
+}
+
+// newHTTPClient 连接复用、有超时的客户端，所有币安请求共用
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          200,
+			MaxIdleConnsPerHost:   100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// timestamp 按时间差校正后的服务器时间，首次调用时同步并开启后台刷新
+func (s *sBinance) timestamp() string {
+	s.timeSync.once.Do(func() {
+		s.syncTime()
+		gtimer.AddSingleton(gctx.GetInitCtx(), timeSyncPeriod, func(ctx context.Context) {
+			s.syncTime()
+		})
+	})
+
+	return strconv.FormatInt(s.serverNow(), 10)
+}
+
+// syncTime 请求服务器时间，以往返中点估算时间差
+func (s *sBinance) syncTime() {
+	start := time.Now().UnixMilli()
+	serverTime := s.getServerTime()
+	if 0 >= serverTime {
+		return
+	}
+
+	end := time.Now().UnixMilli()
+	s.timeSync.offset.Set(serverTime - (start+end)/2)
+}
+
+// serverNow 按时间差校正后的服务器时间，毫秒，不触发同步
+func (s *sBinance) serverNow() int64 {
+	return time.Now().UnixMilli() + s.timeSync.offset.Val()
+}
+
+// doRequest 使用共用客户端发送请求，请求前按限频排队，返回响应体和状态码
+func (s *sBinance) doRequest(req *http.Request, timeout time.Duration) ([]byte, int, error) {
+	apiKey := req.Header.Get("X-MBX-APIKEY")
+	s.limiter.acquire(s.serverNow, req.Method, req.URL.Path, apiKey)
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	s.limiter.update(s.serverNow(), req.URL.Path, apiKey, resp.Header, resp.StatusCode)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			err := resp.Body.Close()
+			if err != nil {
+				log.Println("关闭响应体错误：", err)
+			}
+		}
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// signedRequest 签名请求，GET、DELETE参数在查询字符串，POST、PUT参数在请求体
+func (s *sBinance) signedRequest(method, baseURL, endpoint string, params url.Values, apiK, apiS string, timeout time.Duration) ([]byte, int, error) {
+	if nil == params {
+		params = url.Values{}
+	}
+	params.Set("timestamp", s.timestamp())
+	params.Set("recvWindow", recvWindow)
+
+	payload := params.Encode()
+	payload += "&signature=" + generateSignature(apiS, params)
+
+	var (
+		req *http.Request
+		err error
+	)
+	if http.MethodGet == method || http.MethodDelete == method {
+		req, err = http.NewRequest(method, baseURL+endpoint+"?"+payload, nil)
+	} else {
+		req, err = http.NewRequest(method, baseURL+endpoint, strings.NewReader(payload))
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req.Header.Set("X-MBX-APIKEY", apiK)
+	if nil != req.Body {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	body, status, err := s.doRequest(req, timeout)
+	if err != nil {
+		return nil, status, err
+	}
+
+	// 时间戳超出接收窗口，同步完成后返回，调用方重试时使用新的时间差
+	if http.StatusOK != status {
+		var apiErr struct {
+			Code int64 `json:"code"`
+		}
+		if nil == json.Unmarshal(body, &apiErr) && timestampErrors == apiErr.Code {
+			log.Println("币安时间戳错误，重新同步服务器时间：", endpoint)
+			s.syncTime()
+		}
+	}
+
+	return body, status, nil
+}
+
+// publicRequest 无需签名的GET请求
+func (s *sBinance) publicRequest(requestURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, status, err := s.doRequest(req, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if http.StatusOK != status {
+		return nil, gerror.Newf("API error: %d %s", status, string(body))
+	}
+
+	return body, nil
+}
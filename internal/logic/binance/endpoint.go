@@ -0,0 +1,63 @@
+package binance
+
+import (
+	"github.com/gogf/gf/v2/os/gctx"
+	"log"
+	"plat_order/utility"
+)
+
+const (
+	envProduction = "production"
+	envTestnet    = "testnet"
+	envCustom     = "custom"
+)
+
+// endpoint 一套环境的接口地址
+type endpoint struct {
+	futuresURL string
+	spotURL    string
+	wsURL      string
+}
+
+var endpoints = map[string]*endpoint{
+	envProduction: {
+		futuresURL: futuresBaseURL,
+		spotURL:    spotBaseURL,
+		wsURL:      wsBaseURL,
+	},
+	envTestnet: {
+		futuresURL: "https://testnet.binancefuture.com",
+		spotURL:    "https://testnet.binance.vision",
+		wsURL:      "wss://fstream.binancefuture.com/ws/",
+	},
+}
+
+// configEndpoint 读取配置exchange.binance，env为production、testnet或custom，配置的地址覆盖环境默认值
+func configEndpoint() *endpoint {
+	var (
+		ctx = gctx.GetInitCtx()
+		env = utility.ConfigString(ctx, "exchange.binance.env", envProduction)
+		res = *endpoints[envProduction]
+	)
+	if preset, ok := endpoints[env]; ok {
+		res = *preset
+	} else if envCustom != env {
+		log.Println("币安环境配置错误，使用生产环境：", env)
+	}
+
+	if v := utility.ConfigString(ctx, "exchange.binance.futuresUrl", ""); 0 < len(v) {
+		res.futuresURL = v
+	}
+	if v := utility.ConfigString(ctx, "exchange.binance.spotUrl", ""); 0 < len(v) {
+		res.spotURL = v
+	}
+	if v := utility.ConfigString(ctx, "exchange.binance.wsUrl", ""); 0 < len(v) {
+		res.wsURL = v
+	}
+
+	if envProduction != env {
+		log.Println("币安接口地址：", env, res.futuresURL, res.spotURL, res.wsURL)
+	}
+
+	return &res
+}
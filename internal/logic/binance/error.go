@@ -0,0 +1,45 @@
+package binance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"plat_order/internal/consts"
+	"plat_order/internal/model/entity"
+)
+
+// NewError 按错误码归类
+func NewError(code int64, msg string) *entity.BinanceError {
+	class, ok := consts.BinanceErrorClasses[code]
+	if !ok {
+		class = consts.BinanceErrUnknown
+	}
+
+	return &entity.BinanceError{Code: code, Msg: msg, Class: class}
+}
+
+// serverError 服务端5XX且响应不是错误码，币安文档说明此时订单执行状态未知
+func serverError(status int, body []byte) *entity.BinanceError {
+	return &entity.BinanceError{Msg: fmt.Sprintf("http %d %s", status, string(body)), Class: consts.BinanceErrServer}
+}
+
+// ErrorClass 错误分类，请求未得到响应的传输错误和超时为网络错误，本地解析等其他错误为未归类
+func ErrorClass(err error) string {
+	if nil == err {
+		return ""
+	}
+
+	var e *entity.BinanceError
+	if errors.As(err, &e) {
+		return e.Class
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return consts.BinanceErrNetwork
+	}
+
+	return consts.BinanceErrUnknown
+}
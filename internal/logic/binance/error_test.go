@@ -0,0 +1,68 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"net"
+	"net/url"
+	"plat_order/internal/consts"
+	"testing"
+)
+
+func TestNewError(t *testing.T) {
+	tests := map[int64]string{
+		-1001: consts.BinanceErrServer,
+		-1007: consts.BinanceErrServer,
+		-1021: consts.BinanceErrTimestamp,
+		-1003: consts.BinanceErrRateLimit,
+		-2015: consts.BinanceErrAuth,
+		-1121: consts.BinanceErrSymbol,
+		-1111: consts.BinanceErrPrecision,
+		-4164: consts.BinanceErrQuantity,
+		-2019: consts.BinanceErrMargin,
+		-2022: consts.BinanceErrReduceOnly,
+		-4061: consts.BinanceErrPositionSide,
+		-2013: consts.BinanceErrNotFound,
+		-4116: consts.BinanceErrDuplicate,
+		-9999: consts.BinanceErrUnknown,
+	}
+
+	for code, want := range tests {
+		e := NewError(code, "msg")
+		if want != e.Class || code != e.Code || "msg" != e.Msg {
+			t.Errorf("错误码%d分类%s，期望%s", code, e.Class, want)
+		}
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	var (
+		syntaxErr = json.Unmarshal([]byte("<html>"), &struct{}{})
+		dialErr   = &url.Error{Op: "Post", URL: "https://fapi.binance.com/fapi/v1/order", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "币安错误码", err: NewError(-2019, "Margin is insufficient."), want: consts.BinanceErrMargin},
+		{name: "包装后的币安错误码", err: fmt.Errorf("下单失败：%w", NewError(-2022, "ReduceOnly Order is rejected.")), want: consts.BinanceErrReduceOnly},
+		{name: "服务端5XX", err: serverError(502, []byte("Bad Gateway")), want: consts.BinanceErrServer},
+		{name: "连接失败", err: dialErr, want: consts.BinanceErrNetwork},
+		{name: "请求超时", err: context.DeadlineExceeded, want: consts.BinanceErrNetwork},
+		{name: "gerror包装超时", err: gerror.Wrap(context.DeadlineExceeded, "请求失败"), want: consts.BinanceErrNetwork},
+		{name: "响应解析失败", err: syntaxErr, want: consts.BinanceErrUnknown},
+		{name: "无效订单响应", err: gerror.Newf("invalid order response: %v", nil), want: consts.BinanceErrUnknown},
+	}
+
+	for _, tt := range tests {
+		if res := ErrorClass(tt.err); tt.want != res {
+			t.Errorf("%s: 分类%q，期望%q", tt.name, res, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package binance
+
+import (
+	"encoding/json"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"log"
+	"net/http"
+	"net/url"
+	"plat_order/internal/model/entity"
+	"strconv"
+	"time"
+)
+
+const (
+	fillPollTimes    = 5                      // 订单未结束时查询次数
+	fillPollInterval = 200 * time.Millisecond // 查询间隔
+)
+
+// finalOrderStatus 不会再有成交的订单状态
+var finalOrderStatus = map[string]bool{
+	"FILLED":           true,
+	"CANCELED":         true,
+	"EXPIRED":          true,
+	"EXPIRED_IN_MATCH": true,
+	"REJECTED":         true,
+}
+
+// binanceFill 订单转为成交结果
+func binanceFill(order *entity.BinanceOrder) *entity.OrderFill {
+	executedQty, _ := strconv.ParseFloat(order.ExecutedQty, 64)
+	avgPrice, _ := strconv.ParseFloat(order.AvgPrice, 64)
+
+	return &entity.OrderFill{
+		Plat:        "binance",
+		OrderId:     strconv.FormatInt(order.OrderId, 10),
+		Symbol:      order.Symbol,
+		Status:      order.Status,
+		Final:       finalOrderStatus[order.Status],
+		ExecutedQty: executedQty,
+		AvgPrice:    avgPrice,
+	}
+}
+
+// QueryBinanceOrder 查询订单
+func (s *sBinance) QueryBinanceOrder(symbol string, orderId int64, apiK, apiS string) (*entity.BinanceOrder, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", strconv.FormatInt(orderId, 10))
+
+	return s.queryOrder(params, apiK, apiS)
+}
+
+// QueryBinanceOrderByClientId 按clientOrderId查询订单，订单不存在时返回NOT_FOUND分类的错误
+func (s *sBinance) QueryBinanceOrderByClientId(symbol string, clientOrderId string, apiK, apiS string) (*entity.BinanceOrder, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("origClientOrderId", clientOrderId)
+
+	return s.queryOrder(params, apiK, apiS)
+}
+
+// queryOrder 查询订单，接口错误按错误码归类
+func (s *sBinance) queryOrder(params url.Values, apiK, apiS string) (*entity.BinanceOrder, error) {
+	body, status, err := s.signedRequest(http.MethodGet, s.futuresURL, "/fapi/v1/order", params, apiK, apiS, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if http.StatusOK != status {
+		var info *entity.BinanceOrderInfo
+		if nil == json.Unmarshal(body, &info) && nil != info && 0 != info.Code {
+			return nil, NewError(info.Code, info.Msg)
+		}
+
+		return nil, gerror.Newf("API error: %d %s", status, string(body))
+	}
+
+	var o *entity.BinanceOrder
+	err = json.Unmarshal(body, &o)
+	if err != nil || nil == o || 0 >= o.OrderId {
+		return nil, gerror.Newf("unmarshal order: %v %s", err, string(body))
+	}
+
+	return o, nil
+}
+
+// BinanceOrderFill 下单结果转为成交结果，订单未结束时查询订单状态，查询后仍未结束返回已成交部分和错误
+func (s *sBinance) BinanceOrderFill(order *entity.BinanceOrder, apiK, apiS string) (*entity.OrderFill, error) {
+	if nil == order || 0 >= order.OrderId {
+		return nil, gerror.New("invalid order")
+	}
+
+	res := binanceFill(order)
+	for i := 0; i < fillPollTimes && !res.Final; i++ {
+		time.Sleep(fillPollInterval)
+
+		o, err := s.QueryBinanceOrder(order.Symbol, order.OrderId, apiK, apiS)
+		if nil != err {
+			log.Println("查询订单错误：", order.Symbol, order.OrderId, err)
+			continue
+		}
+
+		res = binanceFill(o)
+	}
+
+	if !res.Final {
+		return res, gerror.Newf("order %d is not final: %s", order.OrderId, res.Status)
+	}
+
+	return res, nil
+}
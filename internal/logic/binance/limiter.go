@@ -0,0 +1,357 @@
+package binance
+
+import (
+	"github.com/gogf/gf/v2/os/gctx"
+	"log"
+	"net/http"
+	"plat_order/internal/model/entity"
+	"plat_order/utility"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	limitTypeWeight  = "weight"
+	limitTypeOrder   = "order"
+	limitRatio       = 0.8              // 默认只使用上限的80%，留给其他进程和估算误差
+	limitMaxWait     = 2 * time.Minute  // 单次请求最长排队时间
+	limitBanDefault  = 30 * time.Second // 429、418未返回Retry-After时的等待时间
+	weightHeaderPre  = "X-Mbx-Used-Weight-"
+	orderHeaderPre   = "X-Mbx-Order-Count-"
+	defaultReqWeight = 1
+)
+
+// ipWeightLimits 各接口组每分钟IP权重上限
+var ipWeightLimits = map[string]int{
+	"fapi": 2400,
+	"api":  6000,
+	"sapi": 12000,
+}
+
+// orderLimits 合约下单数上限，按API Key计
+var orderLimits = map[time.Duration]int{
+	10 * time.Second: 300,
+	time.Minute:      1200,
+}
+
+// endpointWeights 接口权重，未列出的按1计
+var endpointWeights = map[string]int{
+	"GET /fapi/v2/account":              5,
+	"GET /fapi/v1/positionSide/dual":    30,
+	"GET /api/v3/ticker/price":          2,
+	"GET /sapi/v1/asset/wallet/balance": 60,
+}
+
+// window 固定时间窗口计数，与币安一样按整分钟、整10秒重置
+type window struct {
+	interval time.Duration
+	limit    int
+	used     int
+	start    int64 // 窗口开始时间，毫秒
+}
+
+// roll 进入新窗口时清零
+func (w *window) roll(now int64) {
+	start := now - now%w.interval.Milliseconds()
+	if start != w.start {
+		w.start = start
+		w.used = 0
+	}
+}
+
+func (w *window) resetAt() int64 {
+	return w.start + w.interval.Milliseconds()
+}
+
+// budget 允许本地使用的上限
+func (w *window) budget(ratio float64) int {
+	res := int(float64(w.limit) * ratio)
+	if 0 >= res {
+		res = 1
+	}
+
+	return res
+}
+
+// bucket 一个IP接口组或一个API Key的全部窗口
+type bucket struct {
+	windows     map[time.Duration]*window
+	bannedUntil int64
+}
+
+func newBucket(limits map[time.Duration]int) *bucket {
+	res := &bucket{windows: make(map[time.Duration]*window, len(limits))}
+	for interval, limit := range limits {
+		res.windows[interval] = &window{interval: interval, limit: limit}
+	}
+
+	return res
+}
+
+// rateLimiter 按响应头X-MBX-USED-WEIGHT-*、X-MBX-ORDER-COUNT-*维护用量，请求前超过预算则等待窗口重置
+type rateLimiter struct {
+	mu     sync.Mutex
+	ratio  float64
+	ip     map[string]*bucket // 接口组，同一出口IP共用
+	orders map[string]*bucket // API Key
+}
+
+func newRateLimiter() *rateLimiter {
+	ratio := utility.ConfigFloat(gctx.GetInitCtx(), "exchange.binance.limitRatio", limitRatio)
+	if 0 >= ratio || 1 < ratio {
+		log.Println("币安限频比例配置错误，使用默认值：", ratio)
+		ratio = limitRatio
+	}
+
+	return &rateLimiter{
+		ratio:  ratio,
+		ip:     make(map[string]*bucket, 0),
+		orders: make(map[string]*bucket, 0),
+	}
+}
+
+// limitGroup 接口组，路径第一段，fapi、api、sapi
+func limitGroup(path string) string {
+	return strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+}
+
+// requestWeight 接口权重
+func requestWeight(method, path string) int {
+	if weight, ok := endpointWeights[method+" "+path]; ok {
+		return weight
+	}
+
+	return defaultReqWeight
+}
+
+// isOrderRequest 计入下单数的请求
+func isOrderRequest(method, path string) bool {
+	return http.MethodPost == method && "/fapi/v1/order" == path
+}
+
+func (l *rateLimiter) ipBucket(group string) *bucket {
+	if b, ok := l.ip[group]; ok {
+		return b
+	}
+
+	limit, ok := ipWeightLimits[group]
+	if !ok {
+		limit = ipWeightLimits["fapi"]
+	}
+
+	b := newBucket(map[time.Duration]int{time.Minute: limit})
+	l.ip[group] = b
+	return b
+}
+
+func (l *rateLimiter) orderBucket(apiKey string) *bucket {
+	if b, ok := l.orders[apiKey]; ok {
+		return b
+	}
+
+	b := newBucket(orderLimits)
+	l.orders[apiKey] = b
+	return b
+}
+
+// reserve 需要等待的时间，0表示可以立即请求并已计入用量
+func (l *rateLimiter) reserve(now int64, group string, weight int, apiKey string, order bool) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := []*bucket{l.ipBucket(group)}
+	costs := []int{weight}
+	if order && 0 < len(apiKey) {
+		buckets = append(buckets, l.orderBucket(apiKey))
+		costs = append(costs, 1)
+	}
+
+	var wait int64
+	for i, b := range buckets {
+		if now < b.bannedUntil && wait < b.bannedUntil-now {
+			wait = b.bannedUntil - now
+		}
+
+		for _, w := range b.windows {
+			w.roll(now)
+			if 0 < w.used && w.used+costs[i] > w.budget(l.ratio) && wait < w.resetAt()-now {
+				wait = w.resetAt() - now
+			}
+		}
+	}
+
+	if 0 < wait {
+		return time.Duration(wait) * time.Millisecond
+	}
+
+	for i, b := range buckets {
+		for _, w := range b.windows {
+			w.used += costs[i]
+		}
+	}
+
+	return 0
+}
+
+// acquire 请求前调用，超过预算时排队等待窗口重置
+func (l *rateLimiter) acquire(now func() int64, method, path, apiKey string) {
+	var (
+		group  = limitGroup(path)
+		weight = requestWeight(method, path)
+		order  = isOrderRequest(method, path)
+		waited time.Duration
+	)
+	for {
+		wait := l.reserve(now(), group, weight, apiKey, order)
+		if 0 >= wait {
+			return
+		}
+
+		if waited+wait > limitMaxWait {
+			log.Println("币安限频等待超时，继续请求：", method, path, waited)
+			return
+		}
+
+		log.Println("币安限频，等待：", method, path, wait)
+		time.Sleep(wait)
+		waited += wait
+	}
+}
+
+// update 用响应头校正用量，429、418按Retry-After暂停
+func (l *rateLimiter) update(now int64, path, apiKey string, header http.Header, status int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ipBucket := l.ipBucket(limitGroup(path))
+	for name, values := range header {
+		if 0 >= len(values) {
+			continue
+		}
+
+		var (
+			b        *bucket
+			interval time.Duration
+		)
+		if strings.HasPrefix(name, weightHeaderPre) {
+			b, interval = ipBucket, parseInterval(strings.TrimPrefix(name, weightHeaderPre))
+		} else if strings.HasPrefix(name, orderHeaderPre) && 0 < len(apiKey) {
+			b, interval = l.orderBucket(apiKey), parseInterval(strings.TrimPrefix(name, orderHeaderPre))
+		} else {
+			continue
+		}
+
+		used, err := strconv.Atoi(values[0])
+		if nil != err || 0 >= interval {
+			continue
+		}
+
+		w, ok := b.windows[interval]
+		if !ok {
+			continue
+		}
+
+		w.roll(now)
+		w.used = used
+	}
+
+	if http.StatusTooManyRequests != status && http.StatusTeapot != status {
+		return
+	}
+
+	ban := limitBanDefault
+	if retryAfter, err := strconv.Atoi(header.Get("Retry-After")); nil == err && 0 < retryAfter {
+		ban = time.Duration(retryAfter) * time.Second
+	}
+
+	log.Println("币安限频被拒绝，暂停请求：", status, path, ban)
+	ipBucket.bannedUntil = now + ban.Milliseconds()
+}
+
+// parseInterval 响应头中的窗口，如1M、10S、1H、1D
+func parseInterval(s string) time.Duration {
+	if 2 > len(s) {
+		return 0
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if nil != err || 0 >= n {
+		return 0
+	}
+
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "S":
+		return time.Duration(n) * time.Second
+	case "M":
+		return time.Duration(n) * time.Minute
+	case "H":
+		return time.Duration(n) * time.Hour
+	case "D":
+		return time.Duration(n) * 24 * time.Hour
+	}
+
+	return 0
+}
+
+// formatInterval 与响应头一致的窗口写法
+func formatInterval(d time.Duration) string {
+	if 0 == d%time.Minute {
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	}
+
+	return strconv.Itoa(int(d/time.Second)) + "s"
+}
+
+// maskApiKey 只保留API Key后4位
+func maskApiKey(apiKey string) string {
+	if 4 >= len(apiKey) {
+		return "****"
+	}
+
+	return "****" + apiKey[len(apiKey)-4:]
+}
+
+// usage 当前各窗口用量，API Key脱敏
+func (l *rateLimiter) usage(now int64) []*entity.BinanceRateLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	res := make([]*entity.BinanceRateLimit, 0)
+	add := func(key, limitType string, b *bucket) {
+		for _, w := range b.windows {
+			w.roll(now)
+			res = append(res, &entity.BinanceRateLimit{
+				Key:         key,
+				Type:        limitType,
+				Interval:    formatInterval(w.interval),
+				Used:        w.used,
+				Limit:       w.limit,
+				Budget:      w.budget(l.ratio),
+				ResetAt:     w.resetAt(),
+				BannedUntil: b.bannedUntil,
+			})
+		}
+	}
+
+	for group, b := range l.ip {
+		add(group, limitTypeWeight, b)
+	}
+	for apiKey, b := range l.orders {
+		add(maskApiKey(apiKey), limitTypeOrder, b)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Type != res[j].Type {
+			return res[i].Type > res[j].Type
+		}
+		if res[i].Key != res[j].Key {
+			return res[i].Key < res[j].Key
+		}
+		return res[i].Interval < res[j].Interval
+	})
+
+	return res
+}
@@ -0,0 +1,140 @@
+package binance
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(ratio float64) *rateLimiter {
+	return &rateLimiter{
+		ratio:  ratio,
+		ip:     make(map[string]*bucket, 0),
+		orders: make(map[string]*bucket, 0),
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := map[string]time.Duration{
+		"1M":  time.Minute,
+		"1m":  time.Minute,
+		"10S": 10 * time.Second,
+		"1H":  time.Hour,
+		"1D":  24 * time.Hour,
+		"":    0,
+		"M":   0,
+		"0M":  0,
+		"-1M": 0,
+		"5X":  0,
+		"AM":  0,
+	}
+
+	for s, want := range tests {
+		if res := parseInterval(s); want != res {
+			t.Errorf("parseInterval(%q) = %v，期望%v", s, res, want)
+		}
+	}
+}
+
+func TestLimiterReserve(t *testing.T) {
+	var (
+		l   = newTestLimiter(0.5) // fapi预算1200
+		now = int64(60000*1000 + 1000)
+	)
+
+	// 首个请求即使超过预算也不等待
+	if wait := l.reserve(now, "fapi", 1500, "", false); 0 != wait {
+		t.Fatalf("首个请求等待：%v", wait)
+	}
+
+	// 超过预算等到窗口重置
+	if wait := l.reserve(now, "fapi", 1, "", false); 59*time.Second != wait {
+		t.Fatalf("超过预算等待时间错误：%v", wait)
+	}
+
+	// 新窗口清零
+	if wait := l.reserve(now+59000, "fapi", 1000, "", false); 0 != wait {
+		t.Fatalf("新窗口仍等待：%v", wait)
+	}
+
+	// 接口组之间互不影响
+	if wait := l.reserve(now+59000, "api", 1, "", false); 0 != wait {
+		t.Fatalf("其他接口组等待：%v", wait)
+	}
+
+	// 下单数按API Key计，10秒窗口预算150
+	l = newTestLimiter(0.5)
+	for i := 0; i < 150; i++ {
+		if wait := l.reserve(now, "fapi", 1, "key1", true); 0 != wait {
+			t.Fatalf("第%d笔下单等待：%v", i, wait)
+		}
+	}
+
+	if wait := l.reserve(now, "fapi", 1, "key1", true); 9*time.Second != wait {
+		t.Fatalf("下单数超过预算等待时间错误：%v", wait)
+	}
+
+	if wait := l.reserve(now, "fapi", 1, "key2", true); 0 != wait {
+		t.Fatalf("其他API Key下单等待：%v", wait)
+	}
+
+	// 非下单请求不计下单数
+	if wait := l.reserve(now, "fapi", 1, "key1", false); 0 != wait {
+		t.Fatalf("非下单请求等待：%v", wait)
+	}
+}
+
+func TestLimiterUpdate(t *testing.T) {
+	var (
+		l      = newTestLimiter(0.5)
+		now    = int64(60000*1000 + 1000)
+		header = http.Header{}
+	)
+
+	// 以响应头用量为准
+	header.Set("X-MBX-USED-WEIGHT-1M", "1200")
+	header.Set("X-MBX-ORDER-COUNT-10S", "150")
+	l.update(now, "/fapi/v1/order", "key1", header, http.StatusOK)
+
+	if used := l.ipBucket("fapi").windows[time.Minute].used; 1200 != used {
+		t.Fatalf("权重用量错误：%d", used)
+	}
+
+	if used := l.orderBucket("key1").windows[10*time.Second].used; 150 != used {
+		t.Fatalf("下单数用量错误：%d", used)
+	}
+
+	if wait := l.reserve(now, "fapi", 1, "", false); 59*time.Second != wait {
+		t.Fatalf("权重超过预算等待时间错误：%v", wait)
+	}
+
+	// 429按Retry-After暂停，新窗口也不能请求
+	header = http.Header{}
+	header.Set("Retry-After", "90")
+	l.update(now, "/fapi/v1/order", "key1", header, http.StatusTooManyRequests)
+	if wait := l.reserve(now+60000, "fapi", 1, "", false); 30*time.Second != wait {
+		t.Fatalf("429后等待时间错误：%v", wait)
+	}
+
+	// 未返回Retry-After按默认时间
+	l = newTestLimiter(0.5)
+	l.update(now, "/fapi/v1/order", "", http.Header{}, http.StatusTeapot)
+	if wait := l.reserve(now, "fapi", 1, "", false); limitBanDefault != wait {
+		t.Fatalf("418后等待时间错误：%v", wait)
+	}
+}
+
+func TestLimiterUsageMaskApiKey(t *testing.T) {
+	l := newTestLimiter(0.8)
+	l.reserve(60000, "fapi", 1, "abcdefgh1234", true)
+
+	for _, v := range l.usage(60000) {
+		if limitTypeOrder == v.Type && "****1234" != v.Key {
+			t.Errorf("API Key未脱敏：%s", v.Key)
+		}
+	}
+
+	if "****" != maskApiKey("abc") {
+		t.Errorf("短API Key脱敏错误：%s", maskApiKey("abc"))
+	}
+}
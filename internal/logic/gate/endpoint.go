@@ -0,0 +1,42 @@
+package gate
+
+import (
+	"github.com/gogf/gf/v2/os/gctx"
+	"log"
+	"plat_order/utility"
+)
+
+const (
+	envProduction = "production"
+	envTestnet    = "testnet"
+	envCustom     = "custom"
+)
+
+var basePaths = map[string]string{
+	envProduction: "https://api.gateio.ws/api/v4",
+	envTestnet:    "https://fx-api-testnet.gateio.ws/api/v4",
+}
+
+// configBasePath 读取配置exchange.gate，env为production、testnet或custom，配置的url覆盖环境默认值
+func configBasePath() string {
+	var (
+		ctx = gctx.GetInitCtx()
+		env = utility.ConfigString(ctx, "exchange.gate.env", envProduction)
+		res = basePaths[envProduction]
+	)
+	if preset, ok := basePaths[env]; ok {
+		res = preset
+	} else if envCustom != env {
+		log.Println("gate环境配置错误，使用生产环境：", env)
+	}
+
+	if v := utility.ConfigString(ctx, "exchange.gate.url", ""); 0 < len(v) {
+		res = v
+	}
+
+	if envProduction != env {
+		log.Println("gate接口地址：", env, res)
+	}
+
+	return res
+}
@@ -0,0 +1,122 @@
+package gate
+
+import (
+	"context"
+	"errors"
+	"github.com/antihax/optional"
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"log"
+	"math"
+	"plat_order/internal/model/entity"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fillPollTimes    = 5                      // 订单未结束时查询次数
+	fillPollInterval = 200 * time.Millisecond // 查询间隔
+	textQueryLimit   = 100                    // 按text查询时查找的最近订单数
+)
+
+// gateFill 订单转为成交结果，张数为正数
+func gateFill(order gateapi.FuturesOrder) *entity.OrderFill {
+	avgPrice, _ := strconv.ParseFloat(order.FillPrice, 64)
+
+	return &entity.OrderFill{
+		Plat:        "gate",
+		OrderId:     strconv.FormatInt(order.Id, 10),
+		Symbol:      strings.ReplaceAll(order.Contract, "_", ""),
+		Status:      order.Status + "_" + order.FinishAs,
+		Final:       "finished" == order.Status,
+		ExecutedQty: math.Abs(float64(order.Size - order.Left)),
+		AvgPrice:    avgPrice,
+	}
+}
+
+// GetOrderGate 查询订单
+func (s *sGate) GetOrderGate(apiK, apiS string, orderId int64) (gateapi.FuturesOrder, error) {
+	client := s.newClient()
+	ctx := context.WithValue(context.Background(),
+		gateapi.ContextGateAPIV4,
+		gateapi.GateAPIV4{
+			Key:    apiK,
+			Secret: apiS,
+		},
+	)
+
+	result, _, err := client.FuturesApi.GetFuturesOrder(ctx, "usdt", strconv.FormatInt(orderId, 10))
+	if err != nil {
+		var e gateapi.GateAPIError
+		if errors.As(err, &e) {
+			log.Println("gate api error: ", e.Error())
+		}
+
+		return result, err
+	}
+
+	return result, nil
+}
+
+// GetOrderGateByText 按text查询最近的订单，未找到时返回的订单id为0。
+// 按text只能查询挂单中的订单，已结束的订单从最近订单中查找
+func (s *sGate) GetOrderGateByText(apiK, apiS, contract, text string) (gateapi.FuturesOrder, error) {
+	client := s.newClient()
+	ctx := context.WithValue(context.Background(),
+		gateapi.ContextGateAPIV4,
+		gateapi.GateAPIV4{
+			Key:    apiK,
+			Secret: apiS,
+		},
+	)
+
+	for _, status := range []string{"open", "finished"} {
+		result, _, err := client.FuturesApi.ListFuturesOrders(ctx, "usdt", status, &gateapi.ListFuturesOrdersOpts{
+			Contract: optional.NewString(contract),
+			Limit:    optional.NewInt32(textQueryLimit),
+		})
+		if err != nil {
+			var e gateapi.GateAPIError
+			if errors.As(err, &e) {
+				log.Println("gate api error: ", e.Error())
+			}
+
+			return gateapi.FuturesOrder{}, err
+		}
+
+		for _, v := range result {
+			if text == v.Text {
+				return v, nil
+			}
+		}
+	}
+
+	return gateapi.FuturesOrder{}, nil
+}
+
+// GateOrderFill 下单结果转为成交结果，订单未结束时查询订单状态，查询后仍未结束返回已成交部分和错误
+func (s *sGate) GateOrderFill(apiK, apiS string, order gateapi.FuturesOrder) (*entity.OrderFill, error) {
+	if 0 >= order.Id {
+		return nil, gerror.New("invalid order")
+	}
+
+	res := gateFill(order)
+	for i := 0; i < fillPollTimes && !res.Final; i++ {
+		time.Sleep(fillPollInterval)
+
+		o, err := s.GetOrderGate(apiK, apiS, order.Id)
+		if nil != err {
+			log.Println("查询gate订单错误：", order.Contract, order.Id, err)
+			continue
+		}
+
+		res = gateFill(o)
+	}
+
+	if !res.Final {
+		return res, gerror.Newf("order %d is not final: %s", order.Id, res.Status)
+	}
+
+	return res, nil
+}
@@ -7,25 +7,44 @@ import (
 	"github.com/gateio/gateapi-go/v6"
 	"log"
 	"plat_order/internal/service"
+	"strings"
+	"time"
 )
 
+const orderTimeout = 3 * time.Second // 下单请求超时
+
 type (
-	sGate struct{}
+	sGate struct {
+		basePath string // 接口地址
+	}
 )
 
 func init() {
 	service.RegisterGate(New())
 }
 
+// New 按配置的环境选择接口地址
 func New() *sGate {
-	return &sGate{}
+	return NewWithBasePath(configBasePath())
+}
+
+// NewWithBasePath 指定接口地址，用于测试网或本地模拟服务
+func NewWithBasePath(basePath string) *sGate {
+	return &sGate{
+		basePath: strings.TrimSuffix(basePath, "/"),
+	}
+}
+
+// newClient 使用配置地址的客户端
+func (s *sGate) newClient() *gateapi.APIClient {
+	client := gateapi.NewAPIClient(gateapi.NewConfiguration())
+	client.ChangeBasePath(s.basePath)
+	return client
 }
 
 // GetGateContract 获取合约账号信息
 func (s *sGate) GetGateContract(apiK, apiS string) (gateapi.FuturesAccount, error) {
-	client := gateapi.NewAPIClient(gateapi.NewConfiguration())
-	// uncomment the next line if your are testing against testnet
-	// client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
+	client := s.newClient()
 	ctx := context.WithValue(context.Background(),
 		gateapi.ContextGateAPIV4,
 		gateapi.GateAPIV4{
@@ -48,9 +67,7 @@ func (s *sGate) GetGateContract(apiK, apiS string) (gateapi.FuturesAccount, erro
 
 // GetListPositions 获取合约账号信息
 func (s *sGate) GetListPositions(apiK, apiS string) ([]gateapi.Position, error) {
-	client := gateapi.NewAPIClient(gateapi.NewConfiguration())
-	// uncomment the next line if your are testing against testnet
-	// client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
+	client := s.newClient()
 	ctx := context.WithValue(context.Background(),
 		gateapi.ContextGateAPIV4,
 		gateapi.GateAPIV4{
@@ -74,12 +91,13 @@ func (s *sGate) GetListPositions(apiK, apiS string) ([]gateapi.Position, error)
 	return result, nil
 }
 
-// PlaceOrderGate places an order on the Gate.io API with dynamic parameters
-func (s *sGate) PlaceOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, autoSize string) (gateapi.FuturesOrder, error) {
-	client := gateapi.NewAPIClient(gateapi.NewConfiguration())
-	// uncomment the next line if your are testing against testnet
-	// client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
-	ctx := context.WithValue(context.Background(),
+// PlaceOrderGate places an order on the Gate.io API with dynamic parameters, text is the user defined order id prefixed with t-
+func (s *sGate) PlaceOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, autoSize string, text string) (gateapi.FuturesOrder, error) {
+	client := s.newClient()
+	ctx, cancel := context.WithTimeout(context.Background(), orderTimeout)
+	defer cancel()
+
+	ctx = context.WithValue(ctx,
 		gateapi.ContextGateAPIV4,
 		gateapi.GateAPIV4{
 			Key:    apiK,
@@ -103,25 +121,32 @@ func (s *sGate) PlaceOrderGate(apiK, apiS, contract string, size int64, reduceOn
 		order.ReduceOnly = reduceOnly
 	}
 
+	if text != "" {
+		order.Text = text
+	}
+
 	result, _, err := client.FuturesApi.CreateFuturesOrder(ctx, "usdt", order)
 
 	if err != nil {
 		var e gateapi.GateAPIError
 		if errors.As(err, &e) {
 			log.Println("gate api error: ", e.Error())
-			return result, err
 		}
+
+		// 非接口错误时订单状态未知，由调用方按text查询
+		return result, err
 	}
 
 	return result, nil
 }
 
-// PlaceBothOrderGate places an order on the Gate.io API with dynamic parameters
-func (s *sGate) PlaceBothOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, close bool) (gateapi.FuturesOrder, error) {
-	client := gateapi.NewAPIClient(gateapi.NewConfiguration())
-	// uncomment the next line if your are testing against testnet
-	// client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
-	ctx := context.WithValue(context.Background(),
+// PlaceBothOrderGate places an order on the Gate.io API with dynamic parameters, text is the user defined order id prefixed with t-
+func (s *sGate) PlaceBothOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, close bool, text string) (gateapi.FuturesOrder, error) {
+	client := s.newClient()
+	ctx, cancel := context.WithTimeout(context.Background(), orderTimeout)
+	defer cancel()
+
+	ctx = context.WithValue(ctx,
 		gateapi.ContextGateAPIV4,
 		gateapi.GateAPIV4{
 			Key:    apiK,
@@ -145,14 +170,20 @@ func (s *sGate) PlaceBothOrderGate(apiK, apiS, contract string, size int64, redu
 		order.ReduceOnly = reduceOnly
 	}
 
+	if text != "" {
+		order.Text = text
+	}
+
 	result, _, err := client.FuturesApi.CreateFuturesOrder(ctx, "usdt", order)
 
 	if err != nil {
 		var e gateapi.GateAPIError
 		if errors.As(err, &e) {
 			log.Println("gate api error: ", e.Error())
-			return result, err
 		}
+
+		// 非接口错误时订单状态未知，由调用方按text查询
+		return result, err
 	}
 
 	return result, nil
@@ -160,9 +191,7 @@ func (s *sGate) PlaceBothOrderGate(apiK, apiS, contract string, size int64, redu
 
 // SetDual setDual
 func (s *sGate) SetDual(apiK, apiS string, dual bool) (bool, error) {
-	client := gateapi.NewAPIClient(gateapi.NewConfiguration())
-	// uncomment the next line if your are testing against testnet
-	// client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
+	client := s.newClient()
 	ctx := context.WithValue(context.Background(),
 		gateapi.ContextGateAPIV4,
 		gateapi.GateAPIV4{
@@ -186,3 +215,20 @@ func (s *sGate) SetDual(apiK, apiS string, dual bool) (bool, error) {
 
 	return result.InDualMode, nil
 }
+
+// ListGateContracts 获取usdt永续合约列表
+func (s *sGate) ListGateContracts() ([]gateapi.Contract, error) {
+	client := s.newClient()
+
+	result, _, err := client.FuturesApi.ListFuturesContracts(context.Background(), "usdt", nil)
+	if err != nil {
+		var e gateapi.GateAPIError
+		if errors.As(err, &e) {
+			log.Println("gate api error: ", e.Error())
+		}
+
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,205 @@
+package listenandorder
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gfile"
+	"math"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// backtestUser 回测中用户的统计
+type backtestUser struct {
+	user    *entity.User
+	ratio   float64                     // 用户保证金*系数/交易员保证金
+	ideal   map[string]map[uint]float64 // symbol&positionSide => 交易员 => 理想仓位
+	res     *entity.BacktestUserResult
+	squares float64
+}
+
+// Backtest 用模拟交易所回放交易员推送和标记价格，用户按OrderAtPlat同样的逻辑下单，统计跟踪误差、手续费和取整损失
+func (s *sListenAndOrder) Backtest(ctx context.Context, req *entity.BacktestReq) (*entity.BacktestResult, error) {
+	if lessThanOrEqualZero(req.TraderMoney, 1e-7) {
+		return nil, gerror.New("交易员保证金必须大于0")
+	}
+
+	if 0 >= len(req.Users) {
+		return nil, gerror.New("缺少模拟用户")
+	}
+
+	records, err := readJournal(req.Journal)
+	if nil != err {
+		return nil, err
+	}
+
+	prices, err := readBacktestPrices(req.Prices)
+	if nil != err {
+		return nil, err
+	}
+
+	var (
+		r     = newOffline()
+		sim   = service.SimExchange()
+		res   = &entity.BacktestResult{Users: make([]*entity.BacktestUserResult, 0, len(req.Users))}
+		users = make(map[int]*backtestUser, len(req.Users))
+	)
+	sim.SetFeeRate(req.FeeRate)
+
+	for _, v := range req.Symbols {
+		r.offlineSymbol(v.Symbol, v.QuantityPrecision, v.QuantoMultiplier)
+	}
+
+	for i, v := range req.Users {
+		if "binance" != v.Plat && "gate" != v.Plat {
+			return nil, gerror.Newf("不支持的平台：%s", v.Plat)
+		}
+
+		if lessThanOrEqualZero(v.Money, 1e-7) || lessThanOrEqualZero(v.Num, 1e-7) {
+			return nil, gerror.Newf("用户保证金和系数必须大于0：%+v", v)
+		}
+
+		userId := i + 1
+		users[userId] = &backtestUser{
+			user:  r.offlineUser(userId, v.Plat, v.Money*v.Num),
+			ratio: v.Money * v.Num / req.TraderMoney,
+			ideal: make(map[string]map[uint]float64, 0),
+			res: &entity.BacktestUserResult{
+				Plat:  v.Plat,
+				Money: v.Money,
+				Num:   v.Num,
+			},
+		}
+	}
+
+	r.dispatch = func(userId int, msg *entity.OrderInfo) {
+		u := users[userId]
+		if nil == u {
+			return
+		}
+
+		var (
+			key       = msg.Symbol + "&" + msg.PositionSide
+			before    = sim.GetPositions(u.user.ApiKey)[key]
+			idealLast = u.idealAmount(key)
+		)
+
+		r.OrderAtPlat(ctx, &entity.DoValue{UserId: userId, Value: msg})
+
+		if _, ok := u.ideal[key]; !ok {
+			u.ideal[key] = make(map[uint]float64, 0)
+		}
+		u.ideal[key][msg.TraderId] = msg.Amount * u.ratio
+
+		var (
+			after        = sim.GetPositions(u.user.ApiKey)[key]
+			idealCurrent = u.idealAmount(key)
+			price, _     = strconv.ParseFloat(sim.GetLatestPrice(msg.Symbol), 64)
+			gap          = math.Abs(after-idealCurrent) * price
+		)
+
+		u.res.Signals++
+		if floatEqual(after, before, 1e-12) && !floatEqual(idealCurrent, idealLast, 1e-12) {
+			u.res.Skipped++
+		}
+
+		u.res.RoundingLoss += math.Abs((idealCurrent-idealLast)-(after-before)) * price
+		u.squares += gap * gap
+		u.res.MaxTrackingError = math.Max(u.res.MaxTrackingError, gap)
+	}
+
+	// 没有标记价格时，使用推送中的成交价
+	if 0 >= len(prices) {
+		r.seedJournalPrices(records, req.QuantityPrecision, req.QuantoMultiplier)
+	}
+
+	var next int
+	for _, record := range records {
+		if journalKindSignal == record.Kind {
+			res.Signals++
+			continue
+		}
+
+		if journalKindEvent != record.Kind {
+			continue
+		}
+
+		// 推送前的标记价格
+		for ; next < len(prices) && prices[next].Time <= record.Time; next++ {
+			sim.SetPrice(prices[next].Symbol, prices[next].Price)
+		}
+
+		trader := r.offlineTrader(record.TraderId, req.TraderMoney)
+		for userId := range users {
+			r.UsersTraders.Get(userId).(map[uint]float64)[trader.Id] = 1
+		}
+
+		symbol, price := r.replaySymbol(record.Event, req.QuantityPrecision, req.QuantoMultiplier)
+		if 0 >= len(prices) && 0 < price {
+			sim.SetPrice(symbol, price)
+		}
+
+		res.Events++
+		r.dispatchStreamMessage(ctx, trader, record.Event, 0)
+	}
+
+	for userId := 1; userId <= len(req.Users); userId++ {
+		u := users[userId]
+		u.res.Fees, u.res.Fills = sim.GetFees(u.user.ApiKey)
+		u.res.Positions = sim.GetPositions(u.user.ApiKey)
+		if 0 < u.res.Signals {
+			u.res.TrackingError = math.Sqrt(u.squares / float64(u.res.Signals))
+		}
+		u.res.TrackingErrorPct = u.res.TrackingError / (u.res.Money * u.res.Num)
+
+		res.Users = append(res.Users, u.res)
+	}
+
+	return res, nil
+}
+
+// idealAmount 按比例跟单全部交易员时的理想仓位
+func (u *backtestUser) idealAmount(key string) float64 {
+	var amount float64
+	for _, v := range u.ideal[key] {
+		amount += v
+	}
+
+	return amount
+}
+
+// readBacktestPrices 读取标记价格并按时间排序，文件为空时返回空
+func readBacktestPrices(path string) ([]*entity.BacktestPrice, error) {
+	res := make([]*entity.BacktestPrice, 0)
+	if 0 >= len(path) {
+		return res, nil
+	}
+
+	if !gfile.Exists(path) {
+		return nil, gerror.Newf("价格文件不存在：%s", path)
+	}
+
+	for _, line := range strings.Split(gfile.GetContents(path), "\n") {
+		line = strings.TrimSpace(line)
+		if 0 >= len(line) {
+			continue
+		}
+
+		var price *entity.BacktestPrice
+		if err := json.Unmarshal([]byte(line), &price); nil != err || nil == price {
+			return nil, gerror.Newf("价格文件解析错误：%s %v", line, err)
+		}
+
+		res = append(res, price)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Time < res[j].Time
+	})
+
+	return res, nil
+}
@@ -0,0 +1,79 @@
+package listenandorder
+
+import (
+	"fmt"
+	"hash/fnv"
+	"plat_order/internal/model/entity"
+	"strconv"
+	"time"
+)
+
+const (
+	clientIdMaxLen = 25   // 币安newClientOrderId最长36，gate text去掉t-前缀最长28，预留拆分序号
+	gateTextPrefix = "t-" // gate自定义订单id前缀
+)
+
+// 非信号下单的clientOrderId前缀
+const (
+	clientIdReconcile = "r" // 仓位校正
+	clientIdManual    = "m" // 自定义下单
+	clientIdInit      = "i" // 初始化跟单仓位
+	clientIdClose     = "c" // 全部平仓
+)
+
+// signalClientId 跟单订单的确定性标识，同一信号同一用户得到相同的标识，
+// 格式为交易员id-交易员订单id-用户id-成交腿，数字为36进制，可追溯到触发的交易员成交，
+// 成交腿为交易对、成交id、持仓方向和开平的哈希。超长时截去交易员订单id的高位，保留低位。
+// 校正信号没有来源订单，每次生成新的标识
+func signalClientId(userId int, msg *entity.OrderInfo) string {
+	if 0 >= msg.OrderId {
+		return uniqueClientId(clientIdReconcile, userId)
+	}
+
+	leg := fnv.New32a()
+	_, _ = leg.Write([]byte(fmt.Sprintf("%s_%d_%s_%s", msg.Symbol, msg.TradeId, msg.PositionSide, msg.Status)))
+
+	var (
+		trader = strconv.FormatUint(uint64(msg.TraderId), 36)
+		order  = strconv.FormatInt(msg.OrderId, 36)
+		user   = strconv.FormatInt(int64(userId), 36)
+		hash   = strconv.FormatUint(uint64(leg.Sum32()%1679616), 36) // 最多4位36进制
+	)
+	if over := len(trader) + len(order) + len(user) + len(hash) + 3 - clientIdMaxLen; 0 < over {
+		if over >= len(order) {
+			over = len(order) - 1
+		}
+		order = order[over:]
+	}
+
+	// 交易员id和用户id本身过长时直接截断，保证不超过交易所限制
+	res := fmt.Sprintf("%s-%s-%s-%s", trader, order, user, hash)
+	if clientIdMaxLen < len(res) {
+		res = res[:clientIdMaxLen]
+	}
+
+	return res
+}
+
+// uniqueClientId 非信号下单的标识，超时后同样可以按标识查询
+func uniqueClientId(prefix string, userId int) string {
+	return fmt.Sprintf("%s-%s-%s", prefix, strconv.FormatInt(int64(userId), 36), strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+// legClientId 拆分下单时每笔的标识，第一笔不变
+func legClientId(clientId string, leg int) string {
+	if 0 >= len(clientId) || 0 == leg {
+		return clientId
+	}
+
+	return fmt.Sprintf("%s.%d", clientId, leg)
+}
+
+// gateText gate订单的text字段
+func gateText(clientId string) string {
+	if 0 >= len(clientId) {
+		return ""
+	}
+
+	return gateTextPrefix + clientId
+}
@@ -0,0 +1,99 @@
+package listenandorder
+
+import (
+	"plat_order/internal/model/entity"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	binanceClientIdMaxLen = 36 // 币安newClientOrderId最长36
+	gateTextMaxLen        = 30 // gate text含t-前缀最长30
+)
+
+func TestSignalClientId(t *testing.T) {
+	tests := []struct {
+		name      string
+		userId    int
+		msg       *entity.OrderInfo
+		truncated bool
+	}{
+		{name: "常规订单", userId: 1, msg: &entity.OrderInfo{Symbol: "BTCUSDT", TraderId: 7, OrderId: 8389765478, TradeId: 201, PositionSide: "LONG", Status: "OPEN"}},
+		{name: "订单id最大", userId: 123456, msg: &entity.OrderInfo{Symbol: "BTCUSDT", TraderId: 4321, OrderId: 9223372036854775807, TradeId: 1, PositionSide: "SHORT", Status: "CLOSE"}, truncated: true},
+	}
+
+	for _, tt := range tests {
+		id := signalClientId(tt.userId, tt.msg)
+		if id != signalClientId(tt.userId, tt.msg) {
+			t.Errorf("%s: 同一信号标识不一致", tt.name)
+		}
+
+		if clientIdMaxLen < len(id) {
+			t.Errorf("%s: 标识超长：%s", tt.name, id)
+		}
+
+		// 交易员id-交易员订单id-用户id-成交腿
+		parts := strings.Split(id, "-")
+		if 4 != len(parts) {
+			t.Fatalf("%s: 标识格式错误：%s", tt.name, id)
+		}
+
+		if strconv.FormatUint(uint64(tt.msg.TraderId), 36) != parts[0] || strconv.FormatInt(int64(tt.userId), 36) != parts[2] {
+			t.Errorf("%s: 标识不可追溯到交易员和用户：%s", tt.name, id)
+		}
+
+		// 超长时只截去订单id高位
+		order := strconv.FormatInt(tt.msg.OrderId, 36)
+		if tt.truncated != (order != parts[1]) || !strings.HasSuffix(order, parts[1]) {
+			t.Errorf("%s: 订单id部分错误：%s，订单id%s", tt.name, parts[1], order)
+		}
+
+		if 0 >= len(parts[3]) || 4 < len(parts[3]) {
+			t.Errorf("%s: 成交腿部分错误：%s", tt.name, parts[3])
+		}
+
+		// 拆分下单和gate text不超过交易所限制
+		if binanceClientIdMaxLen < len(legClientId(id, 99)) || gateTextMaxLen < len(gateText(legClientId(id, 99))) {
+			t.Errorf("%s: 拆分标识超长：%s", tt.name, legClientId(id, 99))
+		}
+	}
+
+	// 同一交易员订单的多空两条信号标识不同
+	long := &entity.OrderInfo{Symbol: "BTCUSDT", TraderId: 7, OrderId: 100, TradeId: 1, PositionSide: "LONG", Status: "CLOSE"}
+	short := &entity.OrderInfo{Symbol: "BTCUSDT", TraderId: 7, OrderId: 100, TradeId: 1, PositionSide: "SHORT", Status: "OPEN"}
+	if signalClientId(1, long) == signalClientId(1, short) {
+		t.Errorf("反手两条信号标识相同：%s", signalClientId(1, long))
+	}
+
+	// 校正信号每次生成新的标识
+	reconcile := &entity.OrderInfo{Symbol: "BTCUSDT", TraderId: 7, PositionSide: "LONG", Status: "OPEN"}
+	first := signalClientId(1, reconcile)
+	if !strings.HasPrefix(first, clientIdReconcile+"-") || first == signalClientId(1, reconcile) {
+		t.Errorf("校正信号标识错误：%s", first)
+	}
+}
+
+func TestLegClientId(t *testing.T) {
+	tests := []struct {
+		clientId string
+		leg      int
+		want     string
+		wantText string
+	}{
+		{clientId: "7-abc-1-zz", leg: 0, want: "7-abc-1-zz", wantText: "t-7-abc-1-zz"},
+		{clientId: "7-abc-1-zz", leg: 2, want: "7-abc-1-zz.2", wantText: "t-7-abc-1-zz.2"},
+		{clientId: "", leg: 1, want: "", wantText: ""},
+	}
+
+	for _, tt := range tests {
+		res := legClientId(tt.clientId, tt.leg)
+		if tt.want != res {
+			t.Errorf("legClientId(%q, %d) = %q，期望%q", tt.clientId, tt.leg, res, tt.want)
+		}
+
+		if text := gateText(res); tt.wantText != text {
+			t.Errorf("gateText(%q) = %q，期望%q", res, text, tt.wantText)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package listenandorder
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/os/gtimer"
+	"github.com/shopspring/decimal"
+	"log"
+	"plat_order/internal/model/entity"
+	"time"
+)
+
+// limitFill 合并中的限价单成交
+type limitFill struct {
+	event *entity.OrderTradeUpdate // 最后一笔成交，成交数量为合并后的数量
+	qty   decimal.Decimal
+	entry *gtimer.Entry
+}
+
+// coalesceLimitFill 合并限价单成交，窗口结束或订单完全成交时按合并后的数量跟单
+func (s *sListenAndOrder) coalesceLimitFill(ctx context.Context, trader *Trader, event *entity.OrderTradeUpdate, window time.Duration) {
+	qty, err := decimal.NewFromString(event.Order.LastExecutedQty)
+	if nil != err {
+		log.Println("合并成交，解析数量出错，信息", event)
+		return
+	}
+
+	orderId := event.Order.OrderID
+
+	trader.fillMu.Lock()
+	fill, ok := trader.fills[orderId]
+	if !ok {
+		fill = &limitFill{qty: decimal.Zero}
+		fill.entry = gtimer.AddOnce(ctx, window, func(ctx context.Context) {
+			s.flushLimitFill(trader, orderId)
+		})
+		trader.fills[orderId] = fill
+	}
+
+	fill.qty = fill.qty.Add(qty)
+	merged := *event
+	merged.Order.LastExecutedQty = fill.qty.String()
+	fill.event = &merged
+	trader.fillMu.Unlock()
+
+	if "FILLED" == event.Order.OrderStatus {
+		s.flushLimitFill(trader, orderId)
+	}
+}
+
+// flushLimitFill 按合并后的成交跟单
+func (s *sListenAndOrder) flushLimitFill(trader *Trader, orderId int64) {
+	trader.fillMu.Lock()
+	fill, ok := trader.fills[orderId]
+	if ok {
+		delete(trader.fills, orderId)
+	}
+	trader.fillMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	fill.entry.Close()
+	log.Println("限价，合并成交：", trader.Id, fill.event)
+	s.handleTraderOrder(trader, fill.event)
+}
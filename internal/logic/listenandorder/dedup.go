@@ -0,0 +1,176 @@
+package listenandorder
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/os/gtimer"
+	"log"
+	"plat_order/internal/model/entity"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	signalDedupSize  = 20000            // 默认去重窗口大小
+	signalDedupFlush = 10 * time.Second // 持久化间隔
+)
+
+// signalDedup 有界去重窗口，超过容量淘汰最早的记录，可选持久化到文件
+type signalDedup struct {
+	mu    sync.Mutex
+	keys  map[string]struct{}
+	ring  []string
+	next  int
+	file  string
+	dirty bool
+}
+
+func newSignalDedup(size int) *signalDedup {
+	if 0 >= size {
+		size = signalDedupSize
+	}
+
+	return &signalDedup{
+		keys: make(map[string]struct{}, size),
+		ring: make([]string, size),
+	}
+}
+
+// Add 记录key，已存在返回false
+func (d *signalDedup) Add(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.keys[key]; ok {
+		return false
+	}
+
+	if old := d.ring[d.next]; "" != old {
+		delete(d.keys, old)
+	}
+
+	d.ring[d.next] = key
+	d.next = (d.next + 1) % len(d.ring)
+	d.keys[key] = struct{}{}
+	d.dirty = true
+
+	return true
+}
+
+// Has 是否已记录key
+func (d *signalDedup) Has(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.keys[key]
+	return ok
+}
+
+// snapshot 由旧到新的全部key
+func (d *signalDedup) snapshot() []string {
+	res := make([]string, 0, len(d.keys))
+	for i := 0; i < len(d.ring); i++ {
+		if key := d.ring[(d.next+i)%len(d.ring)]; "" != key {
+			res = append(res, key)
+		}
+	}
+
+	return res
+}
+
+// load 从文件恢复去重记录
+func (d *signalDedup) load(file string) {
+	d.mu.Lock()
+	d.file = file
+	d.mu.Unlock()
+
+	if !gfile.Exists(file) {
+		return
+	}
+
+	for _, key := range strings.Split(gfile.GetContents(file), "\n") {
+		if 0 < len(key) {
+			d.Add(key)
+		}
+	}
+
+	d.mu.Lock()
+	total := len(d.keys)
+	d.mu.Unlock()
+
+	log.Println("去重记录恢复：", file, total)
+}
+
+// flush 有新记录时写入文件
+func (d *signalDedup) flush() {
+	d.mu.Lock()
+	if 0 >= len(d.file) || !d.dirty {
+		d.mu.Unlock()
+		return
+	}
+
+	file := d.file
+	contents := strings.Join(d.snapshot(), "\n")
+	d.dirty = false
+	d.mu.Unlock()
+
+	err := gfile.PutContents(file, contents)
+	if nil != err {
+		log.Println("去重记录写入文件错误：", file, err)
+	}
+}
+
+// signalDedupKey 交易员订单的某次成交，市价单NEW推送无成交id
+func signalDedupKey(traderId uint, symbol string, orderId, tradeId int64, executionType string) string {
+	return fmt.Sprintf("%d_%s_%d_%d_%s", traderId, symbol, orderId, tradeId, executionType)
+}
+
+// userSignalDedupKey 用户执行的某条信号，同一成交可能拆分为多空两条。
+// 下单成功或按clientOrderId查询确认已下单后才记录
+func userSignalDedupKey(userId int, msg *entity.OrderInfo) string {
+	return fmt.Sprintf("%d_%d_%s_%d_%d_%s_%s", userId, msg.TraderId, msg.Symbol, msg.OrderId, msg.TradeId, msg.PositionSide, msg.Status)
+}
+
+// addUserSignalDedup 用户信号已下单，记录去重，校正信号key为空不记录
+func (s *sListenAndOrder) addUserSignalDedup(key string) {
+	if 0 >= len(key) {
+		return
+	}
+
+	s.UsersSignalDedup.Add(key)
+}
+
+// InitSignalDedup 读取去重配置，恢复持久化记录并定时写入
+func (s *sListenAndOrder) InitSignalDedup(ctx context.Context) {
+	size := g.Cfg().MustGet(ctx, "dedup.size", signalDedupSize).Int()
+	if signalDedupSize != size {
+		s.SignalDedup = newSignalDedup(size)
+		s.UsersSignalDedup = newSignalDedup(size)
+		s.UsersSignalTried = newSignalDedup(size)
+	}
+
+	file := g.Cfg().MustGet(ctx, "dedup.file").String()
+	if 0 < len(file) {
+		s.SignalDedup.load(file)
+	}
+
+	// 已开始执行的信号和用户执行去重一起持久化，重启后再次投递的信号仍先查询
+	userFile := g.Cfg().MustGet(ctx, "dedup.userFile").String()
+	if 0 < len(userFile) {
+		s.UsersSignalDedup.load(userFile)
+		s.UsersSignalTried.load(userFile + ".tried")
+	}
+
+	if 0 >= len(file) && 0 >= len(userFile) {
+		return
+	}
+
+	gtimer.AddSingleton(ctx, signalDedupFlush, func(ctx context.Context) {
+		s.SignalDedup.flush()
+		s.UsersSignalDedup.flush()
+		s.UsersSignalTried.flush()
+	})
+}
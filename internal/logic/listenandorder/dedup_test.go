@@ -0,0 +1,115 @@
+package listenandorder
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"path/filepath"
+	"plat_order/internal/model/entity"
+	"testing"
+)
+
+func TestSignalDedupKey(t *testing.T) {
+	key := signalDedupKey(1, "BTCUSDT", 100, 200, "TRADE")
+	if key != signalDedupKey(1, "BTCUSDT", 100, 200, "TRADE") {
+		t.Errorf("同一成交key不一致")
+	}
+
+	others := []string{
+		signalDedupKey(2, "BTCUSDT", 100, 200, "TRADE"),
+		signalDedupKey(1, "ETHUSDT", 100, 200, "TRADE"),
+		signalDedupKey(1, "BTCUSDT", 101, 200, "TRADE"),
+		signalDedupKey(1, "BTCUSDT", 100, 201, "TRADE"),
+		signalDedupKey(1, "BTCUSDT", 100, 200, "NEW"),
+	}
+	for _, v := range others {
+		if key == v {
+			t.Errorf("不同成交key相同：%s", v)
+		}
+	}
+}
+
+func TestUserSignalDedupKey(t *testing.T) {
+	msg := &entity.OrderInfo{Symbol: "BTCUSDT", TraderId: 1, OrderId: 100, TradeId: 200, PositionSide: "LONG", Status: "OPEN"}
+	key := userSignalDedupKey(7, msg)
+
+	tmpMsg := *msg
+	if key != userSignalDedupKey(7, &tmpMsg) {
+		t.Errorf("同一信号key不一致")
+	}
+
+	changes := []func(m *entity.OrderInfo){
+		func(m *entity.OrderInfo) { m.Symbol = "ETHUSDT" },
+		func(m *entity.OrderInfo) { m.TraderId = 2 },
+		func(m *entity.OrderInfo) { m.OrderId = 101 },
+		func(m *entity.OrderInfo) { m.TradeId = 201 },
+		func(m *entity.OrderInfo) { m.PositionSide = "SHORT" },
+		func(m *entity.OrderInfo) { m.Status = "CLOSE" },
+	}
+	for i, change := range changes {
+		tmpMsg = *msg
+		change(&tmpMsg)
+		if key == userSignalDedupKey(7, &tmpMsg) {
+			t.Errorf("第%d个字段变化后key相同", i)
+		}
+	}
+
+	if key == userSignalDedupKey(8, msg) {
+		t.Errorf("不同用户key相同")
+	}
+}
+
+func TestSignalDedup(t *testing.T) {
+	d := newSignalDedup(2)
+	if !d.Add("a") || d.Add("a") {
+		t.Fatalf("重复key应返回false")
+	}
+
+	if !d.Has("a") || d.Has("b") {
+		t.Fatalf("Has结果错误")
+	}
+
+	// 超过容量淘汰最早的记录
+	d.Add("b")
+	d.Add("c")
+	if d.Has("a") || !d.Has("b") || !d.Has("c") {
+		t.Errorf("淘汰结果错误：%v", d.snapshot())
+	}
+
+	// 持久化后恢复
+	file := filepath.Join(t.TempDir(), "dedup")
+	d.file = file
+	d.flush()
+
+	loaded := newSignalDedup(2)
+	loaded.load(file)
+	if !loaded.Has("b") || !loaded.Has("c") || loaded.Has("a") {
+		t.Errorf("恢复结果错误：%v", loaded.snapshot())
+	}
+}
+
+// TestInitSignalDedupTried 已开始执行的信号随用户去重文件持久化，重启后再次投递仍先查询
+func TestInitSignalDedupTried(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		userFile = filepath.Join(t.TempDir(), "userDedup")
+	)
+
+	adapter, err := gcfg.NewAdapterContent(fmt.Sprintf(`{"dedup":{"userFile":%q}}`, userFile))
+	if nil != err {
+		t.Fatalf("配置错误：%v", err)
+	}
+	g.Cfg().SetAdapter(adapter)
+
+	s := New()
+	s.InitSignalDedup(ctx)
+	s.UsersSignalTried.Add("tried")
+	s.UsersSignalTried.flush()
+
+	restarted := New()
+	restarted.InitSignalDedup(ctx)
+	if !restarted.UsersSignalTried.Has("tried") {
+		t.Errorf("重启后未恢复已开始执行的信号：%v", restarted.UsersSignalTried.snapshot())
+	}
+}
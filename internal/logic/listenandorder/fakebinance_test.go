@@ -0,0 +1,169 @@
+package listenandorder
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"plat_order/internal/logic/binance"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"plat_order/utility/fakebinance"
+	"testing"
+	"time"
+)
+
+const (
+	fakeTraderId = uint(7)
+	fakeUserId   = 1
+)
+
+// newFakeFollow 启动模拟服务，开通交易员和用户账户，返回跟单用户已加载的实例
+func newFakeFollow(t *testing.T) (*sListenAndOrder, *fakebinance.Server) {
+	// 不读取配置文件，逐笔跟单
+	adapter, err := gcfg.NewAdapterContent(`{}`)
+	if nil != err {
+		t.Fatalf("配置错误：%v", err)
+	}
+	g.Cfg().SetAdapter(adapter)
+
+	fake := fakebinance.New()
+	fake.AddAccount("traderKey", "traderSecret", 10000)
+	fake.AddAccount("userKey", "userSecret", 1000)
+	fake.SetPrice("BTCUSDT", 50000)
+	service.RegisterBinance(binance.NewWithBaseURL(fake.URL(), fake.URL(), fake.WsURL()))
+
+	return newFakeFollower(), fake
+}
+
+// newFakeFollower 跟单交易员的用户，系统仓位为空，相当于重启后的实例
+func newFakeFollower() *sListenAndOrder {
+	s := New()
+	s.ledger = newMemoryLedger()
+	s.SymbolsMap.Set("binanceBTCUSDT", &entity.LhCoinSymbol{Symbol: "BTC", QuantityPrecision: 3})
+	s.Users.Set(fakeUserId, &entity.User{Id: uint(fakeUserId), Plat: "binance", ApiKey: "userKey", ApiSecret: "userSecret", ApiStatus: 1, OpenStatus: 2})
+	s.UsersMoney.Set(fakeUserId, float64(1000))
+	s.UsersPositionSide.Set(fakeUserId, "ALL")
+	s.UsersTraders.Set(fakeUserId, map[uint]float64{fakeTraderId: 1})
+	return s
+}
+
+// TestFakeBinanceFollow 交易员在模拟服务成交，经用户数据流推送、OrderAtPlat跟单，用户仓位与模拟服务一致
+func TestFakeBinanceFollow(t *testing.T) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		traderId    = fakeTraderId
+		userId      = fakeUserId
+	)
+	defer cancel()
+
+	s, fake := newFakeFollow(t)
+	defer fake.Close()
+
+	// 同步执行用户信号，执行后通知
+	done := make(chan *entity.OrderInfo, 10)
+	s.dispatch = func(userId int, msg *entity.OrderInfo) {
+		s.OrderAtPlat(ctx, &entity.DoValue{UserId: userId, Value: msg})
+		done <- msg
+	}
+
+	trader := newTrader(&entity.Trader{Id: traderId, ApiKey: "traderKey", ApiSecret: "traderSecret"})
+	trader.Source = &binanceSource{s: s}
+	if err := trader.Source.Prepare(trader); nil != err {
+		t.Fatalf("交易员初始化失败：%v", err)
+	}
+
+	if lessThanOrEqualZero(trader.Money.Val(), 1e-7) {
+		t.Fatalf("交易员保证金错误：%v", trader.Money.Val())
+	}
+
+	s.Traders.Set(int(traderId), trader)
+	go s.Run(ctx, traderId)
+
+	for i := 0; nil == trader.getConn(); i++ {
+		if 100 <= i {
+			t.Fatalf("用户数据流未连接")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := fake.PlaceOrder("traderKey", "BTCUSDT", "BUY", "LONG", "0.2", false); nil != err {
+		t.Fatalf("交易员下单失败：%v", err)
+	}
+
+	select {
+	case msg := <-done:
+		if traderId != msg.TraderId || "BTCUSDT" != msg.Symbol || "LONG" != msg.PositionSide || "BUY" != msg.Side || 0 >= msg.OrderId {
+			t.Fatalf("跟单信号错误：%+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("未收到跟单信号")
+	}
+
+	// 交易员仓位来自推送
+	tmpPosition := trader.Position.Get("BTCUSDTLONG")
+	if nil == tmpPosition || !floatEqual(0.2, tmpPosition.(*TraderPosition).PositionAmount, 1e-9) {
+		t.Errorf("交易员仓位错误：%+v", tmpPosition)
+	}
+
+	// 用户仓位按保证金比例跟单，系统记录与交易所成交一致
+	amount, ok := s.OrderMap.Get(orderMapKey("BTCUSDT", "LONG", uint(userId), traderId)).(float64)
+	if !ok || lessThanOrEqualZero(amount, 1e-7) {
+		t.Fatalf("用户仓位未记录：%v", s.OrderMap.Map())
+	}
+
+	// 用户保证金1000，交易员10000，交易员开0.2
+	if !floatEqual(0.02, amount, 1e-9) {
+		t.Errorf("用户仓位数量错误：%v", amount)
+	}
+
+	if !floatEqual(amount, fake.Positions("userKey")["BTCUSDT&LONG"], 1e-9) {
+		t.Errorf("用户仓位与交易所不一致：%v，%v", amount, fake.Positions("userKey"))
+	}
+
+	if !floatEqual(50000, s.OrderPrices.GetVar(orderMapKey("BTCUSDT", "LONG", uint(userId), traderId)).Float64(), 1e-6) {
+		t.Errorf("用户开仓均价错误：%v", s.OrderPrices.Map())
+	}
+}
+
+// TestFakeBinanceReplay 重启后重放已下单的信号，按clientOrderId查询到订单，不重复下单
+func TestFakeBinanceReplay(t *testing.T) {
+	var (
+		ctx = context.Background()
+		key = orderMapKey("BTCUSDT", "LONG", uint(fakeUserId), fakeTraderId)
+	)
+
+	s, fake := newFakeFollow(t)
+	defer fake.Close()
+
+	newSignal := func() *entity.OrderInfo {
+		return &entity.OrderInfo{Symbol: "BTCUSDT", Amount: 0.2, Oq: 0.2, Status: "OPEN", Side: "BUY", PositionSide: "LONG", TraderId: fakeTraderId, OrderId: 100, TradeId: 1}
+	}
+
+	trader := newTrader(&entity.Trader{Id: fakeTraderId})
+	trader.Money.Set(10000)
+	s.Traders.Set(int(fakeTraderId), trader)
+
+	s.OrderAtPlat(ctx, &entity.DoValue{UserId: fakeUserId, Value: newSignal()})
+	if !floatEqual(0.02, fake.Positions("userKey")["BTCUSDT&LONG"], 1e-9) {
+		t.Fatalf("首次下单仓位错误：%v", fake.Positions("userKey"))
+	}
+
+	// 下单后未确认即重启，队列重放同一信号
+	replayed := newFakeFollower()
+	replayed.Traders = s.Traders
+	replayed.OrderAtPlat(ctx, &entity.DoValue{UserId: fakeUserId, Value: newSignal(), Replay: true})
+	if !floatEqual(0.02, fake.Positions("userKey")["BTCUSDT&LONG"], 1e-9) {
+		t.Fatalf("重放后重复下单：%v", fake.Positions("userKey"))
+	}
+
+	// 查询到的订单按成交记录系统仓位
+	if amount, ok := replayed.OrderMap.Get(key).(float64); !ok || !floatEqual(0.02, amount, 1e-9) {
+		t.Errorf("重放后系统仓位错误：%v", replayed.OrderMap.Map())
+	}
+
+	// 同一实例再次投递已成功的信号直接忽略
+	replayed.OrderAtPlat(ctx, &entity.DoValue{UserId: fakeUserId, Value: newSignal()})
+	if !floatEqual(0.02, fake.Positions("userKey")["BTCUSDT&LONG"], 1e-9) {
+		t.Errorf("再次投递后重复下单：%v", fake.Positions("userKey"))
+	}
+}
@@ -0,0 +1,337 @@
+package listenandorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/os/gtime"
+	"log"
+	"math"
+	"os"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	journalKindEvent   = "event"   // 原始推送
+	journalKindSignal  = "signal"  // 推送给用户的信号
+	journalKindWebhook = "webhook" // webhook信号
+)
+
+// journal 原始推送和信号的追加日志，每行一条json
+type journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// InitJournal 打开信号日志，配置journal.file为空时不记录
+func (s *sListenAndOrder) InitJournal(ctx context.Context) (err error) {
+	path := g.Cfg().MustGet(ctx, "journal.file").String()
+	if 0 >= len(path) {
+		return nil
+	}
+
+	file, err := gfile.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if nil != err {
+		log.Println("InitJournal，打开日志错误：", path, err)
+		return err
+	}
+
+	s.Journal = &journal{file: file}
+	return nil
+}
+
+// write 写入一条记录
+func (j *journal) write(record *entity.JournalRecord) {
+	content, err := json.Marshal(record)
+	if nil != err {
+		log.Println("日志序列化错误：", err)
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err = j.file.Write(append(content, '\n'))
+	if nil != err {
+		log.Println("日志写入错误：", err)
+	}
+}
+
+// journalEvent 记录原始推送
+func (s *sListenAndOrder) journalEvent(traderId uint, message []byte) {
+	if nil == s.Journal {
+		return
+	}
+
+	s.Journal.write(&entity.JournalRecord{
+		Time:     gtime.TimestampMilli(),
+		Kind:     journalKindEvent,
+		TraderId: traderId,
+		Event:    message,
+	})
+}
+
+// journalSignal 记录推送给用户的信号
+func (s *sListenAndOrder) journalSignal(traderId uint, msg *entity.OrderInfo) {
+	if nil == s.Journal {
+		return
+	}
+
+	s.Journal.write(&entity.JournalRecord{
+		Time:     gtime.TimestampMilli(),
+		Kind:     journalKindSignal,
+		TraderId: traderId,
+		Signal:   msg,
+	})
+}
+
+// journalWebhook 记录webhook信号
+func (s *sListenAndOrder) journalWebhook(alert *entity.WebhookAlert) {
+	if nil == s.Journal {
+		return
+	}
+
+	// token不落日志
+	tmpAlert := *alert
+	tmpAlert.Token = ""
+	content, err := json.Marshal(&tmpAlert)
+	if nil != err {
+		return
+	}
+
+	s.Journal.write(&entity.JournalRecord{
+		Time:     gtime.TimestampMilli(),
+		Kind:     journalKindWebhook,
+		TraderId: alert.TraderId,
+		Event:    content,
+	})
+}
+
+// Replay 用模拟交易所重放日志，一个模拟用户跟单全部交易员，对比重放产生的信号和日志中的信号
+func (s *sListenAndOrder) Replay(ctx context.Context, path string, traderMoney, userMoney float64, quantityPrecision int) (*entity.ReplayResult, error) {
+	if lessThanOrEqualZero(traderMoney, 1e-7) || lessThanOrEqualZero(userMoney, 1e-7) {
+		return nil, gerror.New("保证金必须大于0")
+	}
+
+	records, err := readJournal(path)
+	if nil != err {
+		return nil, err
+	}
+
+	const replayUserId = 1
+	var (
+		r   = newOffline()
+		sim = service.SimExchange()
+		res = &entity.ReplayResult{
+			Mismatches: make([]string, 0),
+		}
+		expected = make([]*entity.OrderInfo, 0)
+		produced = make([]*entity.OrderInfo, 0)
+		user     = r.offlineUser(replayUserId, "binance", userMoney)
+	)
+	r.dispatch = func(userId int, msg *entity.OrderInfo) {
+		tmpMsg := *msg
+		produced = append(produced, &tmpMsg)
+		r.OrderAtPlat(ctx, &entity.DoValue{UserId: userId, Value: msg})
+	}
+
+	// 市价单NEW推送没有成交价，先用交易对首个成交价作为初始价格
+	r.seedJournalPrices(records, quantityPrecision, 0)
+
+	for _, record := range records {
+		if journalKindSignal == record.Kind {
+			if nil != record.Signal {
+				expected = append(expected, record.Signal)
+			}
+
+			continue
+		}
+
+		if journalKindEvent != record.Kind {
+			continue
+		}
+
+		trader := r.offlineTrader(record.TraderId, traderMoney)
+		r.UsersTraders.Get(replayUserId).(map[uint]float64)[trader.Id] = 1
+		if symbol, price := r.replaySymbol(record.Event, quantityPrecision, 0); 0 < price {
+			sim.SetPrice(symbol, price)
+		}
+
+		res.Events++
+		r.dispatchStreamMessage(ctx, trader, record.Event, 0)
+	}
+
+	res.ExpectedSignals = len(expected)
+	res.ProducedSignals = len(produced)
+	for i := 0; i < len(expected) || i < len(produced); i++ {
+		if i >= len(expected) {
+			res.Mismatches = append(res.Mismatches, fmt.Sprintf("#%d 多出信号：%+v", i, produced[i]))
+			continue
+		}
+
+		if i >= len(produced) {
+			res.Mismatches = append(res.Mismatches, fmt.Sprintf("#%d 缺少信号：%+v", i, expected[i]))
+			continue
+		}
+
+		if !sameSignal(expected[i], produced[i]) {
+			res.Mismatches = append(res.Mismatches, fmt.Sprintf("#%d 信号不一致：日志%+v，重放%+v", i, expected[i], produced[i]))
+		}
+	}
+
+	res.Positions = sim.GetPositions(user.ApiKey)
+	res.Ledger = make(map[string]float64, 0)
+	r.OrderMap.Iterator(func(k interface{}, v interface{}) bool {
+		res.Ledger[k.(string)] = v.(float64)
+		return true
+	})
+
+	return res, nil
+}
+
+// readJournal 读取日志，解析失败的行跳过
+func readJournal(path string) ([]*entity.JournalRecord, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]*entity.JournalRecord, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var record *entity.JournalRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); nil != err || nil == record {
+			log.Println("读取日志，解析错误：", err, scanner.Text())
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	if err = scanner.Err(); nil != err {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// newOffline 离线实例，不读写数据库，交易所替换为模拟交易所
+func newOffline() *sListenAndOrder {
+	sim := service.SimExchange()
+	sim.Reset()
+	service.RegisterBinance(sim)
+	service.RegisterGate(sim)
+
+	r := New()
+	r.ledger = newMemoryLedger()
+	return r
+}
+
+// offlineUser 离线模拟用户，双向持仓，跟单的交易员在首次出现时加入
+func (s *sListenAndOrder) offlineUser(userId int, plat string, userMoney float64) *entity.User {
+	user := &entity.User{
+		Id:         uint(userId),
+		Plat:       plat,
+		ApiKey:     fmt.Sprintf("offline_%d", userId),
+		ApiSecret:  "offline",
+		ApiStatus:  1,
+		OpenStatus: 2,
+		Num:        1,
+	}
+
+	s.Users.Set(userId, user)
+	s.UsersMoney.Set(userId, userMoney)
+	s.UsersPositionSide.Set(userId, "ALL")
+	s.UsersTraders.Set(userId, make(map[uint]float64, 0))
+	service.SimExchange().SetBalance(user.ApiKey, userMoney)
+	return user
+}
+
+// offlineTrader 离线交易员，首次出现时创建
+func (s *sListenAndOrder) offlineTrader(traderId uint, traderMoney float64) *Trader {
+	if tmpTrader := s.Traders.Get(int(traderId)); nil != tmpTrader {
+		return tmpTrader.(*Trader)
+	}
+
+	trader := newTrader(&entity.Trader{Id: traderId})
+	trader.Source = &binanceSource{s: s}
+	trader.Money.Set(traderMoney)
+	trader.PositionSide.Set("ALL")
+	s.Traders.Set(int(traderId), trader)
+	return trader
+}
+
+// seedJournalPrices 用交易对在日志中的首个成交价作为初始价格
+func (s *sListenAndOrder) seedJournalPrices(records []*entity.JournalRecord, quantityPrecision int, quantoMultiplier float64) {
+	for i := len(records) - 1; i >= 0; i-- {
+		if journalKindEvent != records[i].Kind {
+			continue
+		}
+
+		if symbol, price := s.replaySymbol(records[i].Event, quantityPrecision, quantoMultiplier); 0 < price {
+			service.SimExchange().SetPrice(symbol, price)
+		}
+	}
+}
+
+// replaySymbol 推送的交易对和成交价，无价格时为0，新交易对按默认精度加入交易对信息
+func (s *sListenAndOrder) replaySymbol(message []byte, quantityPrecision int, quantoMultiplier float64) (string, float64) {
+	var event *entity.OrderTradeUpdate
+	if err := json.Unmarshal(message, &event); nil != err || nil == event || "ORDER_TRADE_UPDATE" != event.EventType {
+		return "", 0
+	}
+
+	symbol := event.Order.Symbol
+	s.offlineSymbol(symbol, quantityPrecision, quantoMultiplier)
+
+	for _, tmpPrice := range []string{event.Order.LastExecutedPrice, event.Order.AveragePrice, event.Order.OriginalPrice, event.Order.StopPrice} {
+		price, err := strconv.ParseFloat(tmpPrice, 64)
+		if nil == err && !lessThanOrEqualZero(price, 1e-7) {
+			return symbol, price
+		}
+	}
+
+	return symbol, 0
+}
+
+// offlineSymbol 加入币安和gate交易对信息，已存在的不覆盖
+func (s *sListenAndOrder) offlineSymbol(symbol string, quantityPrecision int, quantoMultiplier float64) {
+	base := strings.TrimSuffix(symbol, "USDT")
+	if !s.SymbolsMap.Contains("binance" + symbol) {
+		s.SymbolsMap.Set("binance"+symbol, &entity.LhCoinSymbol{
+			Symbol:            base,
+			Plat:              "binance",
+			QuantityPrecision: quantityPrecision,
+		})
+	}
+
+	if lessThanOrEqualZero(quantoMultiplier, 1e-12) || s.SymbolsMap.Contains("gate"+symbol) {
+		return
+	}
+
+	s.SymbolsMap.Set("gate"+symbol, &entity.LhCoinSymbol{
+		Symbol:           base,
+		Plat:             "gate",
+		QuantoMultiplier: quantoMultiplier,
+	})
+	service.SimExchange().SetQuantoMultiplier(symbol, quantoMultiplier)
+}
+
+// sameSignal 信号是否一致，数量按相对误差比较
+func sameSignal(a, b *entity.OrderInfo) bool {
+	if a.TraderId != b.TraderId || a.Symbol != b.Symbol || a.Side != b.Side || a.PositionSide != b.PositionSide || a.Status != b.Status {
+		return false
+	}
+
+	return math.Abs(a.Oq-b.Oq) <= 1e-7*math.Max(1, math.Abs(a.Oq))
+}
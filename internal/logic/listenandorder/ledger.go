@@ -0,0 +1,173 @@
+package listenandorder
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/container/gmap"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"log"
+	"math"
+	"plat_order/internal/model/do"
+	"plat_order/internal/model/entity"
+)
+
+// positionLedger 用户仓位持久化，重启后可恢复
+type positionLedger interface {
+	// Save 写入或更新仓位
+	Save(ctx context.Context, position *entity.UserPosition) error
+	// Delete 删除仓位
+	Delete(ctx context.Context, position *entity.UserPosition) error
+	// Load 全部仓位
+	Load(ctx context.Context) ([]*entity.UserPosition, error)
+}
+
+// dbLedger 写入user_position，依赖唯一索引(user_id,trader_id,symbol,position_side)，见manifest/sql/user_position.sql
+type dbLedger struct{}
+
+func (l *dbLedger) Save(ctx context.Context, position *entity.UserPosition) error {
+	_, err := g.Model("user_position").Ctx(ctx).Data(&do.UserPosition{
+		UserId:       position.UserId,
+		TraderId:     position.TraderId,
+		Symbol:       position.Symbol,
+		PositionSide: position.PositionSide,
+		Amount:       position.Amount,
+		AvgPrice:     position.AvgPrice,
+		CreatedAt:    gtime.Now(),
+		UpdatedAt:    gtime.Now(),
+	}).OnDuplicate("amount", "avg_price", "updated_at").Save()
+
+	return err
+}
+
+func (l *dbLedger) Delete(ctx context.Context, position *entity.UserPosition) error {
+	_, err := g.Model("user_position").Ctx(ctx).
+		Where("user_id=? AND trader_id=? AND symbol=? AND position_side=?", position.UserId, position.TraderId, position.Symbol, position.PositionSide).
+		Delete()
+
+	return err
+}
+
+func (l *dbLedger) Load(ctx context.Context) ([]*entity.UserPosition, error) {
+	var positions []*entity.UserPosition
+	err := g.Model("user_position").Ctx(ctx).Scan(&positions)
+
+	return positions, err
+}
+
+// memoryLedger 离线重放使用，不读写数据库，key同OrderMap
+type memoryLedger struct {
+	positions *gmap.StrAnyMap
+}
+
+func newMemoryLedger() *memoryLedger {
+	return &memoryLedger{positions: gmap.NewStrAnyMap(true)}
+}
+
+func (l *memoryLedger) Save(ctx context.Context, position *entity.UserPosition) error {
+	tmp := *position
+	l.positions.Set(orderMapKey(position.Symbol, position.PositionSide, position.UserId, position.TraderId), &tmp)
+	return nil
+}
+
+func (l *memoryLedger) Delete(ctx context.Context, position *entity.UserPosition) error {
+	l.positions.Remove(orderMapKey(position.Symbol, position.PositionSide, position.UserId, position.TraderId))
+	return nil
+}
+
+func (l *memoryLedger) Load(ctx context.Context) ([]*entity.UserPosition, error) {
+	res := make([]*entity.UserPosition, 0, l.positions.Size())
+	l.positions.Iterator(func(k string, v interface{}) bool {
+		tmp := *v.(*entity.UserPosition)
+		res = append(res, &tmp)
+		return true
+	})
+
+	return res, nil
+}
+
+// ledgerPosition 用户仓位key对应的持久化记录
+func ledgerPosition(key string) (*entity.UserPosition, error) {
+	symbol, positionSide, userId, traderId, err := parseOrderMapKey(key)
+	if nil != err {
+		return nil, err
+	}
+
+	return &entity.UserPosition{UserId: userId, TraderId: traderId, Symbol: symbol, PositionSide: positionSide}, nil
+}
+
+// setOrderMap 更新用户仓位，同时写入持久化记录
+func (s *sListenAndOrder) setOrderMap(ctx context.Context, key string, amount float64) {
+	s.OrderMap.Set(key, amount)
+
+	position, err := ledgerPosition(key)
+	if nil != err {
+		log.Println("用户仓位写入，解析key错误:", key, err)
+		return
+	}
+
+	position.Amount = amount
+	position.AvgPrice = s.OrderPrices.GetVar(key).Float64()
+	err = s.ledger.Save(ctx, position)
+	if nil != err {
+		log.Println("用户仓位写入，数据库错误:", key, amount, err)
+	}
+}
+
+// setOrderMapFill 按实际成交更新用户仓位和开仓均价，加仓按成交价加权，减仓均价不变，平仓清零，反向开仓为成交价
+func (s *sListenAndOrder) setOrderMapFill(ctx context.Context, key string, amount float64, fill *entity.OrderFill) {
+	var (
+		last      = s.OrderMap.GetVar(key).Float64()
+		lastPrice = s.OrderPrices.GetVar(key).Float64()
+		price     = lastPrice
+	)
+	if floatEqual(amount, 0, 1e-12) {
+		price = 0
+	} else if nil != fill && 0 < fill.AvgPrice {
+		if math.Signbit(amount) != math.Signbit(last) || floatEqual(last, 0, 1e-12) {
+			price = fill.AvgPrice
+		} else if math.Abs(amount) > math.Abs(last) {
+			added := math.Abs(amount) - math.Abs(last)
+			price = (math.Abs(last)*lastPrice + added*fill.AvgPrice) / math.Abs(amount)
+		}
+	}
+
+	s.OrderPrices.Set(key, price)
+	s.setOrderMap(ctx, key, amount)
+}
+
+// removeOrderMap 删除用户仓位，同时删除持久化记录
+func (s *sListenAndOrder) removeOrderMap(ctx context.Context, key string) {
+	s.OrderMap.Remove(key)
+	s.OrderPrices.Remove(key)
+
+	position, err := ledgerPosition(key)
+	if nil != err {
+		log.Println("用户仓位删除，解析key错误:", key, err)
+		return
+	}
+
+	err = s.ledger.Delete(ctx, position)
+	if nil != err {
+		log.Println("用户仓位删除，数据库错误:", key, err)
+	}
+}
+
+// LoadOrderMap 启动时从数据库恢复用户仓位
+func (s *sListenAndOrder) LoadOrderMap(ctx context.Context) (err error) {
+	var (
+		positions []*entity.UserPosition
+	)
+	positions, err = s.ledger.Load(ctx)
+	if nil != err {
+		log.Println("LoadOrderMap，数据库查询错误：", err)
+		return err
+	}
+
+	for _, v := range positions {
+		s.OrderMap.Set(orderMapKey(v.Symbol, v.PositionSide, v.UserId, v.TraderId), v.Amount)
+		s.OrderPrices.Set(orderMapKey(v.Symbol, v.PositionSide, v.UserId, v.TraderId), v.AvgPrice)
+	}
+
+	log.Println("LoadOrderMap，恢复用户仓位：", len(positions))
+	return nil
+}
@@ -0,0 +1,85 @@
+package listenandorder
+
+import (
+	"context"
+	"plat_order/internal/model/entity"
+	"testing"
+)
+
+// TestLedgerLoadOrderMap 仓位写入、更新、删除后重启恢复，与内存中的仓位一致
+func TestLedgerLoadOrderMap(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		s     = New()
+		long  = orderMapKey("BTCUSDT", "LONG", 1, 7)
+		short = orderMapKey("ETHUSDT", "SHORT", 1, 8)
+		gate  = orderMapKey("BTC", "BOTH", 2, 7)
+	)
+	s.ledger = newMemoryLedger()
+
+	s.setOrderMapFill(ctx, long, 0.1, &entity.OrderFill{AvgPrice: 50000})
+	s.setOrderMapFill(ctx, long, 0.3, &entity.OrderFill{AvgPrice: 60000})
+	s.setOrderMapFill(ctx, short, 2, &entity.OrderFill{AvgPrice: 3000})
+	s.removeOrderMap(ctx, short)
+	s.setOrderMapFill(ctx, gate, -5, &entity.OrderFill{AvgPrice: 51000})
+	s.setOrderMap(ctx, gate, -3)
+
+	restarted := New()
+	restarted.ledger = s.ledger
+	if err := restarted.LoadOrderMap(ctx); nil != err {
+		t.Fatalf("恢复用户仓位错误：%v", err)
+	}
+
+	if 2 != restarted.OrderMap.Size() || restarted.OrderMap.Contains(short) {
+		t.Fatalf("恢复的仓位错误：%v", restarted.OrderMap.Map())
+	}
+
+	for _, key := range []string{long, gate} {
+		if !floatEqual(s.OrderMap.GetVar(key).Float64(), restarted.OrderMap.GetVar(key).Float64(), 1e-12) {
+			t.Errorf("%s仓位%v，期望%v", key, restarted.OrderMap.Get(key), s.OrderMap.Get(key))
+		}
+
+		if !floatEqual(s.OrderPrices.GetVar(key).Float64(), restarted.OrderPrices.GetVar(key).Float64(), 1e-9) {
+			t.Errorf("%s均价%v，期望%v", key, restarted.OrderPrices.Get(key), s.OrderPrices.Get(key))
+		}
+	}
+}
+
+func TestSetOrderMapFill(t *testing.T) {
+	tests := []struct {
+		name      string
+		last      float64
+		lastPrice float64
+		amount    float64
+		fill      *entity.OrderFill
+		want      float64
+	}{
+		{name: "开仓", last: 0, lastPrice: 0, amount: 0.1, fill: &entity.OrderFill{AvgPrice: 50000}, want: 50000},
+		{name: "加仓加权", last: 0.1, lastPrice: 50000, amount: 0.3, fill: &entity.OrderFill{AvgPrice: 60000}, want: (0.1*50000 + 0.2*60000) / 0.3},
+		{name: "空仓加仓加权", last: -1, lastPrice: 3000, amount: -3, fill: &entity.OrderFill{AvgPrice: 3300}, want: 3200},
+		{name: "减仓均价不变", last: 0.3, lastPrice: 55000, amount: 0.1, fill: &entity.OrderFill{AvgPrice: 70000}, want: 55000},
+		{name: "平仓清零", last: 0.3, lastPrice: 55000, amount: 0, fill: &entity.OrderFill{AvgPrice: 70000}, want: 0},
+		{name: "反向开仓为成交价", last: 2, lastPrice: 3000, amount: -1, fill: &entity.OrderFill{AvgPrice: 3100}, want: 3100},
+		{name: "无成交均价不变", last: 0.1, lastPrice: 50000, amount: 0.2, fill: nil, want: 50000},
+		{name: "成交价为0均价不变", last: 0.1, lastPrice: 50000, amount: 0.2, fill: &entity.OrderFill{}, want: 50000},
+	}
+
+	for _, tt := range tests {
+		var (
+			s   = New()
+			key = orderMapKey("BTCUSDT", "BOTH", 1, 7)
+		)
+		s.ledger = newMemoryLedger()
+		s.OrderMap.Set(key, tt.last)
+		s.OrderPrices.Set(key, tt.lastPrice)
+
+		s.setOrderMapFill(context.Background(), key, tt.amount, tt.fill)
+		if !floatEqual(tt.amount, s.OrderMap.GetVar(key).Float64(), 1e-12) {
+			t.Errorf("%s: 仓位%v，期望%v", tt.name, s.OrderMap.Get(key), tt.amount)
+		}
+
+		if !floatEqual(tt.want, s.OrderPrices.GetVar(key).Float64(), 1e-9) {
+			t.Errorf("%s: 均价%v，期望%v", tt.name, s.OrderPrices.Get(key), tt.want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gateio/gateapi-go/v6"
 	"github.com/gogf/gf/v2/container/gmap"
 	"github.com/gogf/gf/v2/container/gtype"
+	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/os/grpool"
 	"github.com/gogf/gf/v2/os/gtime"
@@ -16,28 +17,43 @@ import (
 	"github.com/shopspring/decimal"
 	"log"
 	"math"
-	"plat_order/internal/logic/binance"
 	"plat_order/internal/model/do"
 	"plat_order/internal/model/entity"
 	"plat_order/internal/service"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type (
 	sListenAndOrder struct {
-		SymbolsMap *gmap.StrAnyMap
+		SymbolsMap  *gmap.StrAnyMap
+		SymbolRules *gmap.StrAnyMap // 币安交易规则，symbol => *symbolRule
+		MarkPrices  *gmap.StrAnyMap // 标记价格缓存，symbol => *markPrice
 
 		Users             *gmap.IntAnyMap
 		UsersMoney        *gmap.IntAnyMap
 		UsersPositionSide *gmap.IntStrMap
+		UsersTraders      *gmap.IntAnyMap
 		OrderMap          *gmap.Map
+		OrderPrices       *gmap.StrAnyMap // 用户仓位开仓均价，key同OrderMap
+		UserMismatches    *gmap.IntAnyMap
+		OrderFailures     *gmap.IntAnyMap // 用户下单失败统计，userId => *entity.OrderFailure
 
-		TraderInfo         *Trader
-		TraderMoney        *gtype.Float64
-		TraderPositionSide *gtype.String
-		Position           *gmap.StrAnyMap
+		Traders          *gmap.IntAnyMap
+		TraderMismatches *gmap.IntAnyMap
+		traderMu         sync.Mutex // 交易员加载和更换api
+
+		Journal *journal // 信号日志，未配置时为nil
+
+		ledger       positionLedger                          // 用户仓位持久化，离线重放时为内存
+		paperBalance float64                                 // 模拟盘入金
+		dispatch     func(userId int, msg *entity.OrderInfo) // 信号分发，默认推送到用户队列
+
+		SignalDedup      *signalDedup // 交易员推送去重
+		UsersSignalDedup *signalDedup // 用户执行信号去重
+		UsersSignalTried *signalDedup // 用户已开始执行的信号，再次投递时先查询是否已下单
 
 		Pool *grpool.Pool
 	}
@@ -49,28 +65,101 @@ func init() {
 
 func New() *sListenAndOrder {
 	return &sListenAndOrder{
-		SymbolsMap: gmap.NewStrAnyMap(true), // 交易对信息
+		SymbolsMap:  gmap.NewStrAnyMap(true), // 交易对信息
+		SymbolRules: gmap.NewStrAnyMap(true), // 交易规则
+		MarkPrices:  gmap.NewStrAnyMap(true), // 标记价格
 
 		Users:             gmap.NewIntAnyMap(true), // 用户信息
 		UsersMoney:        gmap.NewIntAnyMap(true), // 用户保证金
 		UsersPositionSide: gmap.NewIntStrMap(true), // 用户持仓方向
+		UsersTraders:      gmap.NewIntAnyMap(true), // 用户跟单的交易员及系数
 		OrderMap:          gmap.New(true),
+		OrderPrices:       gmap.NewStrAnyMap(true),
+		ledger:            &dbLedger{},
+		UserMismatches:    gmap.NewIntAnyMap(true), // 用户仓位差异
+		OrderFailures:     gmap.NewIntAnyMap(true), // 用户下单失败统计
 
-		TraderInfo: &Trader{
-			apiKey:    "",
-			apiSecret: "",
-		},
-		TraderMoney:        gtype.NewFloat64(),      // 交易员保证金
-		TraderPositionSide: gtype.NewString(),       // 交易员持仓方向
-		Position:           gmap.NewStrAnyMap(true), // 交易员仓位信息
+		Traders:          gmap.NewIntAnyMap(true), // 交易员信息
+		TraderMismatches: gmap.NewIntAnyMap(true), // 交易员仓位差异
+
+		SignalDedup:      newSignalDedup(signalDedupSize),
+		UsersSignalDedup: newSignalDedup(signalDedupSize),
+		UsersSignalTried: newSignalDedup(signalDedupSize),
 
 		Pool: grpool.New(), // 全局协程池子
 	}
 }
 
 type Trader struct {
+	Id        uint
+	mu        sync.RWMutex
 	apiKey    string
 	apiSecret string
+
+	Source          SignalSource    // 信号来源
+	Money           *gtype.Float64  // 交易员保证金
+	PositionSide    *gtype.String   // 交易员持仓方向
+	Position        *gmap.StrAnyMap // 交易员仓位信息
+	AccountPosition *gmap.StrAnyMap // 交易员账户推送仓位
+	PendingOrders   *gmap.Map       // 已跟单未完成的交易员订单
+	PositionTimes   *gmap.StrIntMap // 仓位最新的事件时间，订单结束、账户推送或接口快照
+
+	orderMu sync.Mutex           // 仓位更新
+	pushMu  sync.Mutex           // 信号推送，保证按仓位更新的顺序推送
+	signals []*entity.OrderInfo  // 持有orderMu期间产生的信号，释放后推送
+	fillMu  sync.Mutex           // 限价成交合并
+	fills   map[int64]*limitFill // 合并中的限价单成交
+
+	connMu sync.Mutex
+	conn   *websocket.Conn
+	cancel context.CancelFunc
+}
+
+func newTrader(v *entity.Trader) *Trader {
+	return &Trader{
+		Id:        v.Id,
+		apiKey:    v.ApiKey,
+		apiSecret: v.ApiSecret,
+
+		Money:           gtype.NewFloat64(),
+		PositionSide:    gtype.NewString(),
+		Position:        gmap.NewStrAnyMap(true),
+		AccountPosition: gmap.NewStrAnyMap(true),
+		PendingOrders:   gmap.New(true),
+		PositionTimes:   gmap.NewStrIntMap(true),
+
+		fills: make(map[int64]*limitFill, 0),
+	}
+}
+
+// credentials 交易员api信息，运行中可更换
+func (t *Trader) credentials() (string, string) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.apiKey, t.apiSecret
+}
+
+// setCredentials 更换交易员api信息
+func (t *Trader) setCredentials(apiKey, apiSecret string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.apiKey = apiKey
+	t.apiSecret = apiSecret
+}
+
+// advancePositionTime 记录仓位的事件时间，早于已记录的时间返回false
+func (t *Trader) advancePositionTime(key string, eventTime int64) bool {
+	var res bool
+	t.PositionTimes.LockFunc(func(m map[string]int) {
+		if int64(m[key]) > eventTime {
+			return
+		}
+
+		m[key] = int(eventTime)
+		res = true
+	})
+
+	return res
 }
 
 type TraderPosition struct {
@@ -79,6 +168,43 @@ type TraderPosition struct {
 	PositionAmount float64
 }
 
+type PendingOrder struct {
+	Key       string
+	CreatedAt time.Time
+}
+
+// pendingOrderExpire 已跟单订单等待成交的最长时间，超时后以账户仓位为准
+const pendingOrderExpire = 10 * time.Minute
+
+// orderMapKey 用户仓位key，交易对&持仓方向&用户id&交易员id
+func orderMapKey(symbol, positionSide string, userId, traderId uint) string {
+	return symbol + "&" + positionSide + "&" + strconv.FormatUint(uint64(userId), 10) + "&" + strconv.FormatUint(uint64(traderId), 10)
+}
+
+// parseOrderMapKey 解析用户仓位key
+func parseOrderMapKey(key string) (symbol, positionSide string, userId, traderId uint, err error) {
+	parts := strings.Split(key, "&")
+	if 4 != len(parts) {
+		return "", "", 0, 0, gerror.Newf("用户仓位key错误：%s", key)
+	}
+
+	var (
+		uid uint64
+		tid uint64
+	)
+	uid, err = strconv.ParseUint(parts[2], 10, 64)
+	if nil != err {
+		return "", "", 0, 0, err
+	}
+
+	tid, err = strconv.ParseUint(parts[3], 10, 64)
+	if nil != err {
+		return "", "", 0, 0, err
+	}
+
+	return parts[0], parts[1], uint(uid), uint(tid), nil
+}
+
 // floatEqual 判断两个浮点数是否在精度范围内相等
 func floatEqual(a, b, epsilon float64) bool {
 	return math.Abs(a-b) <= epsilon
@@ -89,6 +215,64 @@ func lessThanOrEqualZero(a, epsilon float64) bool {
 	return a-0 < epsilon || math.Abs(a-0) < epsilon
 }
 
+// hedgeOrderInfo 双向持仓的仓位变化转为下单信息，仓位均为正数
+func hedgeOrderInfo(symbol, positionSide, status string, lastAmount, newAmount, oQ float64) *entity.OrderInfo {
+	var (
+		openSide  string
+		closeSide string
+	)
+	if "LONG" == positionSide {
+		openSide = "BUY"
+		closeSide = "SELL"
+	} else if "SHORT" == positionSide {
+		openSide = "SELL"
+		closeSide = "BUY"
+	} else {
+		return nil
+	}
+
+	if "CLOSE" == status {
+		// 上一次无仓位
+		if lessThanOrEqualZero(lastAmount, 1e-7) {
+			return nil
+		}
+
+		// 全平仓
+		return &entity.OrderInfo{
+			Symbol:       symbol,
+			Amount:       0,
+			LastAmount:   lastAmount,
+			Oq:           lastAmount,
+			Status:       "CLOSE",
+			Side:         closeSide,
+			PositionSide: positionSide,
+		}
+	}
+
+	if lessThanOrEqualZero(newAmount, 1e-7) {
+		return nil
+	}
+
+	tmpMsg := &entity.OrderInfo{
+		Symbol:       symbol,
+		Amount:       newAmount,
+		LastAmount:   lastAmount,
+		Oq:           math.Abs(oQ),
+		Status:       "OPEN",
+		PositionSide: positionSide,
+	}
+
+	if newAmount > lastAmount {
+		// 开仓或追加仓位
+		tmpMsg.Side = openSide
+	} else {
+		// 部分平仓
+		tmpMsg.Side = closeSide
+	}
+
+	return tmpMsg
+}
+
 // SetSymbol 更新symbol
 func (s *sListenAndOrder) SetSymbol(ctx context.Context) (err error) {
 	// 获取代币信息
@@ -110,67 +294,68 @@ func (s *sListenAndOrder) SetSymbol(ctx context.Context) (err error) {
 	return nil
 }
 
-// PullAndSetBaseMoneyNewGuiTuAndUser 拉取binance保证金数据
-func (s *sListenAndOrder) PullAndSetBaseMoneyNewGuiTuAndUser(ctx context.Context) {
+// pullTraderMoney 拉取交易员总资产，btc计价转为usdt
+func (s *sListenAndOrder) pullTraderMoney(trader *Trader, btcPriceF float64) {
 	var (
-		err         error
-		walletInfo  []*entity.WalletInfo
-		btcPriceStr string
-		btcPriceF   float64
+		err             error
+		walletInfo      []*entity.WalletInfo
+		allWalletAmount float64
 	)
 
-	btcPriceStr = service.Binance().GetLatestPrice("BTCUSDT")
-	btcPriceF, err = strconv.ParseFloat(btcPriceStr, 64)
-	if nil != err {
-		log.Println(err)
-	}
-
-	if !lessThanOrEqualZero(btcPriceF, 1e-7) {
+	walletInfo = service.Binance().GetWalletInfo(trader.credentials())
+	for _, vWalletInfo := range walletInfo {
+		var tmpBalanceF float64
+		tmpBalanceF, err = strconv.ParseFloat(vWalletInfo.Balance, 64)
+		if nil != err {
+			allWalletAmount = 0
+			log.Println(err)
+			break
+		}
 
-		var allWalletAmount float64
-		walletInfo = service.Binance().GetWalletInfo(s.TraderInfo.apiKey, s.TraderInfo.apiSecret)
-		for _, vWalletInfo := range walletInfo {
-			var tmpBalanceF float64
-			tmpBalanceF, err = strconv.ParseFloat(vWalletInfo.Balance, 64)
-			if nil != err {
-				allWalletAmount = 0
-				log.Println(err)
-				break
-			}
+		if lessThanOrEqualZero(tmpBalanceF, 1e-7) {
+			continue
+		}
 
-			if lessThanOrEqualZero(tmpBalanceF, 1e-7) {
-				continue
-			}
+		allWalletAmount += tmpBalanceF * btcPriceF
+	}
 
-			allWalletAmount += tmpBalanceF * btcPriceF
+	if !lessThanOrEqualZero(allWalletAmount, 1e-7) {
+		if !floatEqual(allWalletAmount, trader.Money.Val(), 100) {
+			log.Println("总资产预估测试usdt:", trader.Id, allWalletAmount)
+			trader.Money.Set(allWalletAmount)
 		}
+	} else {
+		log.Println("总资产为 0 usdt:", trader.Id, allWalletAmount)
+	}
+}
 
-		if !lessThanOrEqualZero(allWalletAmount, 1e-7) {
-			if !floatEqual(allWalletAmount, s.TraderMoney.Val(), 100) {
-				//log.Println("龟兔，变更保证金", tmp, baseMoneyGuiTu.Val())
-				log.Println("总资产预估测试usdt:", allWalletAmount)
-				s.TraderMoney.Set(allWalletAmount)
-			}
-		} else {
-			log.Println("总资产为 0 usdt:", allWalletAmount)
-		}
+// getBtcPrice btc价格
+func getBtcPrice() float64 {
+	btcPriceF, err := strconv.ParseFloat(service.Binance().GetLatestPrice("BTCUSDT"), 64)
+	if nil != err {
+		log.Println(err)
+		return 0
 	}
 
-	//one = service.Binance().GetBinanceInfo(s.TraderInfo.apiKey, s.TraderInfo.apiSecret)
-	//if 0 < len(one) {
-	//	var tmp float64
-	//	tmp, err = strconv.ParseFloat(one, 64)
-	//	if nil != err {
-	//		log.Println("拉取保证金，转化失败：", err)
-	//	}
-	//
-	//	if !floatEqual(tmp, s.TraderMoney.Val(), 10) {
-	//		//log.Println("龟兔，变更保证金", tmp, baseMoneyGuiTu.Val())
-	//		s.TraderMoney.Set(tmp)
-	//	}
-	//}
+	return btcPriceF
+}
+
+// PullAndSetBaseMoneyNewGuiTuAndUser 拉取binance保证金数据
+func (s *sListenAndOrder) PullAndSetBaseMoneyNewGuiTuAndUser(ctx context.Context) {
+	var (
+		err       error
+		btcPriceF float64
+	)
 
-	time.Sleep(300 * time.Millisecond)
+	btcPriceF = getBtcPrice()
+	if !lessThanOrEqualZero(btcPriceF, 1e-7) {
+		s.Traders.Iterator(func(k int, v interface{}) bool {
+			if _, ok := v.(*Trader).Source.(*binanceSource); ok {
+				s.pullTraderMoney(v.(*Trader), btcPriceF)
+			}
+			return true
+		})
+	}
 
 	var (
 		users []*entity.User
@@ -200,7 +385,8 @@ func (s *sListenAndOrder) PullAndSetBaseMoneyNewGuiTuAndUser(ctx context.Context
 			detail string
 		)
 		if "binance" == vGlobalUsers.Plat {
-			detail = service.Binance().GetBinanceInfo(vGlobalUsers.ApiKey, vGlobalUsers.ApiSecret)
+			s.openPaperAccount(vGlobalUsers)
+			detail = userBinance(vGlobalUsers).GetBinanceInfo(vGlobalUsers.ApiKey, vGlobalUsers.ApiSecret)
 		} else if "gate" == vGlobalUsers.Plat {
 			//var (
 			//	gateUser gateapi.FuturesAccount
@@ -241,33 +427,39 @@ func (s *sListenAndOrder) PullAndSetBaseMoneyNewGuiTuAndUser(ctx context.Context
 			//log.Println("保证金为0", vGlobalUsers)
 		}
 
-		time.Sleep(300 * time.Millisecond)
 		return true
 	})
 }
 
+// pullTraderPositionSide 交易员持仓方向，单向BOTH，双向ALL
+func (s *sListenAndOrder) pullTraderPositionSide(trader *Trader) {
+	traderPositionSide := service.Binance().GetBinancePositionSide(trader.credentials())
+	if "BOTH" != traderPositionSide && "ALL" != traderPositionSide {
+		log.Println("查询交易员持仓方向失败", trader.Id, traderPositionSide)
+		if 0 >= len(trader.PositionSide.Val()) {
+			trader.PositionSide.Set("BOTH")
+		}
+	} else if traderPositionSide != trader.PositionSide.Val() {
+		log.Println("交易员持仓方向：", trader.Id, traderPositionSide)
+		trader.PositionSide.Set(traderPositionSide)
+	}
+}
+
 // PullAndSetTraderUserPositionSide 获取并更新持仓方向
 func (s *sListenAndOrder) PullAndSetTraderUserPositionSide(ctx context.Context) (err error) {
-	s.TraderPositionSide.Set("BOTH")
-	// todo 用户和trader的持仓方向更新
 	var (
 		positionSide string
 	)
-	//positionSide = service.Binance().GetBinancePositionSide(s.TraderInfo.apiKey, s.TraderInfo.apiSecret)
-	//if 0 > len(positionSide) {
-	//	log.Println("查询交易员持仓方向失败")
-	//	return nil
-	//}
-	//
-	//if "BOTH" != positionSide && "ALL" != positionSide {
-	//	log.Println("查询交易员持仓方向失败2")
-	//	return nil
-	//}
-	//
-	//if positionSide != s.TraderPositionSide.Val() {
-	//	s.TraderPositionSide.Set(positionSide)
-	//}
 
+	// 交易员
+	s.Traders.Iterator(func(k int, v interface{}) bool {
+		if _, ok := v.(*Trader).Source.(*binanceSource); ok {
+			s.pullTraderPositionSide(v.(*Trader))
+		}
+		return true
+	})
+
+	// 用户统一双向持仓
 	positionSide = "ALL"
 	// 用户
 	s.Users.Iterator(func(k int, v interface{}) bool {
@@ -316,6 +508,216 @@ func (s *sListenAndOrder) PullAndSetTraderUserPositionSide(ctx context.Context)
 	return nil
 }
 
+// getConfigTraders 配置文件中的交易员，配置优先于数据库
+func getConfigTraders(ctx context.Context) map[uint]*entity.Trader {
+	res := make(map[uint]*entity.Trader, 0)
+
+	tmpTraders, err := g.Cfg().Get(ctx, "traders")
+	if nil != err {
+		log.Println("配置文件，交易员读取错误", err)
+		return res
+	}
+
+	if nil == tmpTraders || tmpTraders.IsNil() {
+		return res
+	}
+
+	var configTraders []*entity.Trader
+	err = tmpTraders.Scan(&configTraders)
+	if nil != err {
+		log.Println("配置文件，交易员解析错误", err)
+		return res
+	}
+
+	for _, v := range configTraders {
+		if 0 >= v.Id {
+			log.Println("配置文件，交易员id错误", v.Name)
+			continue
+		}
+
+		v.Status = 1
+		res[v.Id] = v
+	}
+
+	return res
+}
+
+// SetTraders 初始化交易员，新增的开启监听，删除的停止监听，api变更的重新连接
+func (s *sListenAndOrder) SetTraders(ctx context.Context) (err error) {
+	var (
+		traders []*entity.Trader
+	)
+	s.traderMu.Lock()
+	defer s.traderMu.Unlock()
+
+	traders, err = service.User().GetTradersIsOk(ctx)
+	if nil != err {
+		log.Println("SetTraders，查询交易员失败", err)
+		return err
+	}
+
+	// 配置文件覆盖数据库
+	configTraders := getConfigTraders(ctx)
+	tmpTraderMap := make(map[uint]*entity.Trader, 0)
+	for _, vTraders := range traders {
+		tmpTraderMap[vTraders.Id] = vTraders
+	}
+
+	for _, vConfigTraders := range configTraders {
+		tmpTraderMap[vConfigTraders.Id] = vConfigTraders
+	}
+
+	var (
+		failedIds = make([]uint, 0)
+	)
+	for _, v := range tmpTraderMap {
+		if s.Traders.Contains(int(v.Id)) {
+			trader := s.Traders.Get(int(v.Id)).(*Trader)
+			apiKey, apiSecret := trader.credentials()
+			if apiKey != v.ApiKey || apiSecret != v.ApiSecret {
+				log.Println("SetTraders，交易员api变更:", v.Id)
+				err = trader.Source.Rotate(trader, v.ApiKey, v.ApiSecret)
+				if nil != err {
+					log.Println("SetTraders，交易员api更换失败:", v.Id, err)
+					failedIds = append(failedIds, v.Id)
+				}
+			}
+
+			continue
+		}
+
+		source, sourceErr := s.newSignalSource(v)
+		if nil != sourceErr {
+			log.Println("SetTraders，信号来源错误:", v.Id, sourceErr)
+			failedIds = append(failedIds, v.Id)
+			continue
+		}
+
+		trader := newTrader(v)
+		trader.Source = source
+		err = source.Prepare(trader)
+		if nil != err {
+			log.Println("SetTraders，交易员初始化失败:", v.Id, err)
+			failedIds = append(failedIds, v.Id)
+			continue
+		}
+
+		var traderCtx context.Context
+		traderCtx, trader.cancel = context.WithCancel(ctx)
+		s.Traders.Set(int(v.Id), trader)
+
+		err = s.Pool.AddWithRecover(
+			traderCtx,
+			func(ctx context.Context) {
+				trader.Source.Start(ctx, trader)
+			},
+			func(ctx context.Context, exception error) {
+				log.Println("交易员协程panic了，信息:", trader.Id, exception)
+			})
+		if err != nil {
+			log.Println("SetTraders，新增协程，错误:", trader.Id, err)
+			trader.cancel()
+			s.Traders.Remove(int(v.Id))
+			continue
+		}
+
+		log.Println("SetTraders，新增交易员:", trader.Id, v.Name)
+	}
+
+	// 删除的交易员
+	tmpIds := make([]int, 0)
+	s.Traders.Iterator(func(k int, v interface{}) bool {
+		if _, ok := tmpTraderMap[uint(k)]; !ok {
+			tmpIds = append(tmpIds, k)
+		}
+		return true
+	})
+
+	for _, vTmpIds := range tmpIds {
+		log.Println("SetTraders，删除交易员，停止监听:", vTmpIds)
+		tmpTrader := s.Traders.Remove(vTmpIds)
+		if nil == tmpTrader {
+			continue
+		}
+
+		trader := tmpTrader.(*Trader)
+		if nil != trader.cancel {
+			trader.cancel()
+		}
+
+		// 关闭连接，结束读取
+		trader.swapConn(nil)
+	}
+
+	if 0 < len(failedIds) {
+		return gerror.Newf("交易员api校验失败：%v", failedIds)
+	}
+
+	return nil
+}
+
+// rotateTrader 更换交易员api，校验后重新创建listenKey并连接
+func (s *sListenAndOrder) rotateTrader(trader *Trader, apiKey, apiSecret string) error {
+	if nil == service.Binance().GetBinancePositionInfo(apiKey, apiSecret) {
+		return gerror.Newf("交易员api校验失败：%d", trader.Id)
+	}
+
+	trader.setCredentials(apiKey, apiSecret)
+	return s.connectTrader(trader)
+}
+
+// RotateTraderKey 更换交易员api，先写数据库再更换内存中的api，与SetTraders互斥，
+// 避免定时加载读到旧数据库记录后把api换回去
+func (s *sListenAndOrder) RotateTraderKey(ctx context.Context, traderId uint64, apiKey, apiSecret string) error {
+	var (
+		err error
+	)
+
+	s.traderMu.Lock()
+	defer s.traderMu.Unlock()
+
+	tmpTrader := s.Traders.Get(int(traderId))
+	if nil == tmpTrader {
+		return gerror.Newf("交易员不存在：%d", traderId)
+	}
+
+	// 配置文件的交易员需修改配置
+	if _, ok := getConfigTraders(ctx)[uint(traderId)]; ok {
+		return gerror.Newf("交易员api来自配置文件：%d", traderId)
+	}
+
+	trader := tmpTrader.(*Trader)
+	oldApiKey, oldApiSecret := trader.credentials()
+
+	_, err = g.Model("trader").Ctx(ctx).
+		Data(g.Map{"api_key": apiKey, "api_secret": apiSecret, "updated_at": gtime.Now()}).
+		Where("id=?", traderId).
+		Update()
+	if nil != err {
+		log.Println("更换交易员api，数据库更新失败：", traderId, err)
+		return err
+	}
+
+	err = trader.Source.Rotate(trader, apiKey, apiSecret)
+	if nil != err {
+		log.Println("更换交易员api失败：", traderId, err)
+
+		// 新api不可用，数据库恢复为旧api
+		_, rollbackErr := g.Model("trader").Ctx(ctx).
+			Data(g.Map{"api_key": oldApiKey, "api_secret": oldApiSecret, "updated_at": gtime.Now()}).
+			Where("id=?", traderId).
+			Update()
+		if nil != rollbackErr {
+			log.Println("更换交易员api，数据库恢复失败：", traderId, rollbackErr)
+		}
+
+		return err
+	}
+
+	log.Println("更换交易员api成功：", traderId)
+	return nil
+}
+
 // SetUser 初始化用户
 func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 	var (
@@ -331,7 +733,31 @@ func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 		tmpUserMap[vUsers.Id] = vUsers
 	}
 
+	// 用户跟单的交易员及系数
+	var (
+		userTraders []*entity.UserTrader
+	)
+	userTraders, err = service.User().GetUserTradersIsOk(ctx)
+	if nil != err {
+		log.Println("SetUser，查询跟单交易员失败", err)
+		return err
+	}
+
+	tmpUserTraderMap := make(map[uint]map[uint]float64, 0)
+	for _, vUserTraders := range userTraders {
+		if _, ok := tmpUserTraderMap[vUserTraders.UserId]; !ok {
+			tmpUserTraderMap[vUserTraders.UserId] = make(map[uint]float64, 0)
+		}
+
+		tmpUserTraderMap[vUserTraders.UserId][vUserTraders.TraderId] = vUserTraders.Num
+	}
+
 	for _, v := range users {
+		if _, ok := tmpUserTraderMap[v.Id]; !ok {
+			tmpUserTraderMap[v.Id] = make(map[uint]float64, 0)
+		}
+		s.UsersTraders.Set(int(v.Id), tmpUserTraderMap[v.Id])
+
 		if s.Users.Contains(int(v.Id)) {
 			// 变更可否开新仓
 			if 2 != v.OpenStatus && 2 == s.Users.Get(int(v.Id)).(*entity.User).OpenStatus {
@@ -352,11 +778,6 @@ func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 			continue
 		}
 
-		if 0 >= len(s.TraderPositionSide.Val()) {
-			log.Println("SetUser，更新初始化状态失败，交易员持仓模式未知")
-			break
-		}
-
 		if "binance" == v.Plat {
 			//tmp := "true"
 			//if "BOTH" == s.TraderPositionSide.Val() {
@@ -406,11 +827,8 @@ func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 
 		tmpUserPositionSide := "ALL"
 
-		// 交易员保证金
-		tmpTraderBaseMoney := s.TraderMoney.Val()
 		// 获取用户保证金
 		var tmpAmount float64
-		strUserId := strconv.FormatUint(uint64(v.Id), 10)
 		detail := ""
 
 		if lessThanOrEqualZero(v.Num, 1e-7) {
@@ -419,7 +837,8 @@ func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 		}
 
 		if "binance" == v.Plat {
-			detail = service.Binance().GetBinanceInfo(v.ApiKey, v.ApiSecret)
+			s.openPaperAccount(v)
+			detail = userBinance(v).GetBinanceInfo(v.ApiKey, v.ApiSecret)
 		} else if "gate" == v.Plat {
 			//var (
 			//	gateUser gateapi.FuturesAccount
@@ -463,254 +882,258 @@ func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 				log.Println("SetUser，更新初始化状态失败:", v)
 			}
 
-			// 交易员保证金信息
-			if lessThanOrEqualZero(tmpTraderBaseMoney, 1e-7) {
-				log.Println("SetUser，交易员保证金不足为0：", tmpTraderBaseMoney, v.Id)
-				continue
-			}
-
 			// 保证金信息
 			if lessThanOrEqualZero(tmpAmount, 1e-7) {
 				log.Println("SetUser，保证金不足为0：", tmpAmount, v.Id)
 				continue
 			}
 
-			// 仓位
-			s.Position.Iterator(func(symbolKey string, vPosition interface{}) bool {
-				tmpInsertData := vPosition.(*TraderPosition)
-
-				// 这里有正负之分
-				if floatEqual(tmpInsertData.PositionAmount, 0, 1e-7) {
-					return true
+			// 按跟单的交易员初始化
+			for traderId, traderNum := range tmpUserTraderMap[v.Id] {
+				tmpTrader := s.Traders.Get(int(traderId))
+				if nil == tmpTrader {
+					log.Println("SetUser，交易员不存在：", traderId, v.Id)
+					continue
 				}
 
-				symbolMapKey := v.Plat + tmpInsertData.Symbol
-				if !s.SymbolsMap.Contains(symbolMapKey) {
-					log.Println("SetUser，代币信息无效，信息", tmpInsertData, v)
-					return true
+				trader := tmpTrader.(*Trader)
+				// 交易员保证金信息
+				tmpTraderBaseMoney := trader.Money.Val()
+				if lessThanOrEqualZero(tmpTraderBaseMoney, 1e-7) {
+					log.Println("SetUser，交易员保证金不足为0：", tmpTraderBaseMoney, v.Id, trader.Id)
+					continue
 				}
 
-				// 下单，不用计算数量，新仓位
-				var (
-					binanceOrderRes *entity.BinanceOrder
-					orderInfoRes    *entity.BinanceOrderInfo
-				)
+				tmpTraderAmount := tmpAmount * traderNum
+				// 仓位
+				trader.Position.Iterator(func(symbolKey string, vPosition interface{}) bool {
+					tmpInsertData := vPosition.(*TraderPosition)
 
-				if "binance" == v.Plat {
+					// 这里有正负之分
+					if floatEqual(tmpInsertData.PositionAmount, 0, 1e-7) {
+						return true
+					}
+
+					symbolMapKey := v.Plat + tmpInsertData.Symbol
+					if !s.SymbolsMap.Contains(symbolMapKey) {
+						log.Println("SetUser，代币信息无效，信息", tmpInsertData, v)
+						return true
+					}
+
+					if !symbolTradable(s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol)) {
+						log.Println("SetUser，交易对不可交易，信息", tmpInsertData, v)
+						return true
+					}
+
+					// 下单，不用计算数量，新仓位
 					var (
-						tmpQty        float64
-						quantity      string
-						quantityFloat float64
-						side          string
-						positionSide  string
-						orderType     = "MARKET"
+						binanceOrderRes *entity.BinanceOrder
+						orderInfoRes    *entity.BinanceOrderInfo
 					)
 
-					//if "BOTH" == tmpUserPositionSide {
-					//	// 单向持仓
-					//	if "BOTH" == tmpInsertData.PositionSide {
-					//		if math.Signbit(tmpInsertData.PositionAmount) {
-					//			positionSide = "BOTH"
-					//			side = "SELL"
-					//		} else {
-					//			positionSide = "BOTH"
-					//			side = "BUY"
-					//		}
-					//	} else {
-					//		return true
-					//	}
-					//} else
-
-					if "ALL" == tmpUserPositionSide {
-						// 双向持仓
-						if "LONG" == tmpInsertData.PositionSide {
-							positionSide = "LONG"
-							side = "BUY"
-						} else if "SHORT" == tmpInsertData.PositionSide {
-							positionSide = "SHORT"
-							side = "SELL"
-						} else if "BOTH" == tmpInsertData.PositionSide {
-							// 如果带单员单向持仓
-							if math.Signbit(tmpInsertData.PositionAmount) {
+					if "binance" == v.Plat {
+						var (
+							tmpQty       float64
+							side         string
+							positionSide string
+							orderType    = "MARKET"
+						)
+
+						//if "BOTH" == tmpUserPositionSide {
+						//	// 单向持仓
+						//	if "BOTH" == tmpInsertData.PositionSide {
+						//		if math.Signbit(tmpInsertData.PositionAmount) {
+						//			positionSide = "BOTH"
+						//			side = "SELL"
+						//		} else {
+						//			positionSide = "BOTH"
+						//			side = "BUY"
+						//		}
+						//	} else {
+						//		return true
+						//	}
+						//} else
+
+						if "ALL" == tmpUserPositionSide {
+							// 双向持仓
+							if "LONG" == tmpInsertData.PositionSide {
+								positionSide = "LONG"
+								side = "BUY"
+							} else if "SHORT" == tmpInsertData.PositionSide {
 								positionSide = "SHORT"
 								side = "SELL"
+							} else if "BOTH" == tmpInsertData.PositionSide {
+								// 如果带单员单向持仓
+								if math.Signbit(tmpInsertData.PositionAmount) {
+									positionSide = "SHORT"
+									side = "SELL"
+								} else {
+									positionSide = "LONG"
+									side = "BUY"
+								}
 							} else {
-								positionSide = "LONG"
-								side = "BUY"
+								return true
 							}
+
 						} else {
+							log.Println("SetUser，持续方向信息无效，信息", tmpInsertData, v, tmpUserPositionSide)
 							return true
 						}
 
-					} else {
-						log.Println("SetUser，持续方向信息无效，信息", tmpInsertData, v, tmpUserPositionSide)
-						return true
-					}
-
-					tmpPositionAmount := math.Abs(tmpInsertData.PositionAmount)
-					// 本次 代单员币的数量 * (用户保证金/代单员保证金)
-					tmpQty = tmpPositionAmount * tmpAmount / tmpTraderBaseMoney // 本次开单数量
+						tmpPositionAmount := math.Abs(tmpInsertData.PositionAmount)
+						// 本次 代单员币的数量 * (用户保证金/代单员保证金)
+						tmpQty = tmpPositionAmount * tmpTraderAmount / tmpTraderBaseMoney // 本次开单数量
 
-					// 精度调整
-					if 0 >= s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantityPrecision {
-						quantity = fmt.Sprintf("%d", int64(tmpQty))
-					} else {
-						quantity = strconv.FormatFloat(tmpQty, 'f', s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantityPrecision, 64)
-					}
-
-					quantityFloat, err = strconv.ParseFloat(quantity, 64)
-					if nil != err {
-						log.Println("SetUser，精度转化", err, quantity)
-						return true
-					}
+						// 按交易规则调整数量
+						quantities, _, reason := s.normalizeQuantity(symbolMapKey, tmpInsertData.Symbol, tmpQty, false)
+						if 0 >= len(quantities) {
+							log.Println("SetUser，数量不满足交易规则，跳过", v, tmpInsertData, reason)
+							return true
+						}
 
-					if lessThanOrEqualZero(quantityFloat, 1e-7) {
-						return true
-					}
+						if 0 < len(reason) {
+							log.Println("SetUser，数量调整", v, tmpInsertData, reason)
+						}
 
-					// 请求下单
-					binanceOrderRes, orderInfoRes, err = service.Binance().RequestBinanceOrder(tmpInsertData.Symbol, side, orderType, positionSide, quantity, v.ApiKey, v.ApiSecret, false)
-					if nil != err {
-						log.Println("SetUser，下单", v, err, binanceOrderRes, orderInfoRes, tmpInsertData)
-						return true
-					}
+						// 请求下单，以实际成交为准
+						var fill *entity.OrderFill
+						fill, binanceOrderRes, orderInfoRes, err = s.placeBinanceOrders(v, tmpInsertData.Symbol, side, orderType, positionSide, quantities, false, uniqueClientId(clientIdInit, int(v.Id)), false)
+						if lessThanOrEqualZero(fill.ExecutedQty, 1e-7) {
+							log.Println("SetUser，下单", v, err, binanceOrderRes, orderInfoRes, tmpInsertData)
+							return true
+						}
 
-					//binanceOrderRes = &entity.BinanceOrder{
-					//	OrderId:       1,
-					//	ExecutedQty:   quantity,
-					//	ClientOrderId: "",
-					//	Symbol:        "",
-					//	AvgPrice:      "",
-					//	CumQuote:      "",
-					//	Side:          side,
-					//	PositionSide:  positionSide,
-					//	ClosePosition: false,
-					//	Type:          orderType,
-					//	Status:        "",
-					//}
-
-					// 下单异常
-					if 0 >= binanceOrderRes.OrderId {
-						log.Println("SetUser，下单，订单id为0", v, err, binanceOrderRes, orderInfoRes, tmpInsertData)
-						return true
+						//binanceOrderRes = &entity.BinanceOrder{
+						//	OrderId:       1,
+						//	ExecutedQty:   quantity,
+						//	ClientOrderId: "",
+						//	Symbol:        "",
+						//	AvgPrice:      "",
+						//	CumQuote:      "",
+						//	Side:          side,
+						//	PositionSide:  positionSide,
+						//	ClosePosition: false,
+						//	Type:          orderType,
+						//	Status:        "",
+						//}
+
+						var tmpExecutedQty float64
+						tmpExecutedQty = fill.ExecutedQty
+
+						//if "BOTH" == positionSide {
+						//	if "SELL" == side {
+						//		tmpExecutedQty = -tmpExecutedQty
+						//	}
+						//}
+
+						// 不存在新增，这里只能是开仓
+						s.setOrderMapFill(ctx, orderMapKey(tmpInsertData.Symbol, positionSide, v.Id, trader.Id), tmpExecutedQty, fill)
+					} else if "gate" == v.Plat {
+						//if 0 >= s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantoMultiplier {
+						//	log.Println("SetUser，代币信息无效，信息", tmpInsertData, v)
+						//	return true
+						//}
+						//
+						//var (
+						//	tmpQty        float64
+						//	gateRes       gateapi.FuturesOrder
+						//	side          string
+						//	symbol        = s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).Symbol + "_USDT"
+						//	positionSide  string
+						//	quantity      string
+						//	quantityInt64 int64
+						//	quantityFloat float64
+						//	reduceOnly    bool
+						//)
+						//
+						//tmpPositionAmount := math.Abs(tmpInsertData.PositionAmount)
+						//// 本次 代单员币的数量 * (用户保证金/代单员保证金)
+						//tmpQty = tmpPositionAmount * tmpAmount / tmpTraderBaseMoney // 本次开单数量
+						//
+						//// 转化为张数=币的数量/每张币的数量
+						//tmpQtyOkx := tmpQty / s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantoMultiplier
+						//// 按张的精度转化，
+						//quantityInt64 = int64(math.Round(tmpQtyOkx))
+						//quantityFloat = float64(quantityInt64)
+						//if lessThanOrEqualZero(quantityFloat, 1e-7) {
+						//	log.Println("SetUser，开仓数量小于0，信息", tmpInsertData, v, quantityFloat)
+						//	return true
+						//}
+						//
+						//tmpExecutedQty := quantityFloat
+						//if "BOTH" == tmpUserPositionSide {
+						//	// 单向持仓
+						//	if "BOTH" == tmpInsertData.PositionSide {
+						//		if math.Signbit(tmpInsertData.PositionAmount) {
+						//			positionSide = "BOTH"
+						//			side = "SELL"
+						//
+						//			quantityFloat = -quantityFloat
+						//			quantityInt64 = -quantityInt64
+						//		} else {
+						//			positionSide = "BOTH"
+						//			side = "BUY"
+						//		}
+						//	} else {
+						//		return true
+						//	}
+						//
+						//	quantity = strconv.FormatFloat(quantityFloat, 'f', -1, 64)
+						//
+						//	gateRes, err = service.Gate().PlaceBothOrderGate(v.ApiKey, v.ApiSecret, symbol, quantityInt64, reduceOnly, false)
+						//	if nil != err {
+						//		log.Println("SetUser，gate，下单错误", err, tmpInsertData, v, quantity, quantityInt64, gateRes)
+						//		return true
+						//	}
+						//
+						//	if 0 >= gateRes.Id {
+						//		log.Println("SetUser，gate，下单错误", err, tmpInsertData, v, quantity, quantityInt64, gateRes)
+						//		return true
+						//	}
+						//} else if "ALL" == tmpUserPositionSide {
+						//	// 双向持仓
+						//	if "LONG" == tmpInsertData.PositionSide {
+						//		positionSide = "LONG"
+						//		side = "BUY"
+						//	} else if "SHORT" == tmpInsertData.PositionSide {
+						//		positionSide = "SHORT"
+						//		side = "SELL"
+						//
+						//		quantityFloat = -quantityFloat
+						//		quantityInt64 = -quantityInt64
+						//	} else {
+						//		return true
+						//	}
+						//
+						//	quantity = strconv.FormatFloat(quantityFloat, 'f', -1, 64)
+						//
+						//	gateRes, err = service.Gate().PlaceOrderGate(v.ApiKey, v.ApiSecret, symbol, quantityInt64, reduceOnly, "")
+						//	if nil != err {
+						//		log.Println("SetUser，gate，下单错误", err, tmpInsertData, v, quantity, quantityInt64, gateRes)
+						//		return true
+						//	}
+						//
+						//	if 0 >= gateRes.Id {
+						//		log.Println("SetUser，gate，下单错误", err, tmpInsertData, v, quantity, quantityInt64, gateRes)
+						//		return true
+						//	}
+						//} else {
+						//	log.Println("SetUser，持续方向信息无效，信息", tmpInsertData, v, tmpUserPositionSide)
+						//	return true
+						//}
+						//
+						//if "BOTH" == positionSide {
+						//	if "SELL" == side {
+						//		tmpExecutedQty = -tmpExecutedQty
+						//	}
+						//}
+						//// 不存在新增，这里只能是开仓
+						//s.OrderMap.Set(tmpInsertData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
 					}
 
-					var tmpExecutedQty float64
-					tmpExecutedQty = quantityFloat
-
-					//if "BOTH" == positionSide {
-					//	if "SELL" == side {
-					//		tmpExecutedQty = -tmpExecutedQty
-					//	}
-					//}
-
-					// 不存在新增，这里只能是开仓
-					s.OrderMap.Set(tmpInsertData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
-				} else if "gate" == v.Plat {
-					//if 0 >= s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantoMultiplier {
-					//	log.Println("SetUser，代币信息无效，信息", tmpInsertData, v)
-					//	return true
-					//}
-					//
-					//var (
-					//	tmpQty        float64
-					//	gateRes       gateapi.FuturesOrder
-					//	side          string
-					//	symbol        = s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).Symbol + "_USDT"
-					//	positionSide  string
-					//	quantity      string
-					//	quantityInt64 int64
-					//	quantityFloat float64
-					//	reduceOnly    bool
-					//)
-					//
-					//tmpPositionAmount := math.Abs(tmpInsertData.PositionAmount)
-					//// 本次 代单员币的数量 * (用户保证金/代单员保证金)
-					//tmpQty = tmpPositionAmount * tmpAmount / tmpTraderBaseMoney // 本次开单数量
-					//
-					//// 转化为张数=币的数量/每张币的数量
-					//tmpQtyOkx := tmpQty / s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantoMultiplier
-					//// 按张的精度转化，
-					//quantityInt64 = int64(math.Round(tmpQtyOkx))
-					//quantityFloat = float64(quantityInt64)
-					//if lessThanOrEqualZero(quantityFloat, 1e-7) {
-					//	log.Println("SetUser，开仓数量小于0，信息", tmpInsertData, v, quantityFloat)
-					//	return true
-					//}
-					//
-					//tmpExecutedQty := quantityFloat
-					//if "BOTH" == tmpUserPositionSide {
-					//	// 单向持仓
-					//	if "BOTH" == tmpInsertData.PositionSide {
-					//		if math.Signbit(tmpInsertData.PositionAmount) {
-					//			positionSide = "BOTH"
-					//			side = "SELL"
-					//
-					//			quantityFloat = -quantityFloat
-					//			quantityInt64 = -quantityInt64
-					//		} else {
-					//			positionSide = "BOTH"
-					//			side = "BUY"
-					//		}
-					//	} else {
-					//		return true
-					//	}
-					//
-					//	quantity = strconv.FormatFloat(quantityFloat, 'f', -1, 64)
-					//
-					//	gateRes, err = service.Gate().PlaceBothOrderGate(v.ApiKey, v.ApiSecret, symbol, quantityInt64, reduceOnly, false)
-					//	if nil != err {
-					//		log.Println("SetUser，gate，下单错误", err, tmpInsertData, v, quantity, quantityInt64, gateRes)
-					//		return true
-					//	}
-					//
-					//	if 0 >= gateRes.Id {
-					//		log.Println("SetUser，gate，下单错误", err, tmpInsertData, v, quantity, quantityInt64, gateRes)
-					//		return true
-					//	}
-					//} else if "ALL" == tmpUserPositionSide {
-					//	// 双向持仓
-					//	if "LONG" == tmpInsertData.PositionSide {
-					//		positionSide = "LONG"
-					//		side = "BUY"
-					//	} else if "SHORT" == tmpInsertData.PositionSide {
-					//		positionSide = "SHORT"
-					//		side = "SELL"
-					//
-					//		quantityFloat = -quantityFloat
-					//		quantityInt64 = -quantityInt64
-					//	} else {
-					//		return true
-					//	}
-					//
-					//	quantity = strconv.FormatFloat(quantityFloat, 'f', -1, 64)
-					//
-					//	gateRes, err = service.Gate().PlaceOrderGate(v.ApiKey, v.ApiSecret, symbol, quantityInt64, reduceOnly, "")
-					//	if nil != err {
-					//		log.Println("SetUser，gate，下单错误", err, tmpInsertData, v, quantity, quantityInt64, gateRes)
-					//		return true
-					//	}
-					//
-					//	if 0 >= gateRes.Id {
-					//		log.Println("SetUser，gate，下单错误", err, tmpInsertData, v, quantity, quantityInt64, gateRes)
-					//		return true
-					//	}
-					//} else {
-					//	log.Println("SetUser，持续方向信息无效，信息", tmpInsertData, v, tmpUserPositionSide)
-					//	return true
-					//}
-					//
-					//if "BOTH" == positionSide {
-					//	if "SELL" == side {
-					//		tmpExecutedQty = -tmpExecutedQty
-					//	}
-					//}
-					//// 不存在新增，这里只能是开仓
-					//s.OrderMap.Set(tmpInsertData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
-				}
-
-				return true
-			})
+					return true
+				})
+			}
 		} else {
 			//if "binance" == v.Plat {
 			//	var (
@@ -856,6 +1279,7 @@ func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 	for _, vTmpIds := range tmpIds {
 		log.Println("SetUser，删除用户，解除队列绑定，队列close时，对应的监听协程会自动结束:", vTmpIds)
 		s.Users.Remove(vTmpIds)
+		s.UsersTraders.Remove(vTmpIds)
 
 		// 删除任务
 		err = service.OrderQueue().UnBindUserAndQueue(vTmpIds)
@@ -867,20 +1291,13 @@ func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 		tmpRemoveUserKey := make([]string, 0)
 		// 遍历map
 		s.OrderMap.Iterator(func(k interface{}, v interface{}) bool {
-			parts := strings.Split(k.(string), "&")
-			if 3 != len(parts) {
+			_, _, uid, _, parseErr := parseOrderMapKey(k.(string))
+			if nil != parseErr {
+				log.Println("SetUser，删除用户,解析id错误:", vTmpIds, parseErr)
 				return true
 			}
 
-			var (
-				uid uint64
-			)
-			uid, err = strconv.ParseUint(parts[2], 10, 64)
-			if nil != err {
-				log.Println("SetUser，删除用户,解析id错误:", vTmpIds)
-			}
-
-			if uid != uint64(vTmpIds) {
+			if uid != uint(vTmpIds) {
 				return true
 			}
 
@@ -890,7 +1307,7 @@ func (s *sListenAndOrder) SetUser(ctx context.Context) (err error) {
 
 		for _, vK := range tmpRemoveUserKey {
 			if s.OrderMap.Contains(vK) {
-				s.OrderMap.Remove(vK)
+				s.removeOrderMap(ctx, vK)
 			}
 		}
 	}
@@ -1035,6 +1452,89 @@ func (s *sListenAndOrder) HandleBothPositions(ctx context.Context) {
 
 }
 
+// getUserTraderNum 用户跟单交易员的系数，未跟单为0
+func (s *sListenAndOrder) getUserTraderNum(userId int, traderId uint) float64 {
+	tmpUserTraders := s.UsersTraders.Get(userId)
+	if nil == tmpUserTraders {
+		return 0
+	}
+
+	return tmpUserTraders.(map[uint]float64)[traderId]
+}
+
+// otherTraderHolds 用户同一交易对和持仓方向是否还有其他交易员的跟单仓位
+func (s *sListenAndOrder) otherTraderHolds(symbol, positionSide string, userId, traderId uint) bool {
+	var (
+		prefix = symbol + "&" + positionSide + "&" + strconv.FormatUint(uint64(userId), 10) + "&"
+		self   = orderMapKey(symbol, positionSide, userId, traderId)
+		res    bool
+	)
+	s.OrderMap.RLockFunc(func(m map[interface{}]interface{}) {
+		for k, v := range m {
+			key, ok := k.(string)
+			if !ok || self == key || !strings.HasPrefix(key, prefix) {
+				continue
+			}
+
+			if amount, ok := v.(float64); ok && !floatEqual(amount, 0, 1e-7) {
+				res = true
+				return
+			}
+		}
+	})
+
+	return res
+}
+
+// pushTraderSignal 记录向跟单该交易员的用户推送的信号，orderId、tradeId为来源订单，校正信号为0。
+// 调用方持有orderMu，信号在unlockOrder释放锁后推送，落库不占用仓位锁
+func (s *sListenAndOrder) pushTraderSignal(trader *Trader, msg *entity.OrderInfo, orderId, tradeId int64) {
+	msg.TraderId = trader.Id
+	msg.OrderId = orderId
+	msg.TradeId = tradeId
+	s.journalSignal(trader.Id, msg)
+
+	trader.signals = append(trader.signals, msg)
+}
+
+// unlockOrder 释放orderMu，再推送持有期间产生的信号，先取得pushMu保证推送顺序与仓位更新顺序一致
+func (s *sListenAndOrder) unlockOrder(trader *Trader) {
+	signals := trader.signals
+	trader.signals = nil
+
+	trader.pushMu.Lock()
+	defer trader.pushMu.Unlock()
+	trader.orderMu.Unlock()
+
+	for _, msg := range signals {
+		s.dispatchTraderSignal(trader, msg)
+	}
+}
+
+// dispatchTraderSignal 向跟单该交易员的用户推送，同一信号一次落库
+func (s *sListenAndOrder) dispatchTraderSignal(trader *Trader, msg *entity.OrderInfo) {
+	userIds := make([]int, 0)
+	s.UsersTraders.Iterator(func(userId int, v interface{}) bool {
+		if _, ok := v.(map[uint]float64)[trader.Id]; ok {
+			userIds = append(userIds, userId)
+		}
+
+		return true
+	})
+
+	if nil != s.dispatch {
+		for _, userId := range userIds {
+			s.dispatch(userId, msg)
+		}
+
+		return
+	}
+
+	if 0 < len(userIds) {
+		service.OrderQueue().PushQueues(userIds, msg)
+	}
+}
+
 // OrderAtPlat 在平台下单
 func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoValue) {
 	//log.Println("OrderAtPlat :", doValue)
@@ -1047,14 +1547,47 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 	}
 
 	user := tmpUser.(*entity.User)
-	strUserId := strconv.FormatUint(uint64(doValue.UserId), 10)
 	symbolMapKey := user.Plat + currentData.Symbol
 	if !s.SymbolsMap.Contains(symbolMapKey) {
 		log.Println("OrderAtPlat，不存在交易对:", user, currentData)
 		return
 	}
 
-	traderMoney := s.TraderMoney.Val()
+	if !symbolTradable(s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol)) {
+		log.Println("OrderAtPlat，交易对不可交易:", user, currentData, s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).Status)
+		return
+	}
+
+	tmpTrader := s.Traders.Get(int(currentData.TraderId))
+	if nil == tmpTrader {
+		log.Println("OrderAtPlat，不存在交易员:", user, currentData)
+		return
+	}
+
+	trader := tmpTrader.(*Trader)
+	traderNum := s.getUserTraderNum(doValue.UserId, trader.Id)
+	if lessThanOrEqualZero(traderNum, 1e-7) {
+		log.Println("OrderAtPlat，用户未跟单交易员:", user, currentData)
+		return
+	}
+
+	// 同一信号每个用户只执行一次，校正信号无来源订单不去重，下单成功后才记录。
+	// 重放或再次投递的信号可能已经下单，下单前先按clientOrderId查询
+	var (
+		dedupKey   string
+		queryFirst bool
+	)
+	if 0 < currentData.OrderId {
+		dedupKey = userSignalDedupKey(doValue.UserId, currentData)
+		if s.UsersSignalDedup.Has(dedupKey) {
+			log.Println("OrderAtPlat，重复信号，忽略:", user, currentData)
+			return
+		}
+
+		queryFirst = !s.UsersSignalTried.Add(dedupKey) || doValue.Replay
+	}
+
+	traderMoney := trader.Money.Val()
 	if lessThanOrEqualZero(traderMoney, 1e-7) {
 		log.Println("OrderAtPlat，交易员保证金错误:", user, currentData, traderMoney)
 		return
@@ -1066,15 +1599,15 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 		return
 	}
 
-	userMoney := userMoneyTmp.(float64)
+	// 用户保证金 * 跟单该交易员的系数
+	userMoney := userMoneyTmp.(float64) * traderNum
 	if lessThanOrEqualZero(userMoney, 1e-7) {
 		log.Println("OrderAtPlat，用户保证金错误:", user, currentData, userMoney)
 		return
 	}
 
-	//log.Println("测试", currentData, traderMoney, userMoney, s.OrderMap.Get(currentData.Symbol+"&"+currentData.PositionSide+"&"+strUserId))
-
-	tmp := s.OrderMap.Get(currentData.Symbol + "&" + currentData.PositionSide + "&" + strUserId)
+	userPositionKey := orderMapKey(currentData.Symbol, currentData.PositionSide, user.Id, trader.Id)
+	tmp := s.OrderMap.Get(userPositionKey)
 	var userPositionAmount float64
 	if nil != tmp {
 		userPositionAmount = tmp.(float64)
@@ -1317,6 +1850,9 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 			side         = currentData.Side
 			symbolGate   = s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).Symbol + "_USDT"
 			positionSide = currentData.PositionSide
+			orderText    = gateText(signalClientId(doValue.UserId, currentData))
+			// gate全平会平掉用户在该方向的整个仓位，其他交易员也有跟单仓位时只按本交易员的仓位数量只减仓
+			sharedGate = s.otherTraderHolds(currentData.Symbol, currentData.PositionSide, user.Id, trader.Id)
 		)
 
 		if "BOTH" == currentData.PositionSide {
@@ -1333,49 +1869,110 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 				}
 			}
 
-			gateRes, err = service.Gate().PlaceBothOrderGate(user.ApiKey, user.ApiSecret, symbolGate, quantityInt64Gate, reduceOnly, closeGate)
+			if closeGate && sharedGate {
+				closeGate = false
+				quantityInt64Gate = -int64(math.Round(userPositionAmount))
+				quantityFloatGate = float64(quantityInt64Gate)
+				if 0 == quantityInt64Gate {
+					log.Println("OrderAtPlat，Gate平仓张数为0:", user, currentData, userPositionAmount)
+					return
+				}
+			}
+
+			gateRes, err = s.placeGateOrder(user, symbolGate, orderText, queryFirst, func() (gateapi.FuturesOrder, error) {
+				return userGate(user).PlaceBothOrderGate(user.ApiKey, user.ApiSecret, symbolGate, quantityInt64Gate, reduceOnly, closeGate, orderText)
+			})
 			if nil != err || 0 >= gateRes.Id {
 				log.Println("OrderAtPlat，Gate下单:", user, currentData, gateRes, reduceOnly, closePosition, quantityInt64Gate, symbolGate, closeStatus)
 				return
 			}
 
+			// 以实际成交张数为准，全平按仓位数量记录
+			fill, fillErr := userGate(user).GateOrderFill(user.ApiKey, user.ApiSecret, gateRes)
+			if nil != fillErr {
+				log.Println("OrderAtPlat，Gate确认成交:", user, currentData, gateRes, fill, fillErr)
+			}
+
+			if 0 != quantityInt64Gate && nil != fill {
+				if lessThanOrEqualZero(fill.ExecutedQty, 1e-7) {
+					log.Println("OrderAtPlat，Gate未成交:", user, currentData, gateRes, fill)
+					return
+				}
+
+				tmpExecutedQtyGate = math.Copysign(fill.ExecutedQty, tmpExecutedQtyGate)
+			}
+
+			s.addUserSignalDedup(dedupKey)
+
 			// 不存在新增，这里只能是开仓
-			if !s.OrderMap.Contains(currentData.Symbol + "&" + positionSide + "&" + strUserId) {
+			if !s.OrderMap.Contains(userPositionKey) {
 				// 追加仓位，开仓
-				s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQtyGate)
+				s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQtyGate, fill)
 			} else {
-				if "CLOSE" == closeStatus {
+				if "CLOSE" == closeStatus && closeGate {
 					tmpExecutedQtyGate = 0
 				} else {
 					tmpExecutedQtyGate = userPositionAmount + tmpExecutedQtyGate
 				}
 
-				s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQtyGate)
+				s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQtyGate, fill)
 			}
 
 		} else {
-			gateRes, err = service.Gate().PlaceOrderGate(user.ApiKey, user.ApiSecret, symbolGate, quantityInt64Gate, reduceOnly, closePosition)
+			if "CLOSE" == closeStatus && sharedGate {
+				closePosition = ""
+				quantityInt64Gate = int64(math.Round(userPositionAmount))
+				if "LONG" == positionSide {
+					quantityInt64Gate = -quantityInt64Gate
+				}
+				quantityFloatGate = float64(quantityInt64Gate)
+				if 0 == quantityInt64Gate {
+					log.Println("OrderAtPlat，Gate平仓张数为0:", user, currentData, userPositionAmount)
+					return
+				}
+			}
+
+			gateRes, err = s.placeGateOrder(user, symbolGate, orderText, queryFirst, func() (gateapi.FuturesOrder, error) {
+				return userGate(user).PlaceOrderGate(user.ApiKey, user.ApiSecret, symbolGate, quantityInt64Gate, reduceOnly, closePosition, orderText)
+			})
 			if nil != err || 0 >= gateRes.Id {
 				log.Println("OrderAtPlat，Gate下单:", user, currentData, gateRes, reduceOnly, closePosition, quantityInt64Gate, symbolGate)
 				return
 			}
 
+			// 以实际成交张数为准，全平按仓位数量记录
+			fill, fillErr := userGate(user).GateOrderFill(user.ApiKey, user.ApiSecret, gateRes)
+			if nil != fillErr {
+				log.Println("OrderAtPlat，Gate确认成交:", user, currentData, gateRes, fill, fillErr)
+			}
+
+			if 0 != quantityInt64Gate && nil != fill {
+				if lessThanOrEqualZero(fill.ExecutedQty, 1e-7) {
+					log.Println("OrderAtPlat，Gate未成交:", user, currentData, gateRes, fill)
+					return
+				}
+
+				tmpExecutedQtyGate = math.Copysign(fill.ExecutedQty, tmpExecutedQtyGate)
+			}
+
+			s.addUserSignalDedup(dedupKey)
+
 			// 不存在新增，这里只能是开仓
-			if !s.OrderMap.Contains(currentData.Symbol + "&" + positionSide + "&" + strUserId) {
+			if !s.OrderMap.Contains(userPositionKey) {
 				// 追加仓位，开仓
-				s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQtyGate)
+				s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQtyGate, fill)
 			} else {
 				// 追加仓位，开仓
 				if "LONG" == positionSide {
 					if "BUY" == side {
-						tmpExecutedQtyGate += s.OrderMap.Get(currentData.Symbol + "&" + positionSide + "&" + strUserId).(float64)
-						s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQtyGate)
+						tmpExecutedQtyGate += s.OrderMap.Get(userPositionKey).(float64)
+						s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQtyGate, fill)
 					} else if "SELL" == side {
-						tmpExecutedQtyGate = s.OrderMap.Get(currentData.Symbol+"&"+positionSide+"&"+strUserId).(float64) - tmpExecutedQtyGate
+						tmpExecutedQtyGate = s.OrderMap.Get(userPositionKey).(float64) - tmpExecutedQtyGate
 						if lessThanOrEqualZero(tmpExecutedQtyGate, 1e-7) {
 							tmpExecutedQtyGate = 0
 						}
-						s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQtyGate)
+						s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQtyGate, fill)
 					} else {
 						log.Println("OrderAtPlat，Gate下单，数据存储:", user, currentData, gateRes, reduceOnly, closePosition, quantityInt64Gate, symbolGate, tmpExecutedQtyGate)
 
@@ -1383,14 +1980,14 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 
 				} else if "SHORT" == positionSide {
 					if "SELL" == side {
-						tmpExecutedQtyGate += s.OrderMap.Get(currentData.Symbol + "&" + positionSide + "&" + strUserId).(float64)
-						s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQtyGate)
+						tmpExecutedQtyGate += s.OrderMap.Get(userPositionKey).(float64)
+						s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQtyGate, fill)
 					} else if "BUY" == side {
-						tmpExecutedQtyGate = s.OrderMap.Get(currentData.Symbol+"&"+positionSide+"&"+strUserId).(float64) - tmpExecutedQtyGate
+						tmpExecutedQtyGate = s.OrderMap.Get(userPositionKey).(float64) - tmpExecutedQtyGate
 						if lessThanOrEqualZero(tmpExecutedQtyGate, 1e-7) {
 							tmpExecutedQtyGate = 0
 						}
-						s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQtyGate)
+						s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQtyGate, fill)
 					} else {
 						log.Println("OrderAtPlat，Gate下单，数据存储:", user, currentData, gateRes, reduceOnly, closePosition, quantityInt64Gate, symbolGate, tmpExecutedQtyGate)
 					}
@@ -1403,29 +2000,21 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 		}
 
 	} else if "binance" == user.Plat {
-		// 精度调整
+		// 按交易规则调整数量
 		var (
-			quantity       string
+			quantities     []string
 			quantityFloat  float64
+			reason         string
 			err            error
 			side           = currentData.Side
 			orderType      = "MARKET"
 			positionSide   = currentData.PositionSide
 			tmpExecutedQty float64 // 结果有正负both 其他持仓仓位模式正
+			reducing       = reduceOnlyBinance || bothPartClose || ("LONG" == positionSide && "SELL" == side) || ("SHORT" == positionSide && "BUY" == side)
 		)
-		if 0 >= s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantityPrecision {
-			quantity = fmt.Sprintf("%d", int64(currentAmount))
-		} else {
-			quantity = strconv.FormatFloat(currentAmount, 'f', s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantityPrecision, 64)
-		}
-
-		quantityFloat, err = strconv.ParseFloat(quantity, 64)
-		if nil != err {
-			log.Println("OrderAtPlat，数量解析:", user, currentData, s.UsersPositionSide.Get(doValue.UserId), quantity)
-			return
-		}
-
-		if lessThanOrEqualZero(quantityFloat, 1e-7) {
+		quantities, quantityFloat, reason = s.normalizeQuantity(symbolMapKey, currentData.Symbol, currentAmount, reducing)
+		if 0 >= len(quantities) {
+			log.Println("OrderAtPlat，数量不满足交易规则，跳过:", user, currentData, reason)
 			return
 		}
 		tmpExecutedQty = quantityFloat
@@ -1439,19 +2028,9 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 					return
 				}
 
-				if 0 >= s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantityPrecision {
-					quantity = fmt.Sprintf("%d", int64(currentAmount))
-				} else {
-					quantity = strconv.FormatFloat(currentAmount, 'f', s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantityPrecision, 64)
-				}
-
-				quantityFloat, err = strconv.ParseFloat(quantity, 64)
-				if nil != err {
-					log.Println("OrderAtPlat，数量解析:", user, currentData, s.UsersPositionSide.Get(doValue.UserId), quantity)
-					return
-				}
-
-				if lessThanOrEqualZero(quantityFloat, 1e-7) {
+				quantities, quantityFloat, reason = s.normalizeQuantity(symbolMapKey, currentData.Symbol, currentAmount, true)
+				if 0 >= len(quantities) {
+					log.Println("OrderAtPlat，数量不满足交易规则，跳过:", user, currentData, reason)
 					return
 				}
 
@@ -1461,37 +2040,31 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 			}
 		}
 
+		if 0 < len(reason) {
+			log.Println("OrderAtPlat，数量调整:", user, currentData, reason)
+		}
+
 		// 下单，不用计算数量，新仓位
 		var (
 			binanceOrderRes *entity.BinanceOrder
 			orderInfoRes    *entity.BinanceOrderInfo
+			fill            *entity.OrderFill
 		)
 
-		// 请求下单
-		binanceOrderRes, orderInfoRes, err = service.Binance().RequestBinanceOrder(currentData.Symbol, side, orderType, positionSide, quantity, user.ApiKey, user.ApiSecret, reduceOnlyBinance)
-		if nil != err {
-			log.Println("OrderAtPlat，下单错误:", user, currentData, binanceOrderRes, orderInfoRes, err, quantity)
+		// 请求下单，以实际成交为准，拆分中断或部分成交时按已成交数量记录
+		fill, binanceOrderRes, orderInfoRes, err = s.placeBinanceOrders(user, currentData.Symbol, side, orderType, positionSide, quantities, reduceOnlyBinance, signalClientId(doValue.UserId, currentData), queryFirst)
+		tmpExecutedQty = fill.ExecutedQty
+		if lessThanOrEqualZero(tmpExecutedQty, 1e-7) {
+			log.Println("OrderAtPlat，下单错误:", user, currentData, binanceOrderRes, orderInfoRes, err, quantities)
+			s.handleOrderError(ctx, user, userPositionKey, currentData.Symbol, side, positionSide, err)
 			return
 		}
 
-		//binanceOrderRes = &entity.BinanceOrder{
-		//	OrderId:       1,
-		//	ExecutedQty:   quantity,
-		//	ClientOrderId: "",
-		//	Symbol:        "",
-		//	AvgPrice:      "",
-		//	CumQuote:      "",
-		//	Side:          side,
-		//	PositionSide:  positionSide,
-		//	ClosePosition: false,
-		//	Type:          orderType,
-		//	Status:        "",
-		//}
+		s.addUserSignalDedup(dedupKey)
 
-		// 下单异常
-		if 0 >= binanceOrderRes.OrderId {
-			log.Println(orderInfoRes)
-			return // 返回
+		// 未全部成交，不能按全平记录
+		if "CLOSE" == closeStatus && !floatEqual(tmpExecutedQty, quantityFloat, 1e-9) {
+			closeStatus = "PART"
 		}
 
 		if "BOTH" == positionSide && "SELL" == side {
@@ -1499,13 +2072,13 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 		}
 
 		// 不存在新增，这里只能是开仓
-		if !s.OrderMap.Contains(currentData.Symbol + "&" + positionSide + "&" + strUserId) {
-			s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+		if !s.OrderMap.Contains(userPositionKey) {
+			s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQty, fill)
 		} else {
 			// 追加仓位，开仓
 			if "LONG" == positionSide {
 				if "BUY" == side {
-					d1 := decimal.NewFromFloat(s.OrderMap.Get(currentData.Symbol + "&" + positionSide + "&" + strUserId).(float64))
+					d1 := decimal.NewFromFloat(s.OrderMap.Get(userPositionKey).(float64))
 					d2 := decimal.NewFromFloat(tmpExecutedQty)
 					result := d1.Add(d2)
 
@@ -1515,9 +2088,9 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 						fmt.Println("转换过程中可能发生了精度损失", tmpExecutedQty)
 					}
 
-					s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+					s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQty, fill)
 				} else if "SELL" == side {
-					d1 := decimal.NewFromFloat(s.OrderMap.Get(currentData.Symbol + "&" + positionSide + "&" + strUserId).(float64))
+					d1 := decimal.NewFromFloat(s.OrderMap.Get(userPositionKey).(float64))
 					d2 := decimal.NewFromFloat(tmpExecutedQty)
 					result := d1.Sub(d2)
 
@@ -1530,14 +2103,14 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 					if lessThanOrEqualZero(tmpExecutedQty, 1e-7) {
 						tmpExecutedQty = 0
 					}
-					s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+					s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQty, fill)
 				} else {
 					log.Println("OrderAtPlat，binance下单，数据存储:", user, currentData, binanceOrderRes, orderInfoRes, tmpExecutedQty)
 				}
 
 			} else if "SHORT" == positionSide {
 				if "SELL" == side {
-					d1 := decimal.NewFromFloat(s.OrderMap.Get(currentData.Symbol + "&" + positionSide + "&" + strUserId).(float64))
+					d1 := decimal.NewFromFloat(s.OrderMap.Get(userPositionKey).(float64))
 					d2 := decimal.NewFromFloat(tmpExecutedQty)
 					result := d1.Add(d2)
 
@@ -1547,9 +2120,9 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 						fmt.Println("转换过程中可能发生了精度损失", tmpExecutedQty)
 					}
 
-					s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+					s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQty, fill)
 				} else if "BUY" == side {
-					d1 := decimal.NewFromFloat(s.OrderMap.Get(currentData.Symbol + "&" + positionSide + "&" + strUserId).(float64))
+					d1 := decimal.NewFromFloat(s.OrderMap.Get(userPositionKey).(float64))
 					d2 := decimal.NewFromFloat(tmpExecutedQty)
 					result := d1.Sub(d2)
 
@@ -1562,7 +2135,7 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 					if lessThanOrEqualZero(tmpExecutedQty, 1e-7) {
 						tmpExecutedQty = 0
 					}
-					s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+					s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQty, fill)
 				} else {
 					log.Println("OrderAtPlat，binance下单，数据存储:", user, currentData, binanceOrderRes, orderInfoRes, tmpExecutedQty)
 				}
@@ -1582,7 +2155,7 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 					}
 				}
 
-				s.OrderMap.Set(currentData.Symbol+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+				s.setOrderMapFill(ctx, userPositionKey, tmpExecutedQty, fill)
 			} else {
 				log.Println("OrderAtPlat，binance下单，数据存储:", user, currentData, binanceOrderRes, orderInfoRes, tmpExecutedQty)
 			}
@@ -1593,23 +2166,16 @@ func (s *sListenAndOrder) OrderAtPlat(ctx context.Context, doValue *entity.DoVal
 		return
 	}
 
-	log.Println("仓位信息：", currentData.Symbol+"&"+currentData.PositionSide+"&"+strUserId, s.OrderMap.Get(currentData.Symbol+"&"+currentData.PositionSide+"&"+strUserId))
+	log.Println("仓位信息：", userPositionKey, s.OrderMap.Get(userPositionKey))
 	return
 }
 
-// Run 监控仓位 pulls binance data and orders
-func (s *sListenAndOrder) Run(ctx context.Context) {
+// initTraderPosition 初始化交易员仓位
+func initTraderPosition(trader *Trader, binancePosition []*entity.BinancePosition) {
 	var (
-		err             error
-		binancePosition []*entity.BinancePosition
+		err error
 	)
 
-	binancePosition = service.Binance().GetBinancePositionInfo(s.TraderInfo.apiKey, s.TraderInfo.apiSecret)
-	if nil == binancePosition {
-		log.Println("错误查询仓位")
-		return
-	}
-
 	// 用于数据库更新
 	insertData := make([]*TraderPosition, 0)
 
@@ -1627,7 +2193,7 @@ func (s *sListenAndOrder) Run(ctx context.Context) {
 		}
 		currentAmountAbs = math.Abs(currentAmount) // 绝对值
 
-		if !s.Position.Contains(position.Symbol + position.PositionSide) {
+		if !trader.Position.Contains(position.Symbol + position.PositionSide) {
 			// 以下内容，当系统无此仓位时
 			if "BOTH" != position.PositionSide {
 				insertData = append(insertData, &TraderPosition{
@@ -1652,7 +2218,7 @@ func (s *sListenAndOrder) Run(ctx context.Context) {
 	if 0 < len(insertData) {
 		// 新增数据
 		for _, vIBinancePosition := range insertData {
-			s.Position.Set(vIBinancePosition.Symbol+vIBinancePosition.PositionSide, &TraderPosition{
+			trader.Position.Set(vIBinancePosition.Symbol+vIBinancePosition.PositionSide, &TraderPosition{
 				Symbol:         vIBinancePosition.Symbol,
 				PositionSide:   vIBinancePosition.PositionSide,
 				PositionAmount: vIBinancePosition.PositionAmount,
@@ -1661,26 +2227,26 @@ func (s *sListenAndOrder) Run(ctx context.Context) {
 	}
 
 	// 仓位补足系统
-	s.Position.Iterator(func(k string, v interface{}) bool {
+	trader.Position.Iterator(func(k string, v interface{}) bool {
 		vPosition := v.(*TraderPosition)
-		if !s.Position.Contains(vPosition.Symbol + "BOTH") {
-			s.Position.Set(vPosition.Symbol+"BOTH", &TraderPosition{
+		if !trader.Position.Contains(vPosition.Symbol + "BOTH") {
+			trader.Position.Set(vPosition.Symbol+"BOTH", &TraderPosition{
 				Symbol:         vPosition.Symbol,
 				PositionSide:   "BOTH",
 				PositionAmount: 0,
 			})
 		}
 
-		if !s.Position.Contains(vPosition.Symbol + "LONG") {
-			s.Position.Set(vPosition.Symbol+"LONG", &TraderPosition{
+		if !trader.Position.Contains(vPosition.Symbol + "LONG") {
+			trader.Position.Set(vPosition.Symbol+"LONG", &TraderPosition{
 				Symbol:         vPosition.Symbol,
 				PositionSide:   "LONG",
 				PositionAmount: 0,
 			})
 		}
 
-		if !s.Position.Contains(vPosition.Symbol + "SHORT") {
-			s.Position.Set(vPosition.Symbol+"SHORT", &TraderPosition{
+		if !trader.Position.Contains(vPosition.Symbol + "SHORT") {
+			trader.Position.Set(vPosition.Symbol+"SHORT", &TraderPosition{
 				Symbol:         vPosition.Symbol,
 				PositionSide:   "SHORT",
 				PositionAmount: 0,
@@ -1689,440 +2255,727 @@ func (s *sListenAndOrder) Run(ctx context.Context) {
 
 		return true
 	})
+}
+
+// Run 监控交易员仓位 pulls binance data and orders
+func (s *sListenAndOrder) Run(ctx context.Context, traderId uint) {
+	var (
+		err error
+	)
+
+	tmpTrader := s.Traders.Get(int(traderId))
+	if nil == tmpTrader {
+		log.Println("Run，交易员不存在", traderId)
+		return
+	}
+
+	trader := tmpTrader.(*Trader)
+
+	// 限价成交合并窗口，例如300ms，不配置则逐笔跟单
+	limitCoalesce := g.Cfg().MustGet(ctx, "limitCoalesce").Duration()
 
 	// Refresh listen key every 29 minutes
 	handleRenewListenKey := func(ctx context.Context) {
-		err = service.Binance().RenewListenKey(s.TraderInfo.apiKey)
+		apiKey, _ := trader.credentials()
+		err = service.Binance().RenewListenKey(apiKey)
 		if err != nil {
-			log.Println("Error renewing listen key:", err)
+			log.Println("Error renewing listen key:", trader.Id, err)
 		}
 	}
-	gtimer.AddSingleton(ctx, time.Minute*29, handleRenewListenKey)
+	renewEntry := gtimer.AddSingleton(ctx, time.Minute*29, handleRenewListenKey)
 
-	// Create listen key and connect to WebSocket
-	connect := func(ctx context.Context) {
-		for retry := 0; retry < 30; retry++ {
-			err = service.Binance().CreateListenKey(s.TraderInfo.apiKey)
-			if err != nil {
-				log.Println("Error creating listen key:", err)
-				continue
-			}
-
-			// Connect WebSocket initially
-			err = service.Binance().ConnectWebSocket()
-			if err != nil {
-				log.Println("Error connecting WebSocket:", err)
-				continue
-			}
+	// 主动ping，半开连接由读超时发现
+	pingEntry := gtimer.AddSingleton(ctx, streamPingPeriod, func(ctx context.Context) {
+		s.pingTrader(trader)
+	})
 
-			break
+	// listenKey连接24小时后会被断开，提前更换
+	connectEntry := gtimer.AddSingleton(ctx, time.Hour*23, func(ctx context.Context) {
+		err := s.connectTrader(trader)
+		if nil != err {
+			log.Println("Error connecting trader:", trader.Id, err)
 		}
+	})
+
+	defer func() {
+		renewEntry.Close()
+		pingEntry.Close()
+		connectEntry.Close()
+		trader.swapConn(nil)
+	}()
 
+	if !s.reconnectTrader(ctx, trader) {
 		return
 	}
 
-	connect(ctx)
-	gtimer.AddSingleton(ctx, time.Hour*23, connect)
+	// Listen for WebSocket messages
+	for {
+		if nil != ctx.Err() {
+			log.Println("Run，交易员停止监听", trader.Id)
+			return
+		}
 
-	defer func(conn *websocket.Conn) {
-		err = conn.Close()
-		if err != nil {
+		conn := trader.getConn()
+		if nil == conn {
+			if !s.reconnectTrader(ctx, trader) {
+				return
+			}
 
+			continue
 		}
-	}(binance.Conn)
 
-	// Listen for WebSocket messages
-	for {
 		var message []byte
-		_, message, err = binance.Conn.ReadMessage()
+		_, message, err = conn.ReadMessage()
 		if err != nil {
-			log.Println("Read error:", err, time.Now())
+			// 连接已被替换（定时更换或更换api），继续读新连接
+			if conn != trader.getConn() {
+				continue
+			}
+
+			log.Println("Read error:", trader.Id, err, time.Now())
+			if !s.reconnectTrader(ctx, trader) {
+				return
+			}
 
-			// 可能是23小时的更换conn
-			time.Sleep(100 * time.Millisecond)
 			continue
 		}
 
-		var event *entity.OrderTradeUpdate
-		if err = json.Unmarshal(message, &event); err != nil {
-			log.Println("Failed to parse message:", err, string(message), time.Now())
-			continue
+		_ = conn.SetReadDeadline(time.Now().Add(streamReadWait))
+		s.journalEvent(trader.Id, message)
+
+		if s.dispatchStreamMessage(ctx, trader, message, limitCoalesce) {
+			if !s.reconnectTrader(ctx, trader) {
+				return
+			}
 		}
+	}
+}
 
-		if event.EventType != "ORDER_TRADE_UPDATE" {
-			continue
+// dispatchStreamMessage 处理一条用户数据推送，返回是否需要重新连接
+func (s *sListenAndOrder) dispatchStreamMessage(ctx context.Context, trader *Trader, message []byte, limitCoalesce time.Duration) bool {
+	var baseEvent *entity.StreamEvent
+	if err := json.Unmarshal(message, &baseEvent); err != nil || nil == baseEvent {
+		log.Println("Failed to parse message:", err, string(message), time.Now())
+		return false
+	}
+
+	// listenKey过期，连接不再有推送，重新创建
+	if "listenKeyExpired" == baseEvent.EventType {
+		log.Println("listenKey过期，重新连接:", trader.Id)
+		return true
+	}
+
+	// 账户仓位推送，以pa为准校正交易员仓位
+	if "ACCOUNT_UPDATE" == baseEvent.EventType {
+		var accountEvent *entity.AccountUpdateEvent
+		if err := json.Unmarshal(message, &accountEvent); err != nil {
+			log.Println("Failed to parse account update:", err, string(message), time.Now())
+			return false
 		}
 
-		//log.Println(3, event, "\n\n\n")
+		s.handleAccountUpdate(trader, accountEvent)
+		return false
+	}
 
-		if "MARKET" == event.Order.OriginalOrderType {
-			// 市价
-			if !("NEW" == event.Order.ExecutionType && "NEW" == event.Order.OrderStatus) {
-				continue
-			}
+	if baseEvent.EventType != "ORDER_TRADE_UPDATE" {
+		return false
+	}
 
-			log.Println("市价，new：", event)
-			// 客户端下单
+	var event *entity.OrderTradeUpdate
+	if err := json.Unmarshal(message, &event); err != nil {
+		log.Println("Failed to parse message:", err, string(message), time.Now())
+		return false
+	}
 
-		} else if "LIMIT" == event.Order.OriginalOrderType {
-			// 限价 开始交易，我们的反应是全部执行市价，开或关
-			if "TRADE" != event.Order.ExecutionType {
-				continue
-			}
+	s.handleOrderTradeUpdate(ctx, trader, event, limitCoalesce)
 
-			if "PARTIALLY_FILLED" != event.Order.OrderStatus && "FILLED" != event.Order.OrderStatus {
-				continue
-			}
+	// 订单结束，已跟单的数量不再等待成交。对应的账户推送可能晚于订单推送到达，
+	// 此时的账户仓位还未包含本次成交，不在这里校正，等事件时间不早于订单结束的账户推送再校正
+	if "FILLED" == event.Order.OrderStatus || "CANCELED" == event.Order.OrderStatus || "EXPIRED" == event.Order.OrderStatus || "EXPIRED_IN_MATCH" == event.Order.OrderStatus {
+		// 合并中的成交先跟单
+		s.flushLimitFill(trader, event.Order.OrderID)
+
+		if trader.PendingOrders.Contains(event.Order.OrderID) {
+			tmpPending := trader.PendingOrders.Remove(event.Order.OrderID).(*PendingOrder)
+			trader.advancePositionTime(tmpPending.Key, event.EventTime)
+		}
+	}
 
-			log.Println("限价，trade：", event)
+	return false
+}
 
-			// 只要第一单，情况1一次成交完，情况2部分成交第一单，这两种情况的值相等
-			if event.Order.LastExecutedQty != event.Order.CumulativeExecutedQty {
-				continue
-			}
+// handleOrderTradeUpdate 筛选需要跟单的订单推送，市价单NEW，限价单和触发后的条件单每笔成交
+func (s *sListenAndOrder) handleOrderTradeUpdate(ctx context.Context, trader *Trader, event *entity.OrderTradeUpdate, limitCoalesce time.Duration) {
+	if "MARKET" == event.Order.OriginalOrderType {
+		// 市价
+		if !("NEW" == event.Order.ExecutionType && "NEW" == event.Order.OrderStatus) {
+			return
+		}
+
+		log.Println("市价，new：", event)
+		// 客户端下单
+
+	} else if "LIMIT" == event.Order.OriginalOrderType {
+		// 限价 每笔成交按成交数量执行市价，开或关
+		if "TRADE" != event.Order.ExecutionType {
+			return
+		}
+
+		if "PARTIALLY_FILLED" != event.Order.OrderStatus && "FILLED" != event.Order.OrderStatus {
+			return
+		}
+
+		log.Println("限价，trade：", event)
+
+	} else if isConditionalOrderType(event.Order.OriginalOrderType) {
+		// 止损止盈、跟踪止损 触发后按成交数量执行市价，cp平仓单下单数量为0
+		if "TRADE" != event.Order.ExecutionType {
+			return
+		}
+
+		if "PARTIALLY_FILLED" != event.Order.OrderStatus && "FILLED" != event.Order.OrderStatus {
+			return
+		}
+
+		log.Println("条件单触发，trade：", event, event.Order.StopPrice, event.Order.IsClosePosition, event.Order.ActivatePrice, event.Order.CallbackRate)
+
+	} else {
+		return
+	}
+
+	// 同一订单的同一成交只处理一次，重连或重放的推送直接丢弃
+	if !s.SignalDedup.Add(signalDedupKey(trader.Id, event.Order.Symbol, event.Order.OrderID, event.Order.TradeID, event.Order.ExecutionType)) {
+		log.Println("重复推送，忽略：", trader.Id, event)
+		return
+	}
 
+	// 限价单开启合并时，一段时间内的成交合并为一单
+	if "LIMIT" == event.Order.OriginalOrderType && 0 < limitCoalesce {
+		s.coalesceLimitFill(ctx, trader, event, limitCoalesce)
+		return
+	}
+
+	s.handleTraderOrder(trader, event)
+}
+
+// isConditionalOrderType 条件单类型，触发后才有成交
+func isConditionalOrderType(orderType string) bool {
+	switch orderType {
+	case "STOP_MARKET", "TAKE_PROFIT_MARKET", "TRAILING_STOP_MARKET", "STOP", "TAKE_PROFIT":
+		return true
+	}
+
+	return false
+}
+
+// handleTraderOrder 更新交易员仓位并向跟单用户推送
+func (s *sListenAndOrder) handleTraderOrder(trader *Trader, event *entity.OrderTradeUpdate) {
+	var (
+		err error
+	)
+
+	trader.orderMu.Lock()
+	defer s.unlockOrder(trader)
+
+	// 交易员持仓模式以推送为准，单向BOTH，双向LONG，SHORT
+	if "BOTH" == event.Order.PositionSide && "BOTH" != trader.PositionSide.Val() {
+		log.Println("持仓方向变更，单向，trade：", event, trader.PositionSide.Val())
+		trader.PositionSide.Set("BOTH")
+	} else if "BOTH" != event.Order.PositionSide && "ALL" != trader.PositionSide.Val() {
+		log.Println("持仓方向变更，双向，trade：", event, trader.PositionSide.Val())
+		trader.PositionSide.Set("ALL")
+	}
+
+	var (
+		side         string
+		oQ           float64 // 本次的数量
+		PositionSide string
+		status       = "OPEN"
+	)
+	// 市价按下单数量，限价和条件单按本次成交数量
+	qty := event.Order.OriginalQty
+	if "MARKET" != event.Order.OriginalOrderType {
+		qty = event.Order.LastExecutedQty
+	}
+
+	oQ, err = strconv.ParseFloat(qty, 64)
+	if nil != err {
+		log.Println("解析金额出错，信息", event)
+		return
+	}
+	if lessThanOrEqualZero(oQ, 1e-7) {
+		log.Println("解析金额，下单数字太小，信息", event)
+		return
+	}
+
+	if "SELL" == event.Order.OrderSide {
+		if "BOTH" == event.Order.PositionSide {
+			oQ = -oQ
+			PositionSide = "BOTH"
+		} else if "LONG" == event.Order.PositionSide {
+			PositionSide = "LONG"
+		} else if "SHORT" == event.Order.PositionSide {
+			PositionSide = "SHORT"
 		} else {
-			continue
+			log.Println("解析持仓方向出错，信息", event)
+			return
 		}
 
-		// 源方持仓向要和下单方向一致，BOTH和LONG，SHORT
-		if "BOTH" == event.Order.PositionSide && "BOTH" != s.TraderPositionSide.Val() {
-			log.Println("持仓方向不一致，trade：", event, s.TraderPositionSide.Val())
-			continue
-		} else if "BOTH" != event.Order.PositionSide && "BOTH" == s.TraderPositionSide.Val() {
-			log.Println("持仓方向不一致，trade：", event, s.TraderPositionSide.Val())
-			continue
+		side = "SELL"
+	} else if "BUY" == event.Order.OrderSide {
+		if "BOTH" == event.Order.PositionSide {
+			PositionSide = "BOTH"
+		} else if "LONG" == event.Order.PositionSide {
+			PositionSide = "LONG"
+		} else if "SHORT" == event.Order.PositionSide {
+			PositionSide = "SHORT"
+		} else {
+			log.Println("解析持仓方向出错，信息", event)
+			return
 		}
 
-		var (
-			//side         string
-			oQ           float64 // 本次的数量
-			PositionSide string
-			status       = "OPEN"
-		)
-		oQ, err = strconv.ParseFloat(event.Order.OriginalQty, 64)
-		if nil != err {
-			log.Println("解析金额出错，信息", event)
-			continue
+		side = "BUY"
+	} else {
+		log.Println("不识别的买卖，信息", event)
+		return
+	}
+
+	newPosition := &TraderPosition{
+		Symbol:         event.Order.Symbol,
+		PositionSide:   PositionSide,
+		PositionAmount: oQ,
+	}
+
+	var lastAmount float64
+	tmpPosition := trader.Position.Get(event.Order.Symbol + event.Order.PositionSide)
+	if nil == tmpPosition {
+		// 系统无此仓位，按0仓位处理
+		tmpPosition = &TraderPosition{
+			Symbol:         event.Order.Symbol,
+			PositionSide:   PositionSide,
+			PositionAmount: 0,
 		}
-		if lessThanOrEqualZero(oQ, 1e-7) {
-			log.Println("解析金额，下单数字太小，信息", event)
-			continue
+	}
+
+	tmpTraderPosition := tmpPosition.(*TraderPosition)
+	lastAmount = tmpTraderPosition.PositionAmount
+
+	if "BOTH" == PositionSide {
+		// 这里暂时不做处理，确保当前仓位符合实际和binance对的上 todo
+		newPosition.PositionAmount = tmpTraderPosition.PositionAmount + oQ
+		if floatEqual(newPosition.PositionAmount, 0, 1e-7) {
+			status = "CLOSE" // 完全平仓
+			newPosition.PositionAmount = 0
 		}
 
-		if "SELL" == event.Order.OrderSide {
-			if "BOTH" == event.Order.PositionSide {
-				oQ = -oQ
-				PositionSide = "BOTH"
-			} else {
-				log.Println("解析持仓方向出错，信息", event)
-				continue
+	} else if "LONG" == PositionSide {
+		if "SELL" == side {
+			// 保障关仓要有仓位
+			if lessThanOrEqualZero(tmpTraderPosition.PositionAmount, 1e-7) {
+				log.Println("交易员无此无仓位，信息", event)
+				return
 			}
 
-			//if "LONG" == event.Order.PositionSide {
-			//	PositionSide = "LONG"
-			//} else if "SHORT" == event.Order.PositionSide {
-			//	PositionSide = "SHORT"
-			//} else {
-			//	log.Println("解析持仓方向出错，信息", event)
-			//	continue
-			//}
-
-			//side = "SELL"
-		} else if "BUY" == event.Order.OrderSide {
-			if "BOTH" == event.Order.PositionSide {
-				PositionSide = "BOTH"
-			} else {
-				log.Println("解析持仓方向出错，信息", event)
-				continue
+			newPosition.PositionAmount = tmpTraderPosition.PositionAmount - oQ
+			if lessThanOrEqualZero(newPosition.PositionAmount, 1e-7) {
+				status = "CLOSE" // 完全平仓
+				newPosition.PositionAmount = 0
 			}
+		} else {
+			newPosition.PositionAmount = tmpTraderPosition.PositionAmount + oQ
+		}
 
-			//if "LONG" == event.Order.PositionSide {
-			//	PositionSide = "LONG"
-			//} else if "SHORT" == event.Order.PositionSide {
-			//	PositionSide = "SHORT"
-			//} else {
-			//	log.Println("解析持仓方向出错，信息", event)
-			//	continue
-			//}
+	} else if "SHORT" == PositionSide {
+		if "BUY" == side {
+			// 保障关仓要有仓位
+			if lessThanOrEqualZero(tmpTraderPosition.PositionAmount, 1e-7) {
+				log.Println("交易员无此无仓位，信息", event)
+				return
+			}
 
-			//side = "BUY"
+			newPosition.PositionAmount = tmpTraderPosition.PositionAmount - oQ
+			if lessThanOrEqualZero(newPosition.PositionAmount, 1e-7) {
+				status = "CLOSE" // 完全平仓
+				newPosition.PositionAmount = 0
+			}
 		} else {
-			log.Println("不识别的买卖，信息", event)
-			continue
+			newPosition.PositionAmount = tmpTraderPosition.PositionAmount + oQ
 		}
 
-		newPosition := &TraderPosition{
-			Symbol:         event.Order.Symbol,
-			PositionSide:   PositionSide,
-			PositionAmount: oQ,
-		}
+	} else {
+		log.Println("不识别的仓位方向2，信息", event)
+		return
+	}
 
-		var lastAmount float64
-		tmpPosition := s.Position.Get(event.Order.Symbol + event.Order.PositionSide)
-		if nil != tmpPosition {
-			tmpTraderPosition := tmpPosition.(*TraderPosition)
-			lastAmount = tmpTraderPosition.PositionAmount
+	// 新仓位
+	trader.Position.Set(event.Order.Symbol+event.Order.PositionSide, newPosition)
 
-			if "BOTH" == PositionSide {
-				// 这里暂时不做处理，确保当前仓位符合实际和binance对的上 todo
-				newPosition.PositionAmount = tmpTraderPosition.PositionAmount + oQ
-				if floatEqual(newPosition.PositionAmount, 0, 1e-7) {
-					status = "CLOSE" // 完全平仓
-					newPosition.PositionAmount = 0
-				}
+	// 未完全成交，等待成交后再以账户仓位校正
+	if "NEW" == event.Order.OrderStatus || "PARTIALLY_FILLED" == event.Order.OrderStatus {
+		trader.PendingOrders.Set(event.Order.OrderID, &PendingOrder{
+			Key:       event.Order.Symbol + event.Order.PositionSide,
+			CreatedAt: time.Now(),
+		})
+	}
 
-			} else {
-				log.Println("不识别的仓位方向2，信息", event)
-				continue
+	// 只有BOTH仓处理，并且全部模拟为双向持仓
+	if "BOTH" == PositionSide {
+		// 全平仓
+		if "CLOSE" == status {
+			// 上一次无仓位
+			if floatEqual(lastAmount, 0, 1e-7) {
+				log.Println("仓位似乎不太对，信息1", event, newPosition)
+				return
 			}
 
-			//if "LONG" == PositionSide {
-			//	if "SELL" == side {
-			//		// 保障关仓要有仓位
-			//		if lessThanOrEqualZero(tmpTraderPosition.PositionAmount, 1e-7) {
-			//			log.Println("交易员无此无仓位，信息", event)
-			//			continue
-			//		}
-			//
-			//		newPosition.PositionAmount = tmpTraderPosition.PositionAmount - oQ
-			//		if lessThanOrEqualZero(newPosition.PositionAmount, 1e-7) {
-			//			status = "CLOSE" // 完全平仓
-			//			newPosition.PositionAmount = 0
-			//		}
-			//	} else if "BUY" == side {
-			//		newPosition.PositionAmount = tmpTraderPosition.PositionAmount + oQ
-			//		if lessThanOrEqualZero(newPosition.PositionAmount, 1e-7) {
-			//			status = "CLOSE" // 完全平仓
-			//			newPosition.PositionAmount = 0
-			//		}
-			//	}
-			//
-			//} else if "SHORT" == PositionSide {
-			//	if "SELL" == side {
-			//		newPosition.PositionAmount = tmpTraderPosition.PositionAmount + oQ
-			//		if lessThanOrEqualZero(newPosition.PositionAmount, 1e-7) {
-			//			status = "CLOSE" // 完全平仓
-			//			newPosition.PositionAmount = 0
-			//		}
-			//	} else if "BUY" == side {
-			//		// 保障关仓要有仓位
-			//		if lessThanOrEqualZero(tmpTraderPosition.PositionAmount, 1e-7) {
-			//			log.Println("交易员无此无仓位，信息", event)
-			//			continue
-			//		}
-			//
-			//		newPosition.PositionAmount = tmpTraderPosition.PositionAmount - oQ
-			//		if lessThanOrEqualZero(newPosition.PositionAmount, 1e-7) {
-			//			status = "CLOSE" // 完全平仓
-			//			newPosition.PositionAmount = 0
-			//		}
-			//	}
-			//
-			//} else {
-			//	log.Println("不识别的仓位方向2，信息", event)
-			//	continue
-			//}
-		}
+			// 平仓数是0
+			if !floatEqual(newPosition.PositionAmount, 0, 1e-7) {
+				log.Println("仓位似乎不太对，信息2", event, newPosition)
+				return
+			}
 
-		// 新仓位
-		s.Position.Set(event.Order.Symbol+event.Order.PositionSide, newPosition)
+			// 平空仓
+			tmpMsg := &entity.OrderInfo{
+				Symbol:     newPosition.Symbol,
+				Amount:     0,
+				LastAmount: math.Abs(lastAmount),
+				Oq:         math.Abs(lastAmount),
+				Status:     "CLOSE",
+			}
 
-		// 只有BOTH仓处理，并且全部模拟为双向持仓
-		if "BOTH" == PositionSide {
-			// 全平仓
-			if "CLOSE" == status {
-				// 上一次无仓位
-				if floatEqual(lastAmount, 0, 1e-7) {
-					log.Println("仓位似乎不太对，信息1", event, newPosition)
-					continue
-				}
+			if math.Signbit(lastAmount) {
+				// 平空仓
+				tmpMsg.Side = "BUY"
+				tmpMsg.PositionSide = "SHORT"
+			} else {
+				// 平多仓
+				tmpMsg.Side = "SELL"
+				tmpMsg.PositionSide = "LONG"
+			}
 
-				// 平仓数是0
-				if !floatEqual(newPosition.PositionAmount, 0, 1e-7) {
-					log.Println("仓位似乎不太对，信息2", event, newPosition)
-					continue
+			log.Println("新仓位信息:", tmpMsg)
+			s.pushTraderSignal(trader, tmpMsg, event.Order.OrderID, event.Order.TradeID)
+		} else {
+			if floatEqual(lastAmount, 0, 1e-7) {
+				// 上一次无仓位，则是新开仓
+
+				// 当前仓位也是0，有点问题
+				if floatEqual(newPosition.PositionAmount, 0, 1e-7) {
+					log.Println("仓位似乎不太对，信息3", event, newPosition)
+					return
 				}
 
-				// 平空仓
 				tmpMsg := &entity.OrderInfo{
 					Symbol:     newPosition.Symbol,
-					Amount:     0,
-					LastAmount: math.Abs(lastAmount),
-					Oq:         math.Abs(lastAmount),
-					Status:     "CLOSE",
+					Amount:     math.Abs(newPosition.PositionAmount),
+					LastAmount: 0,
+					Oq:         math.Abs(newPosition.PositionAmount),
+					Status:     "OPEN",
 				}
 
-				if math.Signbit(lastAmount) {
-					// 平空仓
-					tmpMsg.Side = "BUY"
+				if math.Signbit(newPosition.PositionAmount) {
+					// 开空仓
+					tmpMsg.Side = "SELL"
 					tmpMsg.PositionSide = "SHORT"
 				} else {
-					// 平多仓
-					tmpMsg.Side = "SELL"
+					// 开多仓
+					tmpMsg.Side = "BUY"
 					tmpMsg.PositionSide = "LONG"
 				}
 
 				log.Println("新仓位信息:", tmpMsg)
-				service.OrderQueue().PushAllQueue(tmpMsg)
+				s.pushTraderSignal(trader, tmpMsg, event.Order.OrderID, event.Order.TradeID)
 			} else {
-				if floatEqual(lastAmount, 0, 1e-7) {
-					// 上一次无仓位，则是新开仓
+				// 上一次有仓位
 
-					// 当前仓位也是0，有点问题
-					if floatEqual(newPosition.PositionAmount, 0, 1e-7) {
-						log.Println("仓位似乎不太对，信息3", event, newPosition)
-						continue
-					}
+				// 当前仓位也是0，有点问题
+				if floatEqual(newPosition.PositionAmount, 0, 1e-7) {
+					log.Println("仓位似乎不太对，信息4，这里应该走完全平仓", event, newPosition)
+					return
+				}
+
+				if math.Signbit(lastAmount) && math.Signbit(newPosition.PositionAmount) {
+					// 上一次是负数，本次也是负数，追加仓位或平仓
 
 					tmpMsg := &entity.OrderInfo{
 						Symbol:     newPosition.Symbol,
 						Amount:     math.Abs(newPosition.PositionAmount),
-						LastAmount: 0,
-						Oq:         math.Abs(newPosition.PositionAmount),
+						LastAmount: math.Abs(lastAmount),
+						Oq:         math.Abs(newPosition.PositionAmount - lastAmount),
 						Status:     "OPEN",
 					}
 
-					if math.Signbit(newPosition.PositionAmount) {
-						// 开空仓
+					if !math.Signbit(newPosition.PositionAmount - lastAmount) {
+						// 仓位变少，部分平空
+						tmpMsg.Side = "BUY"
+						tmpMsg.PositionSide = "SHORT"
+					} else {
+						// 仓位变少，追加仓位
 						tmpMsg.Side = "SELL"
 						tmpMsg.PositionSide = "SHORT"
+					}
+
+					log.Println("新仓位信息:", tmpMsg)
+					s.pushTraderSignal(trader, tmpMsg, event.Order.OrderID, event.Order.TradeID)
+				} else if !math.Signbit(lastAmount) && !math.Signbit(newPosition.PositionAmount) {
+					// 上一次是正数，本次也是正数，追加仓位或平仓
+
+					tmpMsg := &entity.OrderInfo{
+						Symbol:     newPosition.Symbol,
+						Amount:     math.Abs(newPosition.PositionAmount),
+						LastAmount: math.Abs(lastAmount),
+						Oq:         math.Abs(newPosition.PositionAmount - lastAmount),
+						Status:     "OPEN",
+					}
+
+					if math.Signbit(newPosition.PositionAmount - lastAmount) {
+						// 仓位变少，部分平多
+						tmpMsg.Side = "SELL"
+						tmpMsg.PositionSide = "LONG"
 					} else {
-						// 开多仓
+						// 仓位变少，追加仓位
 						tmpMsg.Side = "BUY"
 						tmpMsg.PositionSide = "LONG"
 					}
 
 					log.Println("新仓位信息:", tmpMsg)
-					service.OrderQueue().PushAllQueue(tmpMsg)
-				} else {
-					// 上一次有仓位
+					s.pushTraderSignal(trader, tmpMsg, event.Order.OrderID, event.Order.TradeID)
+				} else if math.Signbit(lastAmount) && !math.Signbit(newPosition.PositionAmount) {
+					// 上一次是负数，本次也是正数
+
+					// 先平仓，平空
+					tmpMsgClose := &entity.OrderInfo{
+						Symbol:       newPosition.Symbol,
+						Amount:       0,
+						LastAmount:   math.Abs(lastAmount),
+						Oq:           math.Abs(lastAmount),
+						Status:       "CLOSE",
+						Side:         "BUY",
+						PositionSide: "SHORT",
+					}
 
-					// 当前仓位也是0，有点问题
-					if floatEqual(newPosition.PositionAmount, 0, 1e-7) {
-						log.Println("仓位似乎不太对，信息4，这里应该走完全平仓", event, newPosition)
-						continue
+					log.Println("新仓位信息，先平多仓:", tmpMsgClose)
+					s.pushTraderSignal(trader, tmpMsgClose, event.Order.OrderID, event.Order.TradeID)
+
+					// 再开仓，开多
+					tmpMsgOpen := &entity.OrderInfo{
+						Symbol:       newPosition.Symbol,
+						Amount:       math.Abs(newPosition.PositionAmount),
+						LastAmount:   0,
+						Oq:           math.Abs(newPosition.PositionAmount),
+						Status:       "OPEN",
+						Side:         "BUY",
+						PositionSide: "LONG",
 					}
 
-					if math.Signbit(lastAmount) && math.Signbit(newPosition.PositionAmount) {
-						// 上一次是负数，本次也是负数，追加仓位或平仓
+					log.Println("新仓位信息，后开平空仓:", tmpMsgOpen)
+					s.pushTraderSignal(trader, tmpMsgOpen, event.Order.OrderID, event.Order.TradeID)
+				} else if !math.Signbit(lastAmount) && math.Signbit(newPosition.PositionAmount) {
+					// 上一次是正数，本次也是负数
+
+					// 先平仓，平多
+					tmpMsgClose := &entity.OrderInfo{
+						Symbol:       newPosition.Symbol,
+						Amount:       0,
+						LastAmount:   math.Abs(lastAmount),
+						Oq:           math.Abs(lastAmount),
+						Status:       "CLOSE",
+						Side:         "SELL",
+						PositionSide: "LONG",
+					}
 
-						tmpMsg := &entity.OrderInfo{
-							Symbol:     newPosition.Symbol,
-							Amount:     math.Abs(newPosition.PositionAmount),
-							LastAmount: math.Abs(lastAmount),
-							Oq:         math.Abs(newPosition.PositionAmount - lastAmount),
-							Status:     "OPEN",
-						}
+					log.Println("新仓位信息，先平多仓:", tmpMsgClose)
+					s.pushTraderSignal(trader, tmpMsgClose, event.Order.OrderID, event.Order.TradeID)
+
+					// 再开仓，开空
+					tmpMsgOpen := &entity.OrderInfo{
+						Symbol:       newPosition.Symbol,
+						Amount:       math.Abs(newPosition.PositionAmount),
+						LastAmount:   0,
+						Oq:           math.Abs(newPosition.PositionAmount),
+						Status:       "OPEN",
+						Side:         "SELL",
+						PositionSide: "SHORT",
+					}
 
-						if !math.Signbit(newPosition.PositionAmount - lastAmount) {
-							// 仓位变少，部分平空
-							tmpMsg.Side = "BUY"
-							tmpMsg.PositionSide = "SHORT"
-						} else {
-							// 仓位变少，追加仓位
-							tmpMsg.Side = "SELL"
-							tmpMsg.PositionSide = "SHORT"
-						}
+					log.Println("新仓位信息，后开平空仓:", tmpMsgOpen)
+					s.pushTraderSignal(trader, tmpMsgOpen, event.Order.OrderID, event.Order.TradeID)
+				} else {
+					log.Println("不识别的操作，信息", event)
+				}
+			}
 
-						log.Println("新仓位信息:", tmpMsg)
-						service.OrderQueue().PushAllQueue(tmpMsg)
-					} else if !math.Signbit(lastAmount) && !math.Signbit(newPosition.PositionAmount) {
-						// 上一次是正数，本次也是正数，追加仓位或平仓
-
-						tmpMsg := &entity.OrderInfo{
-							Symbol:     newPosition.Symbol,
-							Amount:     math.Abs(newPosition.PositionAmount),
-							LastAmount: math.Abs(lastAmount),
-							Oq:         math.Abs(newPosition.PositionAmount - lastAmount),
-							Status:     "OPEN",
-						}
+		}
+	} else {
+		// 双向持仓，LONG，SHORT仓位均为正数
+		tmpMsg := hedgeOrderInfo(newPosition.Symbol, PositionSide, status, lastAmount, newPosition.PositionAmount, oQ)
+		if nil == tmpMsg {
+			log.Println("仓位似乎不太对，信息5", event, newPosition)
+			return
+		}
 
-						if math.Signbit(newPosition.PositionAmount - lastAmount) {
-							// 仓位变少，部分平多
-							tmpMsg.Side = "SELL"
-							tmpMsg.PositionSide = "LONG"
-						} else {
-							// 仓位变少，追加仓位
-							tmpMsg.Side = "BUY"
-							tmpMsg.PositionSide = "LONG"
-						}
+		log.Println("新仓位信息，双向:", tmpMsg)
+		s.pushTraderSignal(trader, tmpMsg, event.Order.OrderID, event.Order.TradeID)
+	}
+}
 
-						log.Println("新仓位信息:", tmpMsg)
-						service.OrderQueue().PushAllQueue(tmpMsg)
-					} else if math.Signbit(lastAmount) && !math.Signbit(newPosition.PositionAmount) {
-						// 上一次是负数，本次也是正数
-
-						// 先平仓，平空
-						tmpMsgClose := &entity.OrderInfo{
-							Symbol:       newPosition.Symbol,
-							Amount:       0,
-							LastAmount:   math.Abs(lastAmount),
-							Oq:           math.Abs(lastAmount),
-							Status:       "CLOSE",
-							Side:         "BUY",
-							PositionSide: "SHORT",
-						}
+// handleAccountUpdate 处理账户推送，pa为交易员真实仓位
+func (s *sListenAndOrder) handleAccountUpdate(trader *Trader, event *entity.AccountUpdateEvent) {
+	if nil == event {
+		return
+	}
 
-						log.Println("新仓位信息，先平多仓:", tmpMsgClose)
-						service.OrderQueue().PushAllQueue(tmpMsgClose)
-
-						// 再开仓，开多
-						tmpMsgOpen := &entity.OrderInfo{
-							Symbol:       newPosition.Symbol,
-							Amount:       math.Abs(newPosition.PositionAmount),
-							LastAmount:   0,
-							Oq:           math.Abs(newPosition.PositionAmount),
-							Status:       "OPEN",
-							Side:         "BUY",
-							PositionSide: "LONG",
-						}
+	for _, vPosition := range event.Account.Positions {
+		var (
+			err           error
+			currentAmount float64
+		)
+		currentAmount, err = strconv.ParseFloat(vPosition.PositionAmount, 64)
+		if nil != err {
+			log.Println("账户推送，解析仓位出错，信息", vPosition, event)
+			continue
+		}
 
-						log.Println("新仓位信息，后开平空仓:", tmpMsgOpen)
-						service.OrderQueue().PushAllQueue(tmpMsgOpen)
-					} else if !math.Signbit(lastAmount) && math.Signbit(newPosition.PositionAmount) {
-						// 上一次是正数，本次也是负数
-
-						// 先平仓，平多
-						tmpMsgClose := &entity.OrderInfo{
-							Symbol:       newPosition.Symbol,
-							Amount:       0,
-							LastAmount:   math.Abs(lastAmount),
-							Oq:           math.Abs(lastAmount),
-							Status:       "CLOSE",
-							Side:         "SELL",
-							PositionSide: "LONG",
-						}
+		// 双向持仓仓位为正数，单向持仓正负数保持
+		if "BOTH" != vPosition.PositionSide {
+			currentAmount = math.Abs(currentAmount)
+		}
 
-						log.Println("新仓位信息，先平多仓:", tmpMsgClose)
-						service.OrderQueue().PushAllQueue(tmpMsgClose)
-
-						// 再开仓，开空
-						tmpMsgOpen := &entity.OrderInfo{
-							Symbol:       newPosition.Symbol,
-							Amount:       math.Abs(newPosition.PositionAmount),
-							LastAmount:   0,
-							Oq:           math.Abs(newPosition.PositionAmount),
-							Status:       "OPEN",
-							Side:         "SELL",
-							PositionSide: "SHORT",
-						}
+		key := vPosition.Symbol + vPosition.PositionSide
 
-						log.Println("新仓位信息，后开平空仓:", tmpMsgOpen)
-						service.OrderQueue().PushAllQueue(tmpMsgOpen)
-					} else {
-						log.Println("不识别的操作，信息", event)
-					}
-				}
+		// 早于订单结束或上一次账户推送的仓位已过期
+		if !trader.advancePositionTime(key, event.EventTime) {
+			log.Println("账户推送，仓位事件时间早于最新记录，忽略：", trader.Id, key, event.EventTime)
+			continue
+		}
 
-			}
-		} else {
-			log.Println("不识别的仓位方向2，信息", event)
+		trader.AccountPosition.Set(key, &TraderPosition{
+			Symbol:         vPosition.Symbol,
+			PositionSide:   vPosition.PositionSide,
+			PositionAmount: currentAmount,
+		})
+
+		// 仍有订单未完成，等订单结束后再校正
+		if s.hasPendingOrder(trader, key) {
+			continue
+		}
+
+		s.checkAccountPosition(trader, key)
+	}
+}
+
+// hasPendingOrder 是否有已跟单未完成的订单，超时的订单直接丢弃
+func (s *sListenAndOrder) hasPendingOrder(trader *Trader, key string) bool {
+	var (
+		res        bool
+		expiredIds = make([]interface{}, 0)
+	)
+
+	trader.PendingOrders.Iterator(func(k interface{}, v interface{}) bool {
+		tmpPending := v.(*PendingOrder)
+		if time.Since(tmpPending.CreatedAt) > pendingOrderExpire {
+			expiredIds = append(expiredIds, k)
+			return true
+		}
+
+		if key == tmpPending.Key {
+			res = true
+		}
+
+		return true
+	})
+
+	if 0 < len(expiredIds) {
+		trader.PendingOrders.Removes(expiredIds)
+	}
+
+	return res
+}
+
+// checkAccountPosition 对比账户仓位和系统仓位，不一致时推送校正信息
+func (s *sListenAndOrder) checkAccountPosition(trader *Trader, key string) {
+	trader.orderMu.Lock()
+	defer s.unlockOrder(trader)
+
+	tmpAccountPosition := trader.AccountPosition.Get(key)
+	if nil == tmpAccountPosition {
+		return
+	}
+
+	accountPosition := tmpAccountPosition.(*TraderPosition)
+
+	var lastAmount float64
+	tmpPosition := trader.Position.Get(key)
+	if nil != tmpPosition {
+		lastAmount = tmpPosition.(*TraderPosition).PositionAmount
+	}
+
+	if floatEqual(lastAmount, accountPosition.PositionAmount, 1e-7) {
+		return
+	}
+
+	log.Println("仓位校正，系统仓位与账户不一致：", trader.Id, key, lastAmount, accountPosition.PositionAmount)
+	trader.Position.Set(key, &TraderPosition{
+		Symbol:         accountPosition.Symbol,
+		PositionSide:   accountPosition.PositionSide,
+		PositionAmount: accountPosition.PositionAmount,
+	})
+
+	for _, tmpMsg := range correctOrderInfos(accountPosition.Symbol, accountPosition.PositionSide, lastAmount, accountPosition.PositionAmount) {
+		log.Println("新仓位信息，校正:", tmpMsg)
+		s.pushTraderSignal(trader, tmpMsg, 0, 0)
+	}
+}
+
+// correctOrderInfos 仓位从lastAmount变为newAmount需要的下单信息，单向持仓拆分为多空两边，先平后开
+func correctOrderInfos(symbol, positionSide string, lastAmount, newAmount float64) []*entity.OrderInfo {
+	res := make([]*entity.OrderInfo, 0)
+
+	type positionLeg struct {
+		positionSide string
+		lastAmount   float64
+		newAmount    float64
+	}
+
+	legs := make([]*positionLeg, 0)
+	if "BOTH" == positionSide {
+		legs = append(legs,
+			&positionLeg{"LONG", math.Max(lastAmount, 0), math.Max(newAmount, 0)},
+			&positionLeg{"SHORT", math.Max(-lastAmount, 0), math.Max(-newAmount, 0)},
+		)
+	} else {
+		legs = append(legs, &positionLeg{positionSide, lastAmount, newAmount})
+	}
+
+	opens := make([]*entity.OrderInfo, 0)
+	for _, vLeg := range legs {
+		var (
+			legSide   = vLeg.positionSide
+			legLast   = vLeg.lastAmount
+			legNew    = vLeg.newAmount
+			legStatus = "OPEN"
+		)
+		if floatEqual(legLast, legNew, 1e-7) {
+			continue
+		}
+
+		if lessThanOrEqualZero(legNew, 1e-7) {
+			legStatus = "CLOSE"
 		}
 
-		continue
+		tmpMsg := hedgeOrderInfo(symbol, legSide, legStatus, legLast, legNew, legNew-legLast)
+		if nil == tmpMsg {
+			continue
+		}
+
+		if legNew > legLast {
+			opens = append(opens, tmpMsg)
+		} else {
+			res = append(res, tmpMsg)
+		}
 	}
 
+	return append(res, opens...)
 }
 
 // SetPositionSide set position side
@@ -2164,7 +3017,7 @@ func (s *sListenAndOrder) GetSystemUserNum(ctx context.Context) map[string]float
 }
 
 // CreateUser set user num
-func (s *sListenAndOrder) CreateUser(ctx context.Context, address, apiKey, apiSecret, plat string, needInit uint64, num float64) error {
+func (s *sListenAndOrder) CreateUser(ctx context.Context, address, apiKey, apiSecret, plat string, needInit uint64, num float64, paper uint64) error {
 	var (
 		users []*entity.User
 		err   error
@@ -2201,6 +3054,7 @@ func (s *sListenAndOrder) CreateUser(ctx context.Context, address, apiKey, apiSe
 		Plat:       plat,
 		Dai:        0,
 		Ip:         1,
+		Paper:      paper,
 	})
 
 	if nil != err {
@@ -2243,20 +3097,13 @@ func (s *sListenAndOrder) SetApiStatus(ctx context.Context, apiKey string, statu
 
 	canClose := true
 	s.OrderMap.Iterator(func(k interface{}, v interface{}) bool {
-		parts := strings.Split(k.(string), "&")
-		if 3 != len(parts) {
+		_, _, uid, _, parseErr := parseOrderMapKey(k.(string))
+		if nil != parseErr {
+			log.Println("查看用户仓位，解析id错误:", k, parseErr)
 			return true
 		}
 
-		var (
-			uid uint64
-		)
-		uid, err = strconv.ParseUint(parts[2], 10, 64)
-		if nil != err {
-			log.Println("查看用户仓位，解析id错误:", k)
-		}
-
-		if uid != uint64(users[0].Id) {
+		if uid != users[0].Id {
 			return true
 		}
 
@@ -2296,6 +3143,54 @@ func (s *sListenAndOrder) SetUseNewSystem(ctx context.Context, apiKey string, us
 	return nil
 }
 
+// SetUserTrader set user trader
+func (s *sListenAndOrder) SetUserTrader(ctx context.Context, apiKey string, traderId uint64, num float64, status uint64) error {
+	var (
+		err         error
+		users       []*entity.User
+		userTraders []*entity.UserTrader
+	)
+
+	err = g.Model("user").Where("api_key=?", apiKey).Ctx(ctx).Scan(&users)
+	if nil != err {
+		log.Println("用户跟单交易员，数据库查询错误：", err)
+		return err
+	}
+
+	if 0 >= len(users) || 0 >= users[0].Id {
+		return errors.New("用户不存在")
+	}
+
+	err = g.Model("user_trader").Where("user_id=? and trader_id=?", users[0].Id, traderId).Ctx(ctx).Scan(&userTraders)
+	if nil != err {
+		log.Println("用户跟单交易员，数据库查询错误：", err)
+		return err
+	}
+
+	if 0 < len(userTraders) {
+		_, err = g.Model("user_trader").Ctx(ctx).
+			Data(g.Map{"num": num, "status": status, "updated_at": gtime.Now()}).
+			Where("id=?", userTraders[0].Id).
+			Update()
+	} else {
+		_, err = g.Model("user_trader").Ctx(ctx).Insert(&do.UserTrader{
+			UserId:    users[0].Id,
+			TraderId:  traderId,
+			Num:       num,
+			Status:    status,
+			CreatedAt: gtime.Now(),
+			UpdatedAt: gtime.Now(),
+		})
+	}
+
+	if nil != err {
+		log.Println("用户跟单交易员，更新失败：", err)
+		return err
+	}
+
+	return nil
+}
+
 // GetSystemUserPositions get user positions
 func (s *sListenAndOrder) GetSystemUserPositions(ctx context.Context, apiKey string) map[string]float64 {
 	var (
@@ -2317,27 +3212,19 @@ func (s *sListenAndOrder) GetSystemUserPositions(ctx context.Context, apiKey str
 
 	// 遍历map
 	s.OrderMap.Iterator(func(k interface{}, v interface{}) bool {
-		parts := strings.Split(k.(string), "&")
-		if 3 != len(parts) {
+		symbol, positionSide, uid, _, parseErr := parseOrderMapKey(k.(string))
+		if nil != parseErr {
+			log.Println("查看用户仓位，解析id错误:", k, parseErr)
 			return true
 		}
 
-		var (
-			uid uint64
-		)
-		uid, err = strconv.ParseUint(parts[2], 10, 64)
-		if nil != err {
-			log.Println("查看用户仓位，解析id错误:", k)
-		}
-
-		if uid != uint64(users[0].Id) {
+		if uid != users[0].Id {
 			return true
 		}
 
-		part1 := parts[1]
+		// 多个交易员的仓位合并
 		amount := v.(float64)
-
-		res[parts[0]+"&"+part1] = math.Abs(amount)
+		res[symbol+"&"+positionSide] += math.Abs(amount)
 		return true
 	})
 
@@ -2364,7 +3251,7 @@ func (s *sListenAndOrder) GetBinanceUserPositions(ctx context.Context, apiKey st
 		return res
 	}
 
-	positions = service.Binance().GetBinancePositionInfo(users[0].ApiKey, users[0].ApiSecret)
+	positions = userBinance(users[0]).GetBinancePositionInfo(users[0].ApiKey, users[0].ApiSecret)
 	for _, v := range positions {
 		// 新增
 		var (
@@ -2408,7 +3295,7 @@ func (s *sListenAndOrder) CloseBinanceUserPositions(ctx context.Context) uint64
 			positions []*entity.BinancePosition
 		)
 
-		positions = service.Binance().GetBinancePositionInfo(vUser.ApiKey, vUser.ApiSecret)
+		positions = userBinance(vUser).GetBinancePositionInfo(vUser.ApiKey, vUser.ApiSecret)
 		for _, v := range positions {
 			// 新增
 			var (
@@ -2429,7 +3316,6 @@ func (s *sListenAndOrder) CloseBinanceUserPositions(ctx context.Context) uint64
 				symbolRel     = v.Symbol
 				symbolRelKey  = vUser.Plat + v.Symbol
 				tmpQty        float64
-				quantity      string
 				quantityFloat float64
 				orderType     = "MARKET"
 				side          string
@@ -2449,50 +3335,39 @@ func (s *sListenAndOrder) CloseBinanceUserPositions(ctx context.Context) uint64
 				continue
 			}
 
-			// 精度调整
-			if 0 >= s.SymbolsMap.Get(symbolRelKey).(*entity.LhCoinSymbol).QuantityPrecision {
-				quantity = fmt.Sprintf("%d", int64(tmpQty))
-			} else {
-				quantity = strconv.FormatFloat(tmpQty, 'f', s.SymbolsMap.Get(symbolRelKey).(*entity.LhCoinSymbol).QuantityPrecision, 64)
-			}
-
-			quantityFloat, err = strconv.ParseFloat(quantity, 64)
-			if nil != err {
-				log.Println("close positions，数量解析", v, vUser, err)
+			// 按交易规则调整数量，平仓不受最小名义价值限制
+			quantities, quantityFloat, reason := s.normalizeQuantity(symbolRelKey, symbolRel, tmpQty, true)
+			if 0 >= len(quantities) {
+				log.Println("close positions，数量不满足交易规则，跳过", v, vUser, reason)
 				continue
 			}
 
-			if lessThanOrEqualZero(quantityFloat, 1e-7) {
-				continue
+			if 0 < len(reason) {
+				log.Println("close positions，数量调整", v, vUser, reason)
 			}
 
 			var (
 				binanceOrderRes *entity.BinanceOrder
 				orderInfoRes    *entity.BinanceOrderInfo
+				fill            *entity.OrderFill
 			)
 
-			// 请求下单
-			binanceOrderRes, orderInfoRes, err = service.Binance().RequestBinanceOrder(symbolRel, side, orderType, v.PositionSide, quantity, vUser.ApiKey, vUser.ApiSecret, false)
-			if nil != err {
-				log.Println("close positions，执行下单错误，手动：", err, symbolRel, side, orderType, v.PositionSide, quantity, vUser.ApiKey, vUser.ApiSecret)
-			}
-
-			// 下单异常
-			if 0 >= binanceOrderRes.OrderId {
-				log.Println("自定义下单，binance下单错误：", orderInfoRes)
+			// 请求下单，以实际成交为准
+			fill, binanceOrderRes, orderInfoRes, err = s.placeBinanceOrders(vUser, symbolRel, side, orderType, v.PositionSide, quantities, false, uniqueClientId(clientIdClose, int(vUser.Id)), false)
+			quantityFloat = fill.ExecutedQty
+			if lessThanOrEqualZero(quantityFloat, 1e-7) {
+				log.Println("close positions，执行下单错误，手动：", err, orderInfoRes, symbolRel, side, orderType, v.PositionSide, quantities, vUser.ApiKey, vUser.ApiSecret)
 				continue
 			}
-			log.Println("close, 执行成功：", vUser, v, binanceOrderRes)
+			log.Println("close, 执行成功：", vUser, v, binanceOrderRes, quantityFloat)
 		}
-
-		time.Sleep(500 * time.Millisecond)
 	}
 
 	return 1
 }
 
 // SetSystemUserPosition set user positions
-func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint64, allCloseGate uint64, apiKey string, symbol string, side string, positionSide string, num float64) uint64 {
+func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint64, allCloseGate uint64, apiKey string, symbol string, side string, positionSide string, num float64, traderId uint64) uint64 {
 	var (
 		err   error
 		users []*entity.User
@@ -2510,14 +3385,27 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 	}
 
 	vTmpUserMap := users[0]
-	strUserId := strconv.FormatUint(uint64(vTmpUserMap.Id), 10)
 	symbolMapKey := vTmpUserMap.Plat + symbol + "USDT"
 
+	// 未指定交易员时，只跟单一个交易员的用户默认该交易员
+	if 0 == traderId {
+		tmpUserTraders := s.UsersTraders.Get(int(vTmpUserMap.Id))
+		if nil != tmpUserTraders && 1 == len(tmpUserTraders.(map[uint]float64)) {
+			for tmpTraderId := range tmpUserTraders.(map[uint]float64) {
+				traderId = uint64(tmpTraderId)
+			}
+		}
+	}
+
+	if 1 == system && lessThanOrEqualZero(s.getUserTraderNum(int(vTmpUserMap.Id), uint(traderId)), 1e-7) {
+		log.Println("修改仓位，用户未跟单交易员：", apiKey, traderId)
+		return 0
+	}
+
 	if "binance" == vTmpUserMap.Plat {
 		var (
 			symbolRel     = symbol + "USDT"
 			tmpQty        float64
-			quantity      string
 			quantityFloat float64
 			orderType     = "MARKET"
 		)
@@ -2574,52 +3462,30 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 			return 0
 		}
 
-		// 精度调整
-		if 0 >= s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantityPrecision {
-			quantity = fmt.Sprintf("%d", int64(tmpQty))
-		} else {
-			quantity = strconv.FormatFloat(tmpQty, 'f', s.SymbolsMap.Get(symbolMapKey).(*entity.LhCoinSymbol).QuantityPrecision, 64)
-		}
-
-		quantityFloat, err = strconv.ParseFloat(quantity, 64)
-		if nil != err {
-			log.Println(err)
+		// 按交易规则调整数量，减仓不受最小名义价值限制
+		reducing := ("LONG" == positionSide && "SELL" == side) || ("SHORT" == positionSide && "BUY" == side)
+		quantities, quantityFloat, reason := s.normalizeQuantity(symbolMapKey, symbolRel, tmpQty, reducing)
+		if 0 >= len(quantities) {
+			log.Println("自定义下单，数量不满足交易规则，跳过：", apiKey, symbolRel, side, positionSide, num, reason)
 			return 0
 		}
 
-		if lessThanOrEqualZero(quantityFloat, 1e-7) {
-			return 0
+		if 0 < len(reason) {
+			log.Println("自定义下单，数量调整：", apiKey, symbolRel, side, positionSide, num, reason)
 		}
 
 		// 下单，不用计算数量，新仓位
 		var (
 			binanceOrderRes *entity.BinanceOrder
 			orderInfoRes    *entity.BinanceOrderInfo
+			fill            *entity.OrderFill
 		)
 
-		// 请求下单
-		binanceOrderRes, orderInfoRes, err = service.Binance().RequestBinanceOrder(symbolRel, side, orderType, positionSide, quantity, vTmpUserMap.ApiKey, vTmpUserMap.ApiSecret, false)
-		if nil != err {
-			log.Println(err)
-		}
-
-		//binanceOrderRes = &binanceOrder{
-		//	OrderId:       1,
-		//	ExecutedQty:   quantity,
-		//	ClientOrderId: "",
-		//	Symbol:        "",
-		//	AvgPrice:      "",
-		//	CumQuote:      "",
-		//	Side:          side,
-		//	PositionSide:  positionSide,
-		//	ClosePosition: false,
-		//	Type:          "",
-		//	Status:        "",
-		//}
-
-		// 下单异常
-		if 0 >= binanceOrderRes.OrderId {
-			log.Println("自定义下单，binance下单错误：", orderInfoRes)
+		// 请求下单，以实际成交为准
+		fill, binanceOrderRes, orderInfoRes, err = s.placeBinanceOrders(vTmpUserMap, symbolRel, side, orderType, positionSide, quantities, false, uniqueClientId(clientIdManual, int(vTmpUserMap.Id)), false)
+		quantityFloat = fill.ExecutedQty
+		if lessThanOrEqualZero(quantityFloat, 1e-7) {
+			log.Println("自定义下单，binance下单错误：", orderInfoRes, err)
 			return 0
 		}
 
@@ -2631,13 +3497,13 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 
 		if 1 == system {
 			// 不存在新增，这里只能是开仓
-			if !s.OrderMap.Contains(symbolRel + "&" + positionSide + "&" + strUserId) {
-				s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+			if !s.OrderMap.Contains(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))) {
+				s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 			} else {
 				// 追加仓位，开仓
 				if "LONG" == positionSide {
 					if "BUY" == side {
-						d1 := decimal.NewFromFloat(s.OrderMap.Get(symbolRel + "&" + positionSide + "&" + strUserId).(float64))
+						d1 := decimal.NewFromFloat(s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64))
 						d2 := decimal.NewFromFloat(tmpExecutedQty)
 						result := d1.Add(d2)
 
@@ -2647,9 +3513,9 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 							fmt.Println("转换过程中可能发生了精度损失", tmpExecutedQty)
 						}
 
-						s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+						s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 					} else if "SELL" == side {
-						d1 := decimal.NewFromFloat(s.OrderMap.Get(symbolRel + "&" + positionSide + "&" + strUserId).(float64))
+						d1 := decimal.NewFromFloat(s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64))
 						d2 := decimal.NewFromFloat(tmpExecutedQty)
 						result := d1.Sub(d2)
 
@@ -2662,14 +3528,14 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 						if lessThanOrEqualZero(tmpExecutedQty, 1e-7) {
 							tmpExecutedQty = 0
 						}
-						s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+						s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 					} else {
 						log.Println("手动，binance下单，数据存储:", system, allCloseGate, apiKey, symbol, side, positionSide, num, binanceOrderRes, orderInfoRes, tmpExecutedQty)
 					}
 
 				} else if "SHORT" == positionSide {
 					if "SELL" == side {
-						d1 := decimal.NewFromFloat(s.OrderMap.Get(symbolRel + "&" + positionSide + "&" + strUserId).(float64))
+						d1 := decimal.NewFromFloat(s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64))
 						d2 := decimal.NewFromFloat(tmpExecutedQty)
 						result := d1.Add(d2)
 
@@ -2679,9 +3545,9 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 							fmt.Println("转换过程中可能发生了精度损失", tmpExecutedQty)
 						}
 
-						s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+						s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 					} else if "BUY" == side {
-						d1 := decimal.NewFromFloat(s.OrderMap.Get(symbolRel + "&" + positionSide + "&" + strUserId).(float64))
+						d1 := decimal.NewFromFloat(s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64))
 						d2 := decimal.NewFromFloat(tmpExecutedQty)
 						result := d1.Sub(d2)
 
@@ -2694,13 +3560,13 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 						if lessThanOrEqualZero(tmpExecutedQty, 1e-7) {
 							tmpExecutedQty = 0
 						}
-						s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+						s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 					} else {
 						log.Println("手动，binance下单，数据存储:", system, allCloseGate, apiKey, symbol, side, positionSide, num, binanceOrderRes, orderInfoRes, tmpExecutedQty)
 					}
 
 				} else if "BOTH" == positionSide {
-					d1 := decimal.NewFromFloat(s.OrderMap.Get(symbolRel + "&" + positionSide + "&" + strUserId).(float64))
+					d1 := decimal.NewFromFloat(s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64))
 					d2 := decimal.NewFromFloat(tmpExecutedQty)
 					result := d1.Add(d2)
 
@@ -2713,7 +3579,7 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 					if floatEqual(tmpExecutedQty, 0, 1e-7) {
 						tmpExecutedQty = 0
 					}
-					s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+					s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 				} else {
 					log.Println("手动，binance下单，数据存储:", system, allCloseGate, apiKey, symbol, side, positionSide, num, binanceOrderRes, orderInfoRes, tmpExecutedQty)
 				}
@@ -2768,8 +3634,13 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 					quantityFloat = 0
 					closePosition = "close_long"
 
-					// 剩余仓位
-					tmpExecutedQty = s.OrderMap.Get(symbol + "USDT" + "&" + positionSide + "&" + strUserId).(float64)
+					// 剩余仓位，系统无仓位不下单
+					var ok bool
+					tmpExecutedQty, ok = s.OrderMap.Get(orderMapKey(symbol+"USDT", positionSide, vTmpUserMap.Id, uint(traderId))).(float64)
+					if !ok {
+						log.Println("自定义下单，系统无仓位，信息", apiKey, symbol, side, positionSide, num)
+						return 0
+					}
 
 				} else {
 					tmpQty = num
@@ -2806,8 +3677,13 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 					quantityFloat = 0
 					closePosition = "close_short"
 
-					// 剩余仓位
-					tmpExecutedQty = s.OrderMap.Get(symbol + "USDT" + "&" + positionSide + "&" + strUserId).(float64)
+					// 剩余仓位，系统无仓位不下单
+					var ok bool
+					tmpExecutedQty, ok = s.OrderMap.Get(orderMapKey(symbol+"USDT", positionSide, vTmpUserMap.Id, uint(traderId))).(float64)
+					if !ok {
+						log.Println("自定义下单，系统无仓位，信息", apiKey, symbol, side, positionSide, num)
+						return 0
+					}
 
 				} else {
 					tmpQty = num
@@ -2863,8 +3739,14 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 					quantityFloat = 0
 					closeStatus = true
 
-					// 剩余仓位
-					tmpExecutedQty = math.Abs(s.OrderMap.Get(symbol + "USDT" + "&" + positionSide + "&" + strUserId).(float64))
+					// 剩余仓位，系统无仓位不下单
+					var ok bool
+					tmpExecutedQty, ok = s.OrderMap.Get(orderMapKey(symbol+"USDT", positionSide, vTmpUserMap.Id, uint(traderId))).(float64)
+					if !ok {
+						log.Println("自定义下单，系统无仓位，信息", apiKey, symbol, side, positionSide, num)
+						return 0
+					}
+					tmpExecutedQty = math.Abs(tmpExecutedQty)
 
 				} else {
 					tmpQty = num
@@ -2892,8 +3774,14 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 					quantityFloat = 0
 					closeStatus = true
 
-					// 剩余仓位
-					tmpExecutedQty = math.Abs(s.OrderMap.Get(symbol + "USDT" + "&" + positionSide + "&" + strUserId).(float64))
+					// 剩余仓位，系统无仓位不下单
+					var ok bool
+					tmpExecutedQty, ok = s.OrderMap.Get(orderMapKey(symbol+"USDT", positionSide, vTmpUserMap.Id, uint(traderId))).(float64)
+					if !ok {
+						log.Println("自定义下单，系统无仓位，信息", apiKey, symbol, side, positionSide, num)
+						return 0
+					}
+					tmpExecutedQty = math.Abs(tmpExecutedQty)
 
 				} else {
 					tmpQty = num
@@ -2924,14 +3812,19 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 			return 0
 		}
 
+		text := gateText(uniqueClientId(clientIdManual, int(vTmpUserMap.Id)))
 		if "BOTH" == positionSide {
-			gateRes, err = service.Gate().PlaceBothOrderGate(vTmpUserMap.ApiKey, vTmpUserMap.ApiSecret, symbolRel, quantityInt64, reduceOnly, closeStatus)
+			gateRes, err = s.placeGateOrder(vTmpUserMap, symbolRel, text, false, func() (gateapi.FuturesOrder, error) {
+				return userGate(vTmpUserMap).PlaceBothOrderGate(vTmpUserMap.ApiKey, vTmpUserMap.ApiSecret, symbolRel, quantityInt64, reduceOnly, closeStatus, text)
+			})
 			if nil != err || 0 >= gateRes.Id {
 				log.Println("自定义下单，gate，Gate下单:", err, symbol, side, positionSide, quantityInt64, quantity, gateRes)
 				return 0
 			}
 		} else {
-			gateRes, err = service.Gate().PlaceOrderGate(vTmpUserMap.ApiKey, vTmpUserMap.ApiSecret, symbolRel, quantityInt64, reduceOnly, closePosition)
+			gateRes, err = s.placeGateOrder(vTmpUserMap, symbolRel, text, false, func() (gateapi.FuturesOrder, error) {
+				return userGate(vTmpUserMap).PlaceOrderGate(vTmpUserMap.ApiKey, vTmpUserMap.ApiSecret, symbolRel, quantityInt64, reduceOnly, closePosition, text)
+			})
 			if nil != err || 0 >= gateRes.Id {
 				log.Println("自定义下单，gate，Gate下单:", err, symbol, side, positionSide, quantityInt64, quantity, gateRes)
 				return 0
@@ -2943,50 +3836,65 @@ func (s *sListenAndOrder) SetSystemUserPosition(ctx context.Context, system uint
 			return 0
 		}
 
+		// 以实际成交张数为准，全平按仓位数量记录
+		fill, fillErr := userGate(vTmpUserMap).GateOrderFill(vTmpUserMap.ApiKey, vTmpUserMap.ApiSecret, gateRes)
+		if nil != fillErr {
+			log.Println("自定义下单，gate，确认成交:", symbol, side, positionSide, quantityInt64, gateRes, fill, fillErr)
+		}
+
+		if 0 != quantityInt64 && nil != fill {
+			if lessThanOrEqualZero(fill.ExecutedQty, 1e-7) {
+				log.Println("自定义下单，gate，未成交:", symbol, side, positionSide, quantityInt64, gateRes, fill)
+				return 0
+			}
+
+			tmpExecutedQty = fill.ExecutedQty
+		}
+
 		if 1 == system {
 			// 不存在新增，这里只能是开仓
 			if "BOTH" == positionSide && "SELL" == side {
 				tmpExecutedQty = -tmpExecutedQty
 			}
 
-			if !s.OrderMap.Contains(symbolRel + "&" + positionSide + "&" + strUserId) {
-				s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+			if !s.OrderMap.Contains(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))) {
+				s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 			} else {
 				// 追加仓位，开仓
 				if "LONG" == positionSide {
 					if "BUY" == side {
-						tmpExecutedQty += s.OrderMap.Get(symbolRel + "&" + positionSide + "&" + strUserId).(float64)
-						s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+						tmpExecutedQty += s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64)
+						s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 					} else if "SELL" == side {
-						tmpExecutedQty = s.OrderMap.Get(symbolRel+"&"+positionSide+"&"+strUserId).(float64) - tmpExecutedQty
+						tmpExecutedQty = s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64) - tmpExecutedQty
 						if lessThanOrEqualZero(tmpExecutedQty, 1e-7) {
 							tmpExecutedQty = 0
 						}
-						s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+						s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 					} else {
 						log.Println("手动，gate下单，数据存储:", system, allCloseGate, apiKey, symbol, side, positionSide, num, gateRes, tmpExecutedQty)
 					}
 
 				} else if "SHORT" == positionSide {
 					if "SELL" == side {
-						tmpExecutedQty += s.OrderMap.Get(symbolRel + "&" + positionSide + "&" + strUserId).(float64)
-						s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+						tmpExecutedQty += s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64)
+						s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 					} else if "BUY" == side {
-						tmpExecutedQty = s.OrderMap.Get(symbolRel+"&"+positionSide+"&"+strUserId).(float64) - tmpExecutedQty
+						tmpExecutedQty = s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64) - tmpExecutedQty
 						if lessThanOrEqualZero(tmpExecutedQty, 1e-7) {
 							tmpExecutedQty = 0
 						}
-						s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+						s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 					} else {
 						log.Println("手动，gate下单，数据存储:", system, allCloseGate, apiKey, symbol, side, positionSide, num, gateRes, tmpExecutedQty)
 					}
 
 				} else if "BOTH" == positionSide {
-					tmpExecutedQty = s.OrderMap.Get(symbolRel+"&"+positionSide+"&"+strUserId).(float64) + tmpExecutedQty
+					tmpExecutedQty = s.OrderMap.Get(orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId))).(float64) + tmpExecutedQty
 					if floatEqual(tmpExecutedQty, 0, 1e-7) {
 						tmpExecutedQty = 0
 					}
-					s.OrderMap.Set(symbolRel+"&"+positionSide+"&"+strUserId, tmpExecutedQty)
+					s.setOrderMapFill(ctx, orderMapKey(symbolRel, positionSide, vTmpUserMap.Id, uint(traderId)), tmpExecutedQty, fill)
 				} else {
 					log.Println("手动，gate下单，数据存储:", system, allCloseGate, apiKey, symbol, side, positionSide, num, gateRes, tmpExecutedQty)
 				}
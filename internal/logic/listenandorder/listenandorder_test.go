@@ -0,0 +1,168 @@
+package listenandorder
+
+import (
+	"context"
+	_ "plat_order/internal/logic/simexchange"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"testing"
+)
+
+func TestHedgeOrderInfo(t *testing.T) {
+	tests := []struct {
+		name         string
+		positionSide string
+		status       string
+		lastAmount   float64
+		newAmount    float64
+		oQ           float64
+		wantNil      bool
+		wantSide     string
+		wantStatus   string
+		wantAmount   float64
+		wantOq       float64
+	}{
+		{name: "多仓开仓", positionSide: "LONG", status: "OPEN", lastAmount: 0, newAmount: 1.5, oQ: 1.5, wantSide: "BUY", wantStatus: "OPEN", wantAmount: 1.5, wantOq: 1.5},
+		{name: "多仓加仓", positionSide: "LONG", status: "OPEN", lastAmount: 1, newAmount: 3, oQ: 2, wantSide: "BUY", wantStatus: "OPEN", wantAmount: 3, wantOq: 2},
+		{name: "多仓部分平仓", positionSide: "LONG", status: "OPEN", lastAmount: 3, newAmount: 1, oQ: -2, wantSide: "SELL", wantStatus: "OPEN", wantAmount: 1, wantOq: 2},
+		{name: "多仓全平", positionSide: "LONG", status: "CLOSE", lastAmount: 2, newAmount: 0, oQ: 2, wantSide: "SELL", wantStatus: "CLOSE", wantAmount: 0, wantOq: 2},
+		{name: "空仓开仓", positionSide: "SHORT", status: "OPEN", lastAmount: 0, newAmount: 2, oQ: 2, wantSide: "SELL", wantStatus: "OPEN", wantAmount: 2, wantOq: 2},
+		{name: "空仓部分平仓", positionSide: "SHORT", status: "OPEN", lastAmount: 2, newAmount: 0.5, oQ: 1.5, wantSide: "BUY", wantStatus: "OPEN", wantAmount: 0.5, wantOq: 1.5},
+		{name: "空仓全平", positionSide: "SHORT", status: "CLOSE", lastAmount: 2, newAmount: 0, oQ: 2, wantSide: "BUY", wantStatus: "CLOSE", wantAmount: 0, wantOq: 2},
+		{name: "上次无仓位全平", positionSide: "LONG", status: "CLOSE", lastAmount: 0, newAmount: 0, oQ: 0, wantNil: true},
+		{name: "新仓位为0", positionSide: "LONG", status: "OPEN", lastAmount: 1, newAmount: 0, oQ: 1, wantNil: true},
+		{name: "单向持仓", positionSide: "BOTH", status: "OPEN", lastAmount: 0, newAmount: 1, oQ: 1, wantNil: true},
+	}
+
+	for _, tt := range tests {
+		res := hedgeOrderInfo("BTCUSDT", tt.positionSide, tt.status, tt.lastAmount, tt.newAmount, tt.oQ)
+		if tt.wantNil {
+			if nil != res {
+				t.Errorf("%s: 期望nil，得到%+v", tt.name, res)
+			}
+			continue
+		}
+
+		if nil == res {
+			t.Errorf("%s: 得到nil", tt.name)
+			continue
+		}
+
+		if "BTCUSDT" != res.Symbol || tt.positionSide != res.PositionSide || tt.wantSide != res.Side || tt.wantStatus != res.Status {
+			t.Errorf("%s: 下单信息错误%+v", tt.name, res)
+		}
+
+		if !floatEqual(tt.wantAmount, res.Amount, 1e-9) || !floatEqual(tt.lastAmount, res.LastAmount, 1e-9) || !floatEqual(tt.wantOq, res.Oq, 1e-9) {
+			t.Errorf("%s: 数量错误%+v", tt.name, res)
+		}
+	}
+}
+
+func TestCorrectOrderInfos(t *testing.T) {
+	type want struct {
+		positionSide string
+		status       string
+		side         string
+		amount       float64
+		oq           float64
+	}
+
+	tests := []struct {
+		name         string
+		positionSide string
+		lastAmount   float64
+		newAmount    float64
+		want         []want
+	}{
+		{name: "仓位一致", positionSide: "LONG", lastAmount: 1, newAmount: 1, want: []want{}},
+		{name: "双向多仓增加", positionSide: "LONG", lastAmount: 1, newAmount: 2, want: []want{{"LONG", "OPEN", "BUY", 2, 1}}},
+		{name: "双向空仓全平", positionSide: "SHORT", lastAmount: 2, newAmount: 0, want: []want{{"SHORT", "CLOSE", "BUY", 0, 2}}},
+		{name: "单向多仓减少", positionSide: "BOTH", lastAmount: 2, newAmount: 0.5, want: []want{{"LONG", "OPEN", "SELL", 0.5, 1.5}}},
+		{name: "单向空仓开仓", positionSide: "BOTH", lastAmount: 0, newAmount: -1, want: []want{{"SHORT", "OPEN", "SELL", 1, 1}}},
+		{name: "单向多转空先平后开", positionSide: "BOTH", lastAmount: 1, newAmount: -2, want: []want{{"LONG", "CLOSE", "SELL", 0, 1}, {"SHORT", "OPEN", "SELL", 2, 2}}},
+		{name: "单向空转多先平后开", positionSide: "BOTH", lastAmount: -1, newAmount: 3, want: []want{{"SHORT", "CLOSE", "BUY", 0, 1}, {"LONG", "OPEN", "BUY", 3, 3}}},
+	}
+
+	for _, tt := range tests {
+		res := correctOrderInfos("BTCUSDT", tt.positionSide, tt.lastAmount, tt.newAmount)
+		if len(tt.want) != len(res) {
+			t.Errorf("%s: 期望%d条，得到%d条", tt.name, len(tt.want), len(res))
+			continue
+		}
+
+		for i, w := range tt.want {
+			if w.positionSide != res[i].PositionSide || w.status != res[i].Status || w.side != res[i].Side {
+				t.Errorf("%s: 第%d条下单信息错误%+v", tt.name, i, res[i])
+			}
+
+			if !floatEqual(w.amount, res[i].Amount, 1e-9) || !floatEqual(w.oq, res[i].Oq, 1e-9) {
+				t.Errorf("%s: 第%d条数量错误%+v", tt.name, i, res[i])
+			}
+		}
+	}
+}
+
+// TestGateCloseSharedPosition 两个交易员跟单同一交易对同一方向，一个交易员平仓只平掉自己的部分，最后一个平仓时全平
+func TestGateCloseSharedPosition(t *testing.T) {
+	for _, positionSide := range []string{"LONG", "BOTH"} {
+		var (
+			ctx      = context.Background()
+			s        = newOffline()
+			user     = s.offlineUser(1, "gate", 1000)
+			simKey   = "BTCUSDT&" + positionSide
+			key7     = orderMapKey("BTCUSDT", positionSide, user.Id, 7)
+			key8     = orderMapKey("BTCUSDT", positionSide, user.Id, 8)
+			userSide = "ALL"
+		)
+		if "BOTH" == positionSide {
+			userSide = "BOTH"
+		}
+
+		s.UsersPositionSide.Set(1, userSide)
+		s.UsersTraders.Set(1, map[uint]float64{7: 1, 8: 1})
+		s.offlineTrader(7, 1000)
+		s.offlineTrader(8, 1000)
+		s.offlineSymbol("BTCUSDT", 3, 0.0001)
+		service.SimExchange().SetPrice("BTCUSDT", 50000)
+
+		signal := func(traderId uint, orderId int64, side, status string, oq float64) {
+			s.OrderAtPlat(ctx, &entity.DoValue{UserId: 1, Value: &entity.OrderInfo{
+				Symbol:       "BTCUSDT",
+				TraderId:     traderId,
+				OrderId:      orderId,
+				TradeId:      orderId,
+				PositionSide: positionSide,
+				Side:         side,
+				Status:       status,
+				Amount:       oq,
+				Oq:           oq,
+			}})
+		}
+
+		signal(7, 1, "BUY", "OPEN", 0.002)
+		signal(8, 2, "BUY", "OPEN", 0.001)
+		if !floatEqual(0.003, service.SimExchange().GetPositions(user.ApiKey)[simKey], 1e-9) {
+			t.Fatalf("%s: 开仓后仓位%v", positionSide, service.SimExchange().GetPositions(user.ApiKey))
+		}
+
+		// 交易员7平仓，只减掉自己跟单的20张
+		signal(7, 3, "SELL", "CLOSE", 0)
+		if !floatEqual(0.001, service.SimExchange().GetPositions(user.ApiKey)[simKey], 1e-9) {
+			t.Errorf("%s: 交易员7平仓后仓位%v，期望0.001", positionSide, service.SimExchange().GetPositions(user.ApiKey))
+		}
+
+		if !floatEqual(0, s.OrderMap.GetVar(key7).Float64(), 1e-9) || !floatEqual(10, s.OrderMap.GetVar(key8).Float64(), 1e-9) {
+			t.Errorf("%s: 交易员7平仓后记录%v", positionSide, s.OrderMap.Map())
+		}
+
+		// 交易员8平仓，已无其他交易员仓位，全平
+		signal(8, 4, "SELL", "CLOSE", 0)
+		if 0 != len(service.SimExchange().GetPositions(user.ApiKey)) {
+			t.Errorf("%s: 全部平仓后仓位%v", positionSide, service.SimExchange().GetPositions(user.ApiKey))
+		}
+
+		if !floatEqual(0, s.OrderMap.GetVar(key8).Float64(), 1e-9) {
+			t.Errorf("%s: 交易员8平仓后记录%v", positionSide, s.OrderMap.Map())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package listenandorder
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtimer"
+	"log"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"time"
+)
+
+const (
+	paperBalance = 10000            // 模拟盘默认入金，单位USDT
+	paperFlush   = 10 * time.Second // 模拟账户持久化间隔
+)
+
+// userBinance 用户使用的币安接口，模拟盘用户走模拟交易所
+func userBinance(user *entity.User) service.IBinance {
+	if 1 == user.Paper {
+		return service.SimExchange()
+	}
+
+	return service.Binance()
+}
+
+// userGate 用户使用的gate接口，模拟盘用户走模拟交易所
+func userGate(user *entity.User) service.IGate {
+	if 1 == user.Paper {
+		return service.SimExchange()
+	}
+
+	return service.Gate()
+}
+
+// InitPaper 模拟盘以币安合约标记价格成交，与跟单数量计算一致，配置paper.file时恢复并定时保存模拟账户
+func (s *sListenAndOrder) InitPaper(ctx context.Context) {
+	s.paperBalance = g.Cfg().MustGet(ctx, "paper.balance", paperBalance).Float64()
+
+	sim := service.SimExchange()
+	sim.SetPriceFeed(service.Binance().GetMarkPrice)
+
+	file := g.Cfg().MustGet(ctx, "paper.file").String()
+	if 0 >= len(file) {
+		return
+	}
+
+	err := sim.Load(file)
+	if nil != err {
+		log.Println("模拟账户恢复错误：", file, err)
+	}
+
+	gtimer.AddSingleton(ctx, paperFlush, func(ctx context.Context) {
+		err := sim.Flush(file)
+		if nil != err {
+			log.Println("模拟账户写入文件错误：", file, err)
+		}
+	})
+}
+
+// openPaperAccount 模拟盘用户首次加载时开户
+func (s *sListenAndOrder) openPaperAccount(user *entity.User) {
+	if 1 != user.Paper {
+		return
+	}
+
+	if service.SimExchange().OpenAccount(user.ApiKey, s.paperBalance) {
+		log.Println("模拟账户开户：", user.Id, s.paperBalance)
+	}
+}
+
+// GetPaperAccount 模拟盘用户的资金和盈亏
+func (s *sListenAndOrder) GetPaperAccount(ctx context.Context, apiKey string) (*entity.SimAccount, error) {
+	var (
+		users []*entity.User
+		err   error
+	)
+	err = g.Model("user").Where("api_key=?", apiKey).Ctx(ctx).Scan(&users)
+	if nil != err {
+		log.Println("查看模拟账户，数据库查询错误：", err)
+		return nil, err
+	}
+
+	if 0 >= len(users) || 1 != users[0].Paper {
+		return nil, nil
+	}
+
+	return service.SimExchange().GetAccount(users[0].ApiKey), nil
+}
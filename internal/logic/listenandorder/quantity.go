@@ -0,0 +1,243 @@
+package listenandorder
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"log"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"strconv"
+	"time"
+)
+
+const markPriceTTL = 3 * time.Second // 标记价格缓存时间，同一信号多个用户下单共用
+
+// symbolRule 币安交易对的数量规则，市价单使用MARKET_LOT_SIZE，未返回时使用LOT_SIZE
+type symbolRule struct {
+	StepSize    decimal.Decimal
+	MinQty      decimal.Decimal
+	MaxQty      decimal.Decimal
+	MinNotional decimal.Decimal
+}
+
+// markPrice 缓存的标记价格
+type markPrice struct {
+	price float64
+	at    time.Time
+}
+
+// newSymbolRule 解析filters，没有数量规则返回nil
+func newSymbolRule(filters []*entity.BinanceSymbolFilter) *symbolRule {
+	var (
+		lot, market *entity.BinanceSymbolFilter
+		res         = &symbolRule{}
+	)
+	for _, filter := range filters {
+		switch filter.FilterType {
+		case "LOT_SIZE":
+			lot = filter
+		case "MARKET_LOT_SIZE":
+			market = filter
+		case "MIN_NOTIONAL":
+			res.MinNotional, _ = decimal.NewFromString(filter.Notional)
+		}
+	}
+
+	if nil == lot && nil == market {
+		return nil
+	}
+
+	for _, filter := range []*entity.BinanceSymbolFilter{lot, market} {
+		if nil == filter {
+			continue
+		}
+
+		if v, err := decimal.NewFromString(filter.StepSize); nil == err && v.IsPositive() {
+			res.StepSize = v
+		}
+		if v, err := decimal.NewFromString(filter.MinQty); nil == err && v.IsPositive() {
+			res.MinQty = v
+		}
+		if v, err := decimal.NewFromString(filter.MaxQty); nil == err && v.IsPositive() {
+			res.MaxQty = v
+		}
+	}
+
+	return res
+}
+
+// SetSymbolFilters 拉取币安交易规则
+func (s *sListenAndOrder) SetSymbolFilters(ctx context.Context) (err error) {
+	symbols, err := service.Binance().GetBinanceFuturesPairs()
+	if nil != err {
+		log.Println("SetSymbolFilters，交易规则拉取错误：", err)
+		return err
+	}
+
+	s.setSymbolRules(symbols)
+	return nil
+}
+
+// setSymbolRules 更新交易规则
+func (s *sListenAndOrder) setSymbolRules(symbols []*entity.BinanceSymbolInfo) {
+	for _, v := range symbols {
+		rule := newSymbolRule(v.Filters)
+		if nil == rule {
+			continue
+		}
+
+		s.SymbolRules.Set(v.Symbol, rule)
+	}
+}
+
+// getMarkPrice 标记价格，短时间缓存，获取失败返回0
+func (s *sListenAndOrder) getMarkPrice(symbol string) float64 {
+	if tmp := s.MarkPrices.Get(symbol); nil != tmp && time.Since(tmp.(*markPrice).at) < markPriceTTL {
+		return tmp.(*markPrice).price
+	}
+
+	price, err := strconv.ParseFloat(service.Binance().GetMarkPrice(symbol), 64)
+	if nil != err || lessThanOrEqualZero(price, 1e-12) {
+		log.Println("获取标记价格失败：", symbol, err)
+		return 0
+	}
+
+	s.MarkPrices.Set(symbol, &markPrice{price: price, at: time.Now()})
+	return price
+}
+
+// normalizeQuantity 币安下单数量规范：按步长取整，校验最小数量和最小名义价值，超过单笔上限拆分。
+// 返回每笔数量、合计和原因，数量为空表示跳过，原因非空时为跳过或拆分的说明
+func (s *sListenAndOrder) normalizeQuantity(symbolMapKey string, symbol string, qty float64, reduceOnly bool) ([]string, float64, string) {
+	tmpSymbol := s.SymbolsMap.Get(symbolMapKey)
+	if nil == tmpSymbol {
+		return nil, 0, "交易对信息不存在"
+	}
+
+	// 无交易规则时按精度处理
+	tmpRule := s.SymbolRules.Get(symbol)
+	if nil == tmpRule {
+		var quantity string
+		if 0 >= tmpSymbol.(*entity.LhCoinSymbol).QuantityPrecision {
+			quantity = fmt.Sprintf("%d", int64(qty))
+		} else {
+			quantity = strconv.FormatFloat(qty, 'f', tmpSymbol.(*entity.LhCoinSymbol).QuantityPrecision, 64)
+		}
+
+		quantityFloat, err := strconv.ParseFloat(quantity, 64)
+		if nil != err || lessThanOrEqualZero(quantityFloat, 1e-7) {
+			return nil, 0, fmt.Sprintf("数量%v按精度%d取整后为0", qty, tmpSymbol.(*entity.LhCoinSymbol).QuantityPrecision)
+		}
+
+		return []string{quantity}, quantityFloat, ""
+	}
+
+	var (
+		rule  = tmpRule.(*symbolRule)
+		total = decimal.NewFromFloat(qty)
+	)
+	if rule.StepSize.IsPositive() {
+		total = total.Div(rule.StepSize).Round(0).Mul(rule.StepSize)
+	}
+
+	if !total.IsPositive() {
+		return nil, 0, fmt.Sprintf("数量%v按步长%s取整后为0", qty, rule.StepSize)
+	}
+
+	if total.LessThan(rule.MinQty) {
+		return nil, 0, fmt.Sprintf("数量%s小于最小数量%s", total, rule.MinQty)
+	}
+
+	// 只减仓单不受最小名义价值限制
+	if !reduceOnly && rule.MinNotional.IsPositive() {
+		price := s.getMarkPrice(symbol)
+		if 0 < price {
+			notional := total.Mul(decimal.NewFromFloat(price))
+			if notional.LessThan(rule.MinNotional) {
+				return nil, 0, fmt.Sprintf("名义价值%s小于最小名义价值%s，数量%s，标记价格%v", notional.StringFixed(4), rule.MinNotional, total, price)
+			}
+		}
+	}
+
+	totalFloat, _ := total.Float64()
+	if !rule.MaxQty.IsPositive() || total.LessThanOrEqual(rule.MaxQty) {
+		return []string{total.String()}, totalFloat, ""
+	}
+
+	// 超过单笔上限拆分，不足最小数量的余数舍去
+	var (
+		res    = make([]string, 0)
+		remain = total
+		reason string
+	)
+	for remain.GreaterThan(rule.MaxQty) {
+		res = append(res, rule.MaxQty.String())
+		remain = remain.Sub(rule.MaxQty)
+	}
+
+	if remain.GreaterThanOrEqual(rule.MinQty) && remain.IsPositive() {
+		res = append(res, remain.String())
+		reason = fmt.Sprintf("数量%s超过单笔上限%s，拆分为%d笔", total, rule.MaxQty, len(res))
+	} else if remain.IsZero() {
+		reason = fmt.Sprintf("数量%s超过单笔上限%s，拆分为%d笔", total, rule.MaxQty, len(res))
+	} else {
+		total = total.Sub(remain)
+		totalFloat, _ = total.Float64()
+		reason = fmt.Sprintf("数量%s超过单笔上限%s，拆分为%d笔，余数%s小于最小数量舍去", total.Add(remain), rule.MaxQty, len(res), remain)
+	}
+
+	return res, totalFloat, reason
+}
+
+// placeBinanceOrders 按拆分后的数量依次下单并确认成交，每笔clientOrderId为clientId加序号，某笔失败后停止，返回合计的实际成交数量、成交均价和最后一笔结果。
+// queryFirst为true时每笔下单前先按clientOrderId查询，已下单的不再下单
+func (s *sListenAndOrder) placeBinanceOrders(user *entity.User, symbol, side, orderType, positionSide string, quantities []string, reduceOnly bool, clientId string, queryFirst bool) (*entity.OrderFill, *entity.BinanceOrder, *entity.BinanceOrderInfo, error) {
+	var (
+		res             = &entity.OrderFill{Plat: "binance", Symbol: symbol, Final: true}
+		executed        = decimal.Zero
+		cost            = decimal.Zero
+		binanceOrderRes *entity.BinanceOrder
+		orderInfoRes    *entity.BinanceOrderInfo
+		err             error
+	)
+	for i, quantity := range quantities {
+		var placed string
+		binanceOrderRes, orderInfoRes, placed, err = s.placeBinanceOrder(user, symbol, side, orderType, positionSide, quantity, reduceOnly, legClientId(clientId, i), queryFirst)
+		if nil != err || nil == binanceOrderRes || 0 >= binanceOrderRes.OrderId {
+			if 0 < i {
+				log.Println("拆分下单中断，已完成：", user.Id, symbol, side, positionSide, i, len(quantities), executed, orderInfoRes, err)
+			}
+			break
+		}
+
+		// 以实际成交为准，未结束的订单记录已成交部分
+		fill, fillErr := userBinance(user).BinanceOrderFill(binanceOrderRes, user.ApiKey, user.ApiSecret)
+		if nil == fill {
+			log.Println("确认成交错误：", user.Id, symbol, side, positionSide, quantity, binanceOrderRes, fillErr)
+			break
+		}
+
+		if nil != fillErr || !floatEqual(fill.ExecutedQty, decimal.RequireFromString(placed).InexactFloat64(), 1e-12) {
+			log.Println("成交数量与下单数量不一致：", user.Id, symbol, side, positionSide, placed, fill, fillErr)
+		}
+
+		res.OrderId = fill.OrderId
+		res.Status = fill.Status
+		res.Final = res.Final && fill.Final
+		executed = executed.Add(decimal.NewFromFloat(fill.ExecutedQty))
+		cost = cost.Add(decimal.NewFromFloat(fill.ExecutedQty).Mul(decimal.NewFromFloat(fill.AvgPrice)))
+
+		// 本笔未全部成交或重试时缩减了数量，余下的不再下单
+		if !fill.Final || "FILLED" != fill.Status || placed != quantity {
+			break
+		}
+	}
+
+	res.ExecutedQty, _ = executed.Float64()
+	if executed.IsPositive() {
+		res.AvgPrice, _ = cost.Div(executed).Float64()
+	}
+
+	return res, binanceOrderRes, orderInfoRes, err
+}
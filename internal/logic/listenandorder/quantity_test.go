@@ -0,0 +1,66 @@
+package listenandorder
+
+import (
+	"plat_order/internal/model/entity"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeQuantity(t *testing.T) {
+	s := New()
+	s.SymbolsMap.Set("binanceBTCUSDT", &entity.LhCoinSymbol{Symbol: "BTC", QuantityPrecision: 3})
+	s.SymbolsMap.Set("binanceETHUSDT", &entity.LhCoinSymbol{Symbol: "ETH", QuantityPrecision: 2})
+	s.SymbolsMap.Set("binanceDOGEUSDT", &entity.LhCoinSymbol{Symbol: "DOGE", QuantityPrecision: 0})
+	s.setSymbolRules([]*entity.BinanceSymbolInfo{
+		{
+			Symbol: "BTCUSDT",
+			Filters: []*entity.BinanceSymbolFilter{
+				{FilterType: "LOT_SIZE", StepSize: "0.001", MinQty: "0.001", MaxQty: "1000"},
+				{FilterType: "MARKET_LOT_SIZE", StepSize: "0.001", MinQty: "0.002", MaxQty: "1"},
+				{FilterType: "MIN_NOTIONAL", Notional: "200"},
+			},
+		},
+	})
+
+	// 标记价格使用缓存，不请求接口
+	s.MarkPrices.Set("BTCUSDT", &markPrice{price: 50000, at: time.Now().Add(time.Hour)})
+
+	tests := []struct {
+		name       string
+		mapKey     string
+		symbol     string
+		qty        float64
+		reduceOnly bool
+		want       []string
+		wantTotal  float64
+		wantReason bool
+	}{
+		{name: "交易对不存在", mapKey: "binanceXRPUSDT", symbol: "XRPUSDT", qty: 1, want: nil, wantTotal: 0, wantReason: true},
+		{name: "无规则按精度", mapKey: "binanceETHUSDT", symbol: "ETHUSDT", qty: 1.23456, want: []string{"1.23"}, wantTotal: 1.23},
+		{name: "无规则精度为0", mapKey: "binanceDOGEUSDT", symbol: "DOGEUSDT", qty: 12.9, want: []string{"12"}, wantTotal: 12},
+		{name: "无规则取整后为0", mapKey: "binanceETHUSDT", symbol: "ETHUSDT", qty: 0.004, want: nil, wantTotal: 0, wantReason: true},
+		{name: "按步长取整", mapKey: "binanceBTCUSDT", symbol: "BTCUSDT", qty: 0.01234, want: []string{"0.012"}, wantTotal: 0.012},
+		{name: "小于最小数量", mapKey: "binanceBTCUSDT", symbol: "BTCUSDT", qty: 0.0012, want: nil, wantTotal: 0, wantReason: true},
+		{name: "小于最小名义价值", mapKey: "binanceBTCUSDT", symbol: "BTCUSDT", qty: 0.0015, want: nil, wantTotal: 0, wantReason: true},
+		{name: "只减仓不校验名义价值", mapKey: "binanceBTCUSDT", symbol: "BTCUSDT", qty: 0.0015, reduceOnly: true, want: []string{"0.002"}, wantTotal: 0.002},
+		{name: "超过上限拆分", mapKey: "binanceBTCUSDT", symbol: "BTCUSDT", qty: 2.5, want: []string{"1", "1", "0.5"}, wantTotal: 2.5, wantReason: true},
+		{name: "上限整数倍", mapKey: "binanceBTCUSDT", symbol: "BTCUSDT", qty: 2, want: []string{"1", "1"}, wantTotal: 2, wantReason: true},
+		{name: "余数小于最小数量舍去", mapKey: "binanceBTCUSDT", symbol: "BTCUSDT", qty: 2.001, want: []string{"1", "1"}, wantTotal: 2, wantReason: true},
+	}
+
+	for _, tt := range tests {
+		res, total, reason := s.normalizeQuantity(tt.mapKey, tt.symbol, tt.qty, tt.reduceOnly)
+		if !reflect.DeepEqual(tt.want, res) {
+			t.Errorf("%s: 数量%v，期望%v", tt.name, res, tt.want)
+		}
+
+		if !floatEqual(tt.wantTotal, total, 1e-12) {
+			t.Errorf("%s: 合计%v，期望%v", tt.name, total, tt.wantTotal)
+		}
+
+		if tt.wantReason != (0 < len(reason)) {
+			t.Errorf("%s: 原因%q", tt.name, reason)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package listenandorder
+
+import (
+	"context"
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"log"
+	"math"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reconcileTrader 以接口仓位对比交易员系统仓位，push为true时推送校正信息，仍有未完成订单的仓位等订单结束后再校正
+func (s *sListenAndOrder) reconcileTrader(trader *Trader, push bool) ([]*entity.PositionMismatch, bool) {
+	res := make([]*entity.PositionMismatch, 0)
+
+	// 接口仓位可能早于推送，以仓位更新时间为快照时间，未返回更新时间的以请求时间为准
+	requestAt := time.Now().UnixMilli()
+	binancePosition := service.Binance().GetBinancePositionInfo(trader.credentials())
+	if nil == binancePosition {
+		log.Println("校正仓位，查询仓位错误:", trader.Id)
+		return res, false
+	}
+
+	accountKeys := make(map[string]bool, len(binancePosition))
+	accountTimes := make(map[string]int64, len(binancePosition))
+	accountPositions := make([]*TraderPosition, 0, len(binancePosition))
+	for _, position := range binancePosition {
+		currentAmount, err := strconv.ParseFloat(position.PositionAmt, 64)
+		if nil != err {
+			log.Println("校正仓位，解析仓位出错，信息", trader.Id, position)
+			continue
+		}
+
+		// 双向持仓仓位为正数，单向持仓正负数保持
+		if "BOTH" != position.PositionSide {
+			currentAmount = math.Abs(currentAmount)
+		}
+
+		accountKeys[position.Symbol+position.PositionSide] = true
+		accountTimes[position.Symbol+position.PositionSide] = requestAt
+		if 0 < position.UpdateTime {
+			accountTimes[position.Symbol+position.PositionSide] = position.UpdateTime
+		}
+		accountPositions = append(accountPositions, &TraderPosition{
+			Symbol:         position.Symbol,
+			PositionSide:   position.PositionSide,
+			PositionAmount: currentAmount,
+		})
+	}
+
+	// 系统有仓位，接口没有，按0仓位处理
+	trader.Position.Iterator(func(k string, v interface{}) bool {
+		if !accountKeys[k] {
+			tmpPosition := v.(*TraderPosition)
+			accountTimes[k] = requestAt
+			accountPositions = append(accountPositions, &TraderPosition{
+				Symbol:         tmpPosition.Symbol,
+				PositionSide:   tmpPosition.PositionSide,
+				PositionAmount: 0,
+			})
+		}
+
+		return true
+	})
+
+	for _, accountPosition := range accountPositions {
+		key := accountPosition.Symbol + accountPosition.PositionSide
+
+		// 推送已更新到快照之后的仓位以推送为准，接口不能回退
+		if !trader.advancePositionTime(key, accountTimes[key]) {
+			log.Println("校正仓位，推送晚于接口快照，跳过：", trader.Id, key, accountTimes[key])
+			continue
+		}
+
+		trader.AccountPosition.Set(key, accountPosition)
+
+		var systemAmount float64
+		tmpPosition := trader.Position.Get(key)
+		if nil != tmpPosition {
+			systemAmount = tmpPosition.(*TraderPosition).PositionAmount
+		}
+
+		if floatEqual(systemAmount, accountPosition.PositionAmount, 1e-7) {
+			continue
+		}
+
+		// 仍有订单未完成，等订单结束后再校正
+		if s.hasPendingOrder(trader, key) {
+			continue
+		}
+
+		mismatch := &entity.PositionMismatch{
+			TraderId:      trader.Id,
+			Symbol:        accountPosition.Symbol,
+			PositionSide:  accountPosition.PositionSide,
+			SystemAmount:  systemAmount,
+			AccountAmount: accountPosition.PositionAmount,
+			CheckedAt:     gtime.Now(),
+		}
+
+		if push {
+			s.checkAccountPosition(trader, key)
+			mismatch.Corrected = true
+		}
+
+		log.Println("校正仓位，交易员仓位不一致：", trader.Id, key, systemAmount, accountPosition.PositionAmount, push)
+		res = append(res, mismatch)
+	}
+
+	return res, true
+}
+
+// ReconcileTraders 定时对比交易员接口仓位和系统仓位，配置reconcile.push开启时推送校正信息
+func (s *sListenAndOrder) ReconcileTraders(ctx context.Context) {
+	push := g.Cfg().MustGet(ctx, "reconcile.push").Bool()
+
+	s.Traders.Iterator(func(k int, v interface{}) bool {
+		// 只有币安来源有真实账户
+		if _, ok := v.(*Trader).Source.(*binanceSource); !ok {
+			return true
+		}
+
+		mismatches, ok := s.reconcileTrader(v.(*Trader), push)
+		if ok {
+			s.TraderMismatches.Set(k, mismatches)
+		}
+
+		return true
+	})
+}
+
+// GetTraderMismatches 最近一次对比的交易员仓位差异
+func (s *sListenAndOrder) GetTraderMismatches(ctx context.Context) []*entity.PositionMismatch {
+	res := make([]*entity.PositionMismatch, 0)
+	s.TraderMismatches.Iterator(func(k int, v interface{}) bool {
+		res = append(res, v.([]*entity.PositionMismatch)...)
+		return true
+	})
+
+	return res
+}
+
+const (
+	userReconcileReport = "report" // 只报告差异
+	userReconcileMap    = "map"    // 以交易所仓位校正系统仓位
+	userReconcileOrder  = "order"  // 下单使交易所仓位与系统仓位一致
+)
+
+// getUserExchangePositions 用户交易所仓位，key为symbol&positionSide，binance为币的数量，gate为张数，双向持仓为正数
+func (s *sListenAndOrder) getUserExchangePositions(user *entity.User) (map[string]float64, error) {
+	res := make(map[string]float64, 0)
+
+	if "binance" == user.Plat {
+		binancePosition := userBinance(user).GetBinancePositionInfo(user.ApiKey, user.ApiSecret)
+		if nil == binancePosition {
+			return res, gerror.Newf("查询仓位错误，binance：%d", user.Id)
+		}
+
+		for _, position := range binancePosition {
+			currentAmount, err := strconv.ParseFloat(position.PositionAmt, 64)
+			if nil != err {
+				log.Println("用户仓位，解析仓位出错，信息", user.Id, position)
+				continue
+			}
+
+			if floatEqual(currentAmount, 0, 1e-7) {
+				continue
+			}
+
+			if "BOTH" != position.PositionSide {
+				currentAmount = math.Abs(currentAmount)
+			}
+
+			res[position.Symbol+"&"+position.PositionSide] = currentAmount
+		}
+	} else if "gate" == user.Plat {
+		gatePositions, err := userGate(user).GetListPositions(user.ApiKey, user.ApiSecret)
+		if nil != err {
+			return res, gerror.Newf("查询仓位错误，gate：%d，%v", user.Id, err)
+		}
+
+		for _, position := range gatePositions {
+			if 0 == position.Size {
+				continue
+			}
+
+			var (
+				symbol       = strings.Replace(position.Contract, "_", "", -1)
+				positionSide string
+				currentQty   = float64(position.Size)
+			)
+			if "single" == position.Mode {
+				positionSide = "BOTH"
+			} else if "dual_long" == position.Mode {
+				positionSide = "LONG"
+				currentQty = math.Abs(currentQty)
+			} else if "dual_short" == position.Mode {
+				positionSide = "SHORT"
+				currentQty = math.Abs(currentQty)
+			} else {
+				log.Println("用户仓位，gate，未识别", user.Id, position.Mode)
+				continue
+			}
+
+			res[symbol+"&"+positionSide] = currentQty
+		}
+	} else {
+		return res, gerror.Newf("不支持的平台：%d，%s", user.Id, user.Plat)
+	}
+
+	return res, nil
+}
+
+// roundUserQty 按平台精度取整，binance按数量精度，gate按张
+func (s *sListenAndOrder) roundUserQty(user *entity.User, symbol string, qty float64) float64 {
+	if "gate" == user.Plat {
+		return math.Round(qty)
+	}
+
+	tmpSymbol := s.SymbolsMap.Get(user.Plat + symbol)
+	if nil == tmpSymbol {
+		return qty
+	}
+
+	precision := math.Pow10(tmpSymbol.(*entity.LhCoinSymbol).QuantityPrecision)
+	return math.Round(qty*precision) / precision
+}
+
+// ReconcileUser 对比用户交易所仓位和系统仓位，report只报告，map校正系统仓位，order下单补齐交易所仓位
+func (s *sListenAndOrder) ReconcileUser(ctx context.Context, apiKey string, mode string) ([]*entity.PositionMismatch, error) {
+	res := make([]*entity.PositionMismatch, 0)
+
+	var user *entity.User
+	s.Users.Iterator(func(k int, v interface{}) bool {
+		if apiKey == v.(*entity.User).ApiKey {
+			user = v.(*entity.User)
+			return false
+		}
+
+		return true
+	})
+
+	if nil == user {
+		return res, gerror.Newf("用户不存在或未启用：%s", apiKey)
+	}
+
+	if userReconcileReport != mode && userReconcileMap != mode && userReconcileOrder != mode {
+		return res, gerror.Newf("不支持的模式：%s", mode)
+	}
+
+	exchangePositions, err := s.getUserExchangePositions(user)
+	if nil != err {
+		return res, err
+	}
+
+	// 系统仓位按symbol&positionSide汇总，记录各交易员的仓位
+	systemPositions := make(map[string]float64, 0)
+	systemKeys := make(map[string][]string, 0)
+	s.OrderMap.Iterator(func(k interface{}, v interface{}) bool {
+		symbol, positionSide, uid, _, parseErr := parseOrderMapKey(k.(string))
+		if nil != parseErr || uid != user.Id {
+			return true
+		}
+
+		key := symbol + "&" + positionSide
+		systemPositions[key] += v.(float64)
+		systemKeys[key] = append(systemKeys[key], k.(string))
+		return true
+	})
+
+	allKeys := make(map[string]bool, 0)
+	for k := range exchangePositions {
+		allKeys[k] = true
+	}
+	for k := range systemPositions {
+		allKeys[k] = true
+	}
+
+	for key := range allKeys {
+		tmp := strings.Split(key, "&")
+		var (
+			symbol         = tmp[0]
+			positionSide   = tmp[1]
+			systemAmount   = systemPositions[key]
+			exchangeAmount = exchangePositions[key]
+		)
+
+		if floatEqual(s.roundUserQty(user, symbol, exchangeAmount-systemAmount), 0, 1e-7) {
+			continue
+		}
+
+		mismatch := &entity.PositionMismatch{
+			UserId:        user.Id,
+			Symbol:        symbol,
+			PositionSide:  positionSide,
+			SystemAmount:  systemAmount,
+			AccountAmount: exchangeAmount,
+			CheckedAt:     gtime.Now(),
+		}
+
+		if userReconcileMap == mode {
+			mismatch.Corrected = s.correctUserOrderMap(ctx, user, symbol, positionSide, systemKeys[key], systemAmount, exchangeAmount)
+		} else if userReconcileOrder == mode {
+			mismatch.Corrected = s.placeUserCatchUpOrder(user, symbol, positionSide, systemAmount, exchangeAmount)
+		}
+
+		log.Println("校正仓位，用户仓位不一致：", user.Id, key, systemAmount, exchangeAmount, mode, mismatch.Corrected)
+		res = append(res, mismatch)
+	}
+
+	s.UserMismatches.Set(int(user.Id), res)
+	return res, nil
+}
+
+// correctUserOrderMap 以交易所仓位校正系统仓位，多个交易员按原仓位比例分配，无系统仓位时只有一个跟单交易员才能确定归属
+func (s *sListenAndOrder) correctUserOrderMap(ctx context.Context, user *entity.User, symbol, positionSide string, keys []string, systemAmount, exchangeAmount float64) bool {
+	if !floatEqual(systemAmount, 0, 1e-7) {
+		ratio := exchangeAmount / systemAmount
+		for _, k := range keys {
+			s.setOrderMap(ctx, k, s.OrderMap.Get(k).(float64)*ratio)
+		}
+
+		return true
+	}
+
+	tmpUserTraders := s.UsersTraders.Get(int(user.Id))
+	if nil == tmpUserTraders || 1 != len(tmpUserTraders.(map[uint]float64)) {
+		log.Println("校正仓位，无法确定交易员：", user.Id, symbol, positionSide, exchangeAmount)
+		return false
+	}
+
+	for traderId := range tmpUserTraders.(map[uint]float64) {
+		s.setOrderMap(ctx, orderMapKey(symbol, positionSide, user.Id, traderId), exchangeAmount)
+	}
+
+	return true
+}
+
+// placeUserCatchUpOrder 下单使交易所仓位与系统仓位一致，暂停开仓的用户不补开仓
+func (s *sListenAndOrder) placeUserCatchUpOrder(user *entity.User, symbol, positionSide string, systemAmount, exchangeAmount float64) bool {
+	var (
+		diff       = s.roundUserQty(user, symbol, systemAmount-exchangeAmount)
+		reduceOnly bool
+		side       string
+	)
+
+	// 双向持仓仓位减少为平仓
+	if "BOTH" != positionSide && math.Signbit(diff) {
+		reduceOnly = true
+	}
+
+	if !reduceOnly && 2 != user.OpenStatus {
+		log.Println("校正仓位，暂停用户不补开仓:", user.Id, symbol, positionSide, diff)
+		return false
+	}
+
+	if "LONG" == positionSide || "BOTH" == positionSide {
+		side = "BUY"
+		if math.Signbit(diff) {
+			side = "SELL"
+		}
+	} else if "SHORT" == positionSide {
+		side = "SELL"
+		if math.Signbit(diff) {
+			side = "BUY"
+		}
+	} else {
+		return false
+	}
+
+	if "binance" == user.Plat {
+		tmpSymbol := s.SymbolsMap.Get(user.Plat + symbol)
+		if nil == tmpSymbol {
+			log.Println("校正仓位，代币信息无效:", user.Id, symbol)
+			return false
+		}
+
+		quantities, _, reason := s.normalizeQuantity(user.Plat+symbol, symbol, math.Abs(diff), reduceOnly)
+		if 0 >= len(quantities) {
+			log.Println("校正仓位，数量不满足交易规则，跳过:", user.Id, symbol, side, positionSide, diff, reason)
+			return false
+		}
+
+		if 0 < len(reason) {
+			log.Println("校正仓位，数量调整:", user.Id, symbol, side, positionSide, diff, reason)
+		}
+
+		fill, binanceOrderRes, orderInfoRes, err := s.placeBinanceOrders(user, symbol, side, "MARKET", positionSide, quantities, reduceOnly, uniqueClientId(clientIdReconcile, int(user.Id)), false)
+		if lessThanOrEqualZero(fill.ExecutedQty, 1e-7) {
+			log.Println("校正仓位，binance下单错误:", user.Id, symbol, side, positionSide, quantities, orderInfoRes, err)
+			return false
+		}
+
+		log.Println("校正仓位，binance下单成功:", user.Id, symbol, side, positionSide, fill.ExecutedQty, fill.AvgPrice, binanceOrderRes)
+		return true
+	}
+
+	if "gate" == user.Plat {
+		tmpSymbol := s.SymbolsMap.Get(user.Plat + symbol)
+		if nil == tmpSymbol {
+			log.Println("校正仓位，代币信息无效:", user.Id, symbol)
+			return false
+		}
+
+		var (
+			contract = tmpSymbol.(*entity.LhCoinSymbol).Symbol + "_USDT"
+			size     = int64(math.Abs(diff))
+			gateRes  gateapi.FuturesOrder
+			err      error
+		)
+		if "SELL" == side {
+			size = -size
+		}
+
+		text := gateText(uniqueClientId(clientIdReconcile, int(user.Id)))
+		if "BOTH" == positionSide {
+			gateRes, err = s.placeGateOrder(user, contract, text, false, func() (gateapi.FuturesOrder, error) {
+				return userGate(user).PlaceBothOrderGate(user.ApiKey, user.ApiSecret, contract, size, false, false, text)
+			})
+		} else {
+			gateRes, err = s.placeGateOrder(user, contract, text, false, func() (gateapi.FuturesOrder, error) {
+				return userGate(user).PlaceOrderGate(user.ApiKey, user.ApiSecret, contract, size, reduceOnly, "", text)
+			})
+		}
+
+		if nil != err || 0 >= gateRes.Id {
+			log.Println("校正仓位，gate下单错误:", user.Id, contract, side, positionSide, size, gateRes, err)
+			return false
+		}
+
+		fill, err := userGate(user).GateOrderFill(user.ApiKey, user.ApiSecret, gateRes)
+		if nil != err {
+			log.Println("校正仓位，gate成交确认错误:", user.Id, contract, side, positionSide, size, gateRes, fill, err)
+		}
+
+		if nil != fill && lessThanOrEqualZero(fill.ExecutedQty, 1e-7) {
+			log.Println("校正仓位，gate未成交:", user.Id, contract, side, positionSide, size, gateRes, fill)
+			return false
+		}
+
+		log.Println("校正仓位，gate下单成功:", user.Id, contract, side, positionSide, size, gateRes, fill)
+		return true
+	}
+
+	return false
+}
+
+// ReconcileUsers 定时对比用户交易所仓位，模式为配置reconcile.userMode，默认只报告
+func (s *sListenAndOrder) ReconcileUsers(ctx context.Context) {
+	mode := g.Cfg().MustGet(ctx, "reconcile.userMode", userReconcileReport).String()
+
+	apiKeys := make([]string, 0)
+	s.Users.Iterator(func(k int, v interface{}) bool {
+		apiKeys = append(apiKeys, v.(*entity.User).ApiKey)
+		return true
+	})
+
+	for _, apiKey := range apiKeys {
+		_, err := s.ReconcileUser(ctx, apiKey, mode)
+		if nil != err {
+			log.Println("校正仓位，用户：", apiKey, err)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// GetUserMismatches 最近一次对比的用户仓位差异
+func (s *sListenAndOrder) GetUserMismatches(ctx context.Context) []*entity.PositionMismatch {
+	res := make([]*entity.PositionMismatch, 0)
+	s.UserMismatches.Iterator(func(k int, v interface{}) bool {
+		res = append(res, v.([]*entity.PositionMismatch)...)
+		return true
+	})
+
+	return res
+}
@@ -0,0 +1,364 @@
+package listenandorder
+
+import (
+	"context"
+	"errors"
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/gtime"
+	"log"
+	"math"
+	"plat_order/internal/consts"
+	"plat_order/internal/logic/binance"
+	"plat_order/internal/model/entity"
+	"strconv"
+	"time"
+)
+
+const (
+	orderRetryTimes   = 2                       // 同一笔订单按错误分类重试的最多次数
+	orderRetryBackoff = 500 * time.Millisecond  // 限频后重试前的等待
+	marginRetryRatio  = 0.95                    // 保证金不足时按可用保证金的比例缩减数量
+	orderQueryDelay   = 2500 * time.Millisecond // 下单超时后查询前的等待，超时3秒加等待超过recvWindow 5秒
+)
+
+// placeBinanceOrder 下单一笔，被拒时按错误分类处理后重试，返回最后一次下单的数量。
+// 超时等订单状态未知时按clientOrderId查询，确认未下单才用同一clientOrderId重试。
+// 币安只在订单未结束时拒绝重复的clientOrderId，重放或再次投递的信号queryFirst为true，下单前先查询
+func (s *sListenAndOrder) placeBinanceOrder(user *entity.User, symbol, side, orderType, positionSide, quantity string, reduceOnly bool, clientOrderId string, queryFirst bool) (*entity.BinanceOrder, *entity.BinanceOrderInfo, string, error) {
+	var (
+		binanceOrderRes *entity.BinanceOrder
+		orderInfoRes    *entity.BinanceOrderInfo
+		err             error
+	)
+	if queryFirst && 0 < len(clientOrderId) {
+		order, queryErr := userBinance(user).QueryBinanceOrderByClientId(symbol, clientOrderId, user.ApiKey, user.ApiSecret)
+		if nil == queryErr && nil != order && 0 < order.OrderId {
+			log.Println("重复信号，按clientOrderId查询到已下单，不再下单：", user.Id, symbol, clientOrderId, order.OrderId, order.Status)
+			return order, &entity.BinanceOrderInfo{}, quantity, nil
+		}
+
+		// 查询失败时无法确认是否已下单，不下单，等待仓位校正
+		if consts.BinanceErrNotFound != binance.ErrorClass(queryErr) {
+			log.Println("重复信号，按clientOrderId查询失败，不下单：", user.Id, symbol, clientOrderId, queryErr)
+			return nil, &entity.BinanceOrderInfo{}, quantity, queryErr
+		}
+	}
+
+	for i := 0; ; i++ {
+		binanceOrderRes, orderInfoRes, err = userBinance(user).RequestBinanceOrder(symbol, side, orderType, positionSide, quantity, user.ApiKey, user.ApiSecret, reduceOnly, clientOrderId)
+		if nil == err && nil != binanceOrderRes && 0 < binanceOrderRes.OrderId {
+			s.recordOrderSuccess(user)
+			return binanceOrderRes, orderInfoRes, quantity, nil
+		}
+
+		if nil == err {
+			err = gerror.Newf("invalid order response: %v", orderInfoRes)
+		}
+
+		class := binance.ErrorClass(err)
+		if orderStatusUnknown(class) && 0 < len(clientOrderId) {
+			order, placed := s.queryBinanceOrderPlaced(user, symbol, clientOrderId, class, err)
+			if nil != order {
+				s.recordOrderSuccess(user)
+				return order, &entity.BinanceOrderInfo{}, quantity, nil
+			}
+
+			s.recordOrderFailure(user, class, err)
+			if placed || orderRetryTimes <= i {
+				break
+			}
+
+			log.Println("下单状态未知，确认未下单后重试：", user.Id, symbol, side, positionSide, quantity, clientOrderId, err)
+			continue
+		}
+
+		s.recordOrderFailure(user, class, err)
+		if orderRetryTimes <= i {
+			break
+		}
+
+		next, ok := s.retryBinanceOrder(user, class, symbol, side, positionSide, quantity, reduceOnly)
+		if !ok {
+			break
+		}
+
+		log.Println("下单被拒，重试：", user.Id, symbol, side, positionSide, class, quantity, next, err)
+		quantity = next
+	}
+
+	return binanceOrderRes, orderInfoRes, quantity, err
+}
+
+// orderStatusUnknown 请求超时、服务端未知状态或clientOrderId重复时，订单可能已经下单
+func orderStatusUnknown(class string) bool {
+	return consts.BinanceErrNetwork == class || consts.BinanceErrServer == class || consts.BinanceErrDuplicate == class
+}
+
+// queryBinanceOrderPlaced 按clientOrderId查询订单，查到返回订单；
+// 确认不存在时placed为false，可以重试，查询失败时状态仍未知，placed为true不再重试
+func (s *sListenAndOrder) queryBinanceOrderPlaced(user *entity.User, symbol, clientOrderId, class string, err error) (*entity.BinanceOrder, bool) {
+	// 等待原请求超过接收窗口，之后不会再被受理
+	if consts.BinanceErrDuplicate != class {
+		time.Sleep(orderQueryDelay)
+	}
+
+	order, queryErr := userBinance(user).QueryBinanceOrderByClientId(symbol, clientOrderId, user.ApiKey, user.ApiSecret)
+	if nil == queryErr && nil != order && 0 < order.OrderId {
+		log.Println("下单状态未知，按clientOrderId查询到订单：", user.Id, symbol, clientOrderId, order.OrderId, order.Status, err)
+		return order, true
+	}
+
+	if consts.BinanceErrNotFound == binance.ErrorClass(queryErr) {
+		return nil, false
+	}
+
+	log.Println("下单状态未知，按clientOrderId查询失败，等待仓位校正：", user.Id, symbol, clientOrderId, err, queryErr)
+	return nil, true
+}
+
+// placeGateOrder gate下单，请求失败且不是接口错误时订单状态未知，按text查询，确认未下单后用同一text重试一次。
+// queryFirst为true时下单前先按text查询，已下单的不再下单
+func (s *sListenAndOrder) placeGateOrder(user *entity.User, contract, text string, queryFirst bool, place func() (gateapi.FuturesOrder, error)) (gateapi.FuturesOrder, error) {
+	if queryFirst && 0 < len(text) {
+		order, queryErr := userGate(user).GetOrderGateByText(user.ApiKey, user.ApiSecret, contract, text)
+		if nil != queryErr {
+			log.Println("重复信号，gate按text查询失败，不下单：", user.Id, contract, text, queryErr)
+			return order, queryErr
+		}
+
+		if 0 < order.Id {
+			log.Println("重复信号，gate按text查询到已下单，不再下单：", user.Id, contract, text, order.Id, order.Status)
+			return order, nil
+		}
+	}
+
+	res, err := place()
+	if nil == err && 0 < res.Id {
+		return res, nil
+	}
+
+	var apiErr gateapi.GateAPIError
+	if nil == err || errors.As(err, &apiErr) || 0 >= len(text) {
+		return res, err
+	}
+
+	time.Sleep(orderQueryDelay)
+	order, queryErr := userGate(user).GetOrderGateByText(user.ApiKey, user.ApiSecret, contract, text)
+	if nil != queryErr {
+		log.Println("gate下单状态未知，按text查询失败，等待仓位校正：", user.Id, contract, text, err, queryErr)
+		return res, err
+	}
+
+	if 0 < order.Id {
+		log.Println("gate下单状态未知，按text查询到订单：", user.Id, contract, text, order.Id, order.Status, err)
+		return order, nil
+	}
+
+	log.Println("gate下单状态未知，确认未下单后重试：", user.Id, contract, text, err)
+	return place()
+}
+
+// retryBinanceOrder 按错误分类处理，返回重试的数量，不可重试返回false
+func (s *sListenAndOrder) retryBinanceOrder(user *entity.User, class, symbol, side, positionSide, quantity string, reduceOnly bool) (string, bool) {
+	switch class {
+	case consts.BinanceErrTimestamp:
+		// 签名请求收到时间戳错误时已重新同步服务器时间
+		return quantity, true
+	case consts.BinanceErrRateLimit:
+		// 限频器已按响应头记录用量和封禁时间，重试前会排队等待
+		time.Sleep(orderRetryBackoff)
+		return quantity, true
+	case consts.BinanceErrPrecision, consts.BinanceErrQuantity:
+		return s.retryWithSymbolRule(symbol, quantity, reduceOnly)
+	case consts.BinanceErrMargin:
+		return s.retryWithMargin(user, symbol, quantity)
+	case consts.BinanceErrReduceOnly:
+		return s.retryWithPosition(user, symbol, side, positionSide, quantity)
+	}
+
+	return "", false
+}
+
+// retryWithSymbolRule 重新拉取交易规则后按新规则取整，数量不变时不重试
+func (s *sListenAndOrder) retryWithSymbolRule(symbol, quantity string, reduceOnly bool) (string, bool) {
+	err := s.SetSymbolFilters(gctx.New())
+	if nil != err {
+		return "", false
+	}
+
+	qty, _ := strconv.ParseFloat(quantity, 64)
+	quantities, _, reason := s.normalizeQuantity("binance"+symbol, symbol, qty, reduceOnly)
+	if 0 >= len(quantities) || quantity == quantities[0] {
+		log.Println("按交易规则调整数量失败：", symbol, quantity, quantities, reason)
+		return "", false
+	}
+
+	return quantities[0], true
+}
+
+// retryWithMargin 按可用保证金和杠杆缩减开仓数量
+func (s *sListenAndOrder) retryWithMargin(user *entity.User, symbol, quantity string) (string, bool) {
+	available, err := strconv.ParseFloat(userBinance(user).GetBinanceAvailableBalance(user.ApiKey, user.ApiSecret), 64)
+	if nil != err || lessThanOrEqualZero(available, 1e-7) {
+		log.Println("保证金不足，可用保证金为0：", user.Id, symbol, quantity, err)
+		return "", false
+	}
+
+	price := s.getMarkPrice(symbol)
+	if 0 >= price {
+		return "", false
+	}
+
+	leverage := 1.0
+	for _, v := range userBinance(user).GetBinancePositionInfo(user.ApiKey, user.ApiSecret) {
+		if symbol != v.Symbol {
+			continue
+		}
+
+		if tmp, parseErr := strconv.ParseFloat(v.Leverage, 64); nil == parseErr && 0 < tmp {
+			leverage = tmp
+		}
+		break
+	}
+
+	var (
+		qty, _  = strconv.ParseFloat(quantity, 64)
+		maxQty  = available * leverage * marginRetryRatio / price
+		reduced []string
+		reason  string
+	)
+	if maxQty >= qty {
+		log.Println("保证金不足，可用保证金足够下单数量，不重试：", user.Id, symbol, quantity, available, leverage, price)
+		return "", false
+	}
+
+	reduced, _, reason = s.normalizeQuantity("binance"+symbol, symbol, maxQty, false)
+	if 0 >= len(reduced) {
+		log.Println("保证金不足，缩减后的数量不满足交易规则：", user.Id, symbol, quantity, maxQty, reason)
+		return "", false
+	}
+
+	return reduced[0], true
+}
+
+// retryWithPosition 只减仓单被拒时按交易所可平数量重试
+func (s *sListenAndOrder) retryWithPosition(user *entity.User, symbol, side, positionSide, quantity string) (string, bool) {
+	closable := s.binanceClosable(user, symbol, side, positionSide)
+	if lessThanOrEqualZero(closable, 1e-12) {
+		log.Println("只减仓单被拒，交易所无可平仓位：", user.Id, symbol, side, positionSide, quantity)
+		return "", false
+	}
+
+	qty, _ := strconv.ParseFloat(quantity, 64)
+	if closable >= qty {
+		return "", false
+	}
+
+	reduced, _, reason := s.normalizeQuantity("binance"+symbol, symbol, closable, true)
+	if 0 >= len(reduced) {
+		log.Println("只减仓单被拒，可平数量不满足交易规则：", user.Id, symbol, quantity, closable, reason)
+		return "", false
+	}
+
+	return reduced[0], true
+}
+
+// binanceClosable 交易所当前可平数量，单向持仓按平仓方向判断
+func (s *sListenAndOrder) binanceClosable(user *entity.User, symbol, side, positionSide string) float64 {
+	for _, v := range userBinance(user).GetBinancePositionInfo(user.ApiKey, user.ApiSecret) {
+		if symbol != v.Symbol || positionSide != v.PositionSide {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(v.PositionAmt, 64)
+		if nil != err {
+			return 0
+		}
+
+		if "BOTH" == positionSide && ("SELL" == side) != (0 < amount) {
+			return 0
+		}
+
+		return math.Abs(amount)
+	}
+
+	return 0
+}
+
+// handleOrderError 下单最终失败，按错误分类处理系统仓位
+func (s *sListenAndOrder) handleOrderError(ctx context.Context, user *entity.User, userPositionKey, symbol, side, positionSide string, err error) {
+	class := binance.ErrorClass(err)
+	switch class {
+	case consts.BinanceErrReduceOnly:
+		// 交易所已无可平仓位，系统仓位已失效，清零避免之后的平仓信号继续被拒
+		if s.OrderMap.Contains(userPositionKey) && lessThanOrEqualZero(s.binanceClosable(user, symbol, side, positionSide), 1e-12) {
+			log.Println("下单失败，交易所无可平仓位，系统仓位清零：", user.Id, userPositionKey, s.OrderMap.Get(userPositionKey))
+			s.setOrderMapFill(ctx, userPositionKey, 0, nil)
+		}
+	case consts.BinanceErrNetwork, consts.BinanceErrServer:
+		log.Println("下单失败，订单状态未知，等待仓位校正：", user.Id, userPositionKey, err)
+	case consts.BinanceErrAuth:
+		log.Println("下单失败，api key无效或无权限：", user.Id, err)
+	case consts.BinanceErrMargin:
+		log.Println("下单失败，保证金不足：", user.Id, userPositionKey, err)
+	case consts.BinanceErrPositionSide:
+		// 持仓模式是用户整个账户的设置，不自动切换，由用户调整
+		log.Println("下单失败，账户持仓模式与订单不符，需用户调整持仓模式：", user.Id, userPositionKey, positionSide, err)
+	default:
+		log.Println("下单失败：", user.Id, userPositionKey, class, err)
+	}
+}
+
+// recordOrderSuccess 下单成功，连续失败次数清零
+func (s *sListenAndOrder) recordOrderSuccess(user *entity.User) {
+	s.OrderFailures.LockFunc(func(m map[int]interface{}) {
+		if tmp, ok := m[int(user.Id)]; ok {
+			tmp.(*entity.OrderFailure).Consecutive = 0
+		}
+	})
+}
+
+// recordOrderFailure 按错误分类记录用户下单失败
+func (s *sListenAndOrder) recordOrderFailure(user *entity.User, class string, err error) {
+	var consecutive int
+	s.OrderFailures.LockFunc(func(m map[int]interface{}) {
+		tmp, ok := m[int(user.Id)]
+		if !ok {
+			tmp = &entity.OrderFailure{UserId: user.Id, Classes: make(map[string]int, 0)}
+			m[int(user.Id)] = tmp
+		}
+
+		failure := tmp.(*entity.OrderFailure)
+		failure.Total++
+		failure.Consecutive++
+		failure.Classes[class]++
+		failure.LastClass = class
+		failure.LastError = err.Error()
+		failure.LastTime = gtime.Now()
+		consecutive = failure.Consecutive
+	})
+
+	if 0 == consecutive%10 {
+		log.Println("用户连续下单失败：", user.Id, consecutive, class, err)
+	}
+}
+
+// GetOrderFailures 用户下单失败统计
+func (s *sListenAndOrder) GetOrderFailures(ctx context.Context) []*entity.OrderFailure {
+	res := make([]*entity.OrderFailure, 0)
+	s.OrderFailures.RLockFunc(func(m map[int]interface{}) {
+		for _, v := range m {
+			failure := *v.(*entity.OrderFailure)
+			failure.Classes = make(map[string]int, len(v.(*entity.OrderFailure).Classes))
+			for class, count := range v.(*entity.OrderFailure).Classes {
+				failure.Classes[class] = count
+			}
+
+			res = append(res, &failure)
+		}
+	})
+
+	return res
+}
@@ -0,0 +1,104 @@
+package listenandorder
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"log"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+)
+
+const (
+	sourceBinance = "binance" // 交易员币安账户推送
+	sourceWebhook = "webhook" // http信号
+)
+
+// SignalSource 信号来源，产生的信号经pushTraderSignal推送给跟单该交易员的用户
+type SignalSource interface {
+	// Prepare 校验配置，初始化交易员仓位、保证金和持仓方向
+	Prepare(trader *Trader) error
+	// Start 开始产生信号，阻塞直到ctx结束
+	Start(ctx context.Context, trader *Trader)
+	// Rotate 更换api或token
+	Rotate(trader *Trader, apiKey, apiSecret string) error
+}
+
+// newSignalSource 按交易员配置的来源创建，默认币安
+func (s *sListenAndOrder) newSignalSource(v *entity.Trader) (SignalSource, error) {
+	switch v.Source {
+	case "", sourceBinance:
+		return &binanceSource{s: s}, nil
+	case sourceWebhook:
+		return &webhookSource{equity: v.Equity}, nil
+	}
+
+	return nil, gerror.Newf("不支持的信号来源：%d，%s", v.Id, v.Source)
+}
+
+// binanceSource 交易员币安账户推送
+type binanceSource struct {
+	s *sListenAndOrder
+}
+
+func (b *binanceSource) Prepare(trader *Trader) error {
+	apiKey, apiSecret := trader.credentials()
+	if 0 >= len(apiKey) || 0 >= len(apiSecret) {
+		return gerror.Newf("交易员api信息为空：%d", trader.Id)
+	}
+
+	// 校验api，同时初始化仓位
+	binancePosition := service.Binance().GetBinancePositionInfo(apiKey, apiSecret)
+	if nil == binancePosition {
+		return gerror.Newf("交易员api校验失败，查询仓位错误：%d", trader.Id)
+	}
+
+	initTraderPosition(trader, binancePosition)
+	b.s.pullTraderPositionSide(trader)
+
+	btcPriceF := getBtcPrice()
+	if !lessThanOrEqualZero(btcPriceF, 1e-7) {
+		b.s.pullTraderMoney(trader, btcPriceF)
+	}
+
+	return nil
+}
+
+func (b *binanceSource) Start(ctx context.Context, trader *Trader) {
+	b.s.Run(ctx, trader.Id)
+}
+
+func (b *binanceSource) Rotate(trader *Trader, apiKey, apiSecret string) error {
+	return b.s.rotateTrader(trader, apiKey, apiSecret)
+}
+
+// webhookSource http信号，交易员无真实账户，按虚拟保证金计算仓位
+type webhookSource struct {
+	equity float64
+}
+
+func (w *webhookSource) Prepare(trader *Trader) error {
+	_, token := trader.credentials()
+	if 0 >= len(token) {
+		return gerror.Newf("webhook交易员token为空：%d", trader.Id)
+	}
+
+	if lessThanOrEqualZero(w.equity, 1e-7) {
+		return gerror.Newf("webhook交易员虚拟保证金错误：%d", trader.Id)
+	}
+
+	// 统一按双向持仓
+	trader.Money.Set(w.equity)
+	trader.PositionSide.Set("ALL")
+	return nil
+}
+
+func (w *webhookSource) Start(ctx context.Context, trader *Trader) {
+	// 信号由HandleWebhook推送
+	<-ctx.Done()
+	log.Println("webhook交易员停止:", trader.Id)
+}
+
+func (w *webhookSource) Rotate(trader *Trader, apiKey, apiSecret string) error {
+	trader.setCredentials(apiKey, apiSecret)
+	return nil
+}
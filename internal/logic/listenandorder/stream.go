@@ -0,0 +1,120 @@
+package listenandorder
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gorilla/websocket"
+	"log"
+	"math"
+	"plat_order/internal/service"
+	"time"
+)
+
+const (
+	streamReadWait   = 5 * time.Minute  // 超过该时间未收到任何数据（含ping/pong）视为断线
+	streamWriteWait  = 10 * time.Second // 控制帧写超时
+	streamPingPeriod = time.Minute      // 主动ping间隔，需小于streamReadWait
+	streamBackoffMin = time.Second
+	streamBackoffMax = time.Minute
+)
+
+// getConn 当前连接
+func (t *Trader) getConn() *websocket.Conn {
+	t.connMu.Lock()
+	defer t.connMu.Unlock()
+	return t.conn
+}
+
+// swapConn 替换连接并关闭旧连接，旧连接上阻塞的读取会返回错误
+func (t *Trader) swapConn(conn *websocket.Conn) {
+	t.connMu.Lock()
+	old := t.conn
+	t.conn = conn
+	t.connMu.Unlock()
+
+	if nil != old {
+		err := old.Close()
+		if nil != err {
+			log.Println("关闭旧连接错误:", t.Id, err)
+		}
+	}
+}
+
+// prepareConn 设置读超时和ping/pong处理，收到任何心跳都会延长读超时
+func prepareConn(conn *websocket.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(streamReadWait))
+
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(streamReadWait))
+	})
+
+	// 币安每3分钟ping一次，10分钟未回pong会断开
+	conn.SetPingHandler(func(appData string) error {
+		_ = conn.SetReadDeadline(time.Now().Add(streamReadWait))
+
+		err := conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(streamWriteWait))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// pingTrader 主动ping，连接半开时由读超时发现
+func (s *sListenAndOrder) pingTrader(trader *Trader) {
+	conn := trader.getConn()
+	if nil == conn {
+		return
+	}
+
+	err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait))
+	if nil != err {
+		log.Println("ping错误:", trader.Id, err)
+	}
+}
+
+// connectTrader 创建listenKey并连接WebSocket，替换旧连接
+func (s *sListenAndOrder) connectTrader(trader *Trader) error {
+	apiKey, _ := trader.credentials()
+	listenKey, err := service.Binance().CreateListenKey(apiKey)
+	if err != nil {
+		return gerror.Newf("create listen key: %v", err)
+	}
+
+	conn, err := service.Binance().ConnectWebSocket(listenKey)
+	if err != nil {
+		return gerror.Newf("connect websocket: %v", err)
+	}
+
+	prepareConn(conn)
+	trader.swapConn(conn)
+
+	return nil
+}
+
+// reconnectTrader 指数退避重连，成功后用接口仓位补齐断线期间遗漏的成交
+func (s *sListenAndOrder) reconnectTrader(ctx context.Context, trader *Trader) bool {
+	backoff := streamBackoffMin
+	for {
+		if nil != ctx.Err() {
+			return false
+		}
+
+		err := s.connectTrader(trader)
+		if nil == err {
+			log.Println("交易员连接成功:", trader.Id)
+			s.reconcileTrader(trader, true)
+			return true
+		}
+
+		log.Println("交易员连接失败，等待重连:", trader.Id, backoff, err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		backoff = time.Duration(math.Min(float64(backoff*2), float64(streamBackoffMax)))
+	}
+}
@@ -0,0 +1,230 @@
+package listenandorder
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/shopspring/decimal"
+	"log"
+	"plat_order/internal/model/do"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"strconv"
+	"strings"
+)
+
+const (
+	symbolTrading   = "TRADING"   // 可交易，币安status，gate未下架
+	symbolDelisting = "DELISTING" // gate下架中
+	symbolDelisted  = "DELISTED"  // 交易所已不返回
+)
+
+// symbolTradable 手动维护（状态为空）或交易中的交易对可以下单，结算中、已下架的跳过
+func symbolTradable(symbol *entity.LhCoinSymbol) bool {
+	return 0 >= len(symbol.Status) || symbolTrading == symbol.Status
+}
+
+// precisionOf 步长的小数位数，0.01 => 2
+func precisionOf(step string) int {
+	d, err := decimal.NewFromString(step)
+	if nil != err || !d.IsPositive() {
+		return 0
+	}
+
+	if exp := d.Exponent(); 0 > exp {
+		return int(-exp)
+	}
+
+	return 0
+}
+
+// SyncSymbols 从币安exchangeInfo和gate合约列表同步lh_coin_symbol，配置symbolSync.enable关闭时只更新交易规则
+func (s *sListenAndOrder) SyncSymbols(ctx context.Context) (err error) {
+	if !g.Cfg().MustGet(ctx, "symbolSync.enable", true).Bool() {
+		return s.SetSymbolFilters(ctx)
+	}
+
+	pairs, err := service.Binance().GetBinanceFuturesPairs()
+	if nil != err {
+		log.Println("SyncSymbols，币安交易对拉取错误：", err)
+		return err
+	}
+
+	s.setSymbolRules(pairs)
+
+	binanceSymbols := make(map[string]*entity.LhCoinSymbol, 0)
+	for _, v := range pairs {
+		if "PERPETUAL" != v.ContractType || "USDT" != v.QuoteAsset {
+			continue
+		}
+
+		binanceSymbols[v.BaseAsset] = &entity.LhCoinSymbol{
+			Coin:              v.BaseAsset,
+			Symbol:            v.BaseAsset,
+			Plat:              "binance",
+			PricePrecision:    v.PricePrecision,
+			QuantityPrecision: v.QuantityPrecision,
+			Status:            v.Status,
+		}
+	}
+
+	err = s.upsertSymbols(ctx, "binance", binanceSymbols)
+	if nil != err {
+		return err
+	}
+
+	contracts, err := service.Gate().ListGateContracts()
+	if nil != err {
+		log.Println("SyncSymbols，gate合约拉取错误：", err)
+		return err
+	}
+
+	gateSymbols := make(map[string]*entity.LhCoinSymbol, 0)
+	for _, v := range contracts {
+		if !strings.HasSuffix(v.Name, "_USDT") {
+			continue
+		}
+
+		quanto, err := strconv.ParseFloat(v.QuantoMultiplier, 64)
+		if nil != err || lessThanOrEqualZero(quanto, 1e-12) {
+			log.Println("SyncSymbols，gate合约每张数量错误：", v.Name, v.QuantoMultiplier)
+			continue
+		}
+
+		status := symbolTrading
+		if v.InDelisting {
+			status = symbolDelisting
+		}
+
+		base := strings.TrimSuffix(v.Name, "_USDT")
+		gateSymbols[base] = &entity.LhCoinSymbol{
+			Coin:             base,
+			Symbol:           base,
+			Plat:             "gate",
+			PricePrecision:   precisionOf(v.OrderPriceRound),
+			QuantoMultiplier: quanto,
+			Status:           status,
+		}
+	}
+
+	return s.upsertSymbols(ctx, "gate", gateSymbols)
+}
+
+// symbolUpdate 已有交易对需要更新的字段
+type symbolUpdate struct {
+	Id     uint
+	Symbol string
+	Data   g.Map
+}
+
+// upsertSymbols 新上架的插入，精度、每张数量和状态有变化的更新，交易所不再返回的标记为下架。
+// 配置symbolSync.insert为false时不插入新交易对，只维护已有的
+func (s *sListenAndOrder) upsertSymbols(ctx context.Context, plat string, latest map[string]*entity.LhCoinSymbol) error {
+	// 接口异常返回空列表时不能把全部交易对标记为下架
+	if 0 >= len(latest) {
+		return gerror.Newf("%s symbol list is empty", plat)
+	}
+
+	var rows []*entity.LhCoinSymbol
+	err := g.Model("lh_coin_symbol").Ctx(ctx).Where("plat=?", plat).Scan(&rows)
+	if nil != err {
+		log.Println("SyncSymbols，数据库查询错误：", plat, err)
+		return err
+	}
+
+	existing := make(map[string]*entity.LhCoinSymbol, len(rows))
+	for _, v := range rows {
+		existing[v.Symbol] = v
+	}
+
+	var (
+		inserts, updates   = diffSymbols(plat, existing, latest, g.Cfg().MustGet(ctx, "symbolSync.insert", true).Bool())
+		inserted, modified int
+	)
+	for _, v := range inserts {
+		_, err = g.Model("lh_coin_symbol").Ctx(ctx).Insert(&do.LhCoinSymbol{
+			Coin:              v.Coin,
+			Symbol:            v.Symbol,
+			Plat:              plat,
+			PricePrecision:    v.PricePrecision,
+			QuantityPrecision: v.QuantityPrecision,
+			QuantoMultiplier:  v.QuantoMultiplier,
+			Status:            v.Status,
+		})
+		if nil != err {
+			log.Println("SyncSymbols，新增交易对错误：", plat, v.Symbol, err)
+			continue
+		}
+
+		log.Println("SyncSymbols，新上架交易对：", plat, v.Symbol)
+		inserted++
+	}
+
+	for _, v := range updates {
+		_, err = g.Model("lh_coin_symbol").Ctx(ctx).Data(v.Data).Where("id=?", v.Id).Update()
+		if nil != err {
+			log.Println("SyncSymbols，更新交易对错误：", plat, v.Symbol, err)
+			continue
+		}
+
+		modified++
+	}
+
+	if 0 < inserted || 0 < modified {
+		log.Println("SyncSymbols，同步完成：", plat, inserted, modified)
+	}
+
+	return nil
+}
+
+// diffSymbols 对比数据库和交易所的交易对，返回需要插入的新交易对和已有交易对需要更新的字段，
+// 只插入交易中的新交易对，交易所不再返回的标记为下架
+func diffSymbols(plat string, existing, latest map[string]*entity.LhCoinSymbol, insert bool) ([]*entity.LhCoinSymbol, []*symbolUpdate) {
+	var (
+		inserts = make([]*entity.LhCoinSymbol, 0)
+		updates = make([]*symbolUpdate, 0)
+	)
+	for symbol, v := range latest {
+		old, ok := existing[symbol]
+		if !ok {
+			if insert && symbolTrading == v.Status {
+				inserts = append(inserts, v)
+			}
+
+			continue
+		}
+
+		data := g.Map{}
+		if old.Status != v.Status {
+			data["status"] = v.Status
+			log.Println("SyncSymbols，交易对状态变更：", plat, symbol, old.Status, v.Status)
+		}
+
+		if "binance" == plat {
+			if old.PricePrecision != v.PricePrecision {
+				data["price_precision"] = v.PricePrecision
+			}
+			if old.QuantityPrecision != v.QuantityPrecision {
+				data["quantity_precision"] = v.QuantityPrecision
+			}
+		} else if !floatEqual(old.QuantoMultiplier, v.QuantoMultiplier, 1e-12) {
+			data["quanto_multiplier"] = v.QuantoMultiplier
+			log.Println("SyncSymbols，gate每张数量变更：", symbol, old.QuantoMultiplier, v.QuantoMultiplier)
+		}
+
+		if 0 < len(data) {
+			updates = append(updates, &symbolUpdate{Id: old.Id, Symbol: symbol, Data: data})
+		}
+	}
+
+	for symbol, old := range existing {
+		if _, ok := latest[symbol]; ok || symbolDelisted == old.Status {
+			continue
+		}
+
+		log.Println("SyncSymbols，交易对已下架：", plat, symbol)
+		updates = append(updates, &symbolUpdate{Id: old.Id, Symbol: symbol, Data: g.Map{"status": symbolDelisted}})
+	}
+
+	return inserts, updates
+}
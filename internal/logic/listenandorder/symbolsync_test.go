@@ -0,0 +1,66 @@
+package listenandorder
+
+import (
+	"context"
+	"plat_order/internal/model/entity"
+	"testing"
+)
+
+func TestUpsertSymbolsEmpty(t *testing.T) {
+	// 空列表直接返回错误，不查询数据库，不标记下架
+	if err := New().upsertSymbols(context.Background(), "binance", map[string]*entity.LhCoinSymbol{}); nil == err {
+		t.Errorf("空交易对列表未返回错误")
+	}
+}
+
+func TestDiffSymbols(t *testing.T) {
+	existing := map[string]*entity.LhCoinSymbol{
+		"BTC":  {Id: 1, Symbol: "BTC", PricePrecision: 2, QuantityPrecision: 3, Status: symbolTrading},
+		"ETH":  {Id: 2, Symbol: "ETH", PricePrecision: 2, QuantityPrecision: 3, Status: symbolTrading},
+		"LUNA": {Id: 3, Symbol: "LUNA", Status: symbolTrading},
+		"FTT":  {Id: 4, Symbol: "FTT", Status: symbolDelisted},
+		"DOGE": {Id: 5, Symbol: "DOGE", QuantityPrecision: 0},
+	}
+	latest := map[string]*entity.LhCoinSymbol{
+		"BTC":  {Symbol: "BTC", PricePrecision: 2, QuantityPrecision: 3, Status: symbolTrading},
+		"ETH":  {Symbol: "ETH", PricePrecision: 2, QuantityPrecision: 3, Status: "SETTLING"},
+		"DOGE": {Symbol: "DOGE", QuantityPrecision: 0, Status: symbolTrading},
+		"SOL":  {Symbol: "SOL", QuantityPrecision: 0, Status: symbolTrading},
+		"XYZ":  {Symbol: "XYZ", Status: "PENDING_TRADING"},
+	}
+
+	inserts, updates := diffSymbols("binance", existing, latest, true)
+	if 1 != len(inserts) || "SOL" != inserts[0].Symbol {
+		t.Errorf("新增交易对错误：%+v", inserts)
+	}
+
+	want := map[uint]string{
+		2: "SETTLING",     // 状态变更写入
+		3: symbolDelisted, // 交易所不再返回标记下架
+		5: symbolTrading,  // 手动维护的交易对写入交易所状态
+	}
+	if len(want) != len(updates) {
+		t.Fatalf("更新数量%d，期望%d", len(updates), len(want))
+	}
+
+	for _, v := range updates {
+		if status, ok := want[v.Id]; !ok || status != v.Data["status"] {
+			t.Errorf("交易对%s更新错误：%v", v.Symbol, v.Data)
+		}
+	}
+
+	// 不插入新交易对时只维护已有的
+	if inserts, _ = diffSymbols("binance", existing, latest, false); 0 < len(inserts) {
+		t.Errorf("关闭插入后仍新增：%+v", inserts)
+	}
+
+	// gate按每张数量更新
+	_, updates = diffSymbols("gate",
+		map[string]*entity.LhCoinSymbol{"BTC": {Id: 1, Symbol: "BTC", QuantoMultiplier: 0.0001, Status: symbolTrading}},
+		map[string]*entity.LhCoinSymbol{"BTC": {Symbol: "BTC", QuantoMultiplier: 0.001, Status: symbolDelisting}},
+		true,
+	)
+	if 1 != len(updates) || 0.001 != updates[0].Data["quanto_multiplier"] || symbolDelisting != updates[0].Data["status"] {
+		t.Errorf("gate更新错误：%+v", updates)
+	}
+}
@@ -0,0 +1,105 @@
+package listenandorder
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gtime"
+	"log"
+	"math"
+	"plat_order/internal/model/entity"
+	"strings"
+)
+
+// HandleWebhook 处理webhook信号，开仓按虚拟保证金百分比折算数量，平仓按仓位百分比
+func (s *sListenAndOrder) HandleWebhook(ctx context.Context, alert *entity.WebhookAlert) error {
+	s.journalWebhook(alert)
+
+	tmpTrader := s.Traders.Get(int(alert.TraderId))
+	if nil == tmpTrader {
+		return gerror.Newf("交易员不存在：%d", alert.TraderId)
+	}
+
+	trader := tmpTrader.(*Trader)
+	if _, ok := trader.Source.(*webhookSource); !ok {
+		return gerror.Newf("交易员不是webhook来源：%d", alert.TraderId)
+	}
+
+	_, token := trader.credentials()
+	if 1 != subtle.ConstantTimeCompare([]byte(token), []byte(alert.Token)) {
+		return gerror.Newf("webhook token错误：%d", alert.TraderId)
+	}
+
+	var (
+		symbol       = strings.ToUpper(alert.Symbol)
+		positionSide string
+	)
+	if "long" == strings.ToLower(alert.Side) {
+		positionSide = "LONG"
+	} else if "short" == strings.ToLower(alert.Side) {
+		positionSide = "SHORT"
+	} else {
+		return gerror.Newf("webhook方向错误：%s", alert.Side)
+	}
+
+	trader.orderMu.Lock()
+	defer s.unlockOrder(trader)
+
+	var lastAmount float64
+	tmpPosition := trader.Position.Get(symbol + positionSide)
+	if nil != tmpPosition {
+		lastAmount = tmpPosition.(*TraderPosition).PositionAmount
+	}
+
+	var (
+		newAmount float64
+		oQ        float64
+		status    = "OPEN"
+	)
+	switch strings.ToLower(alert.Action) {
+	case "open":
+		if lessThanOrEqualZero(alert.Size, 1e-7) {
+			return gerror.Newf("webhook开仓比例错误：%f", alert.Size)
+		}
+
+		// 合约按标记价格折算
+		price := s.getMarkPrice(symbol)
+		if lessThanOrEqualZero(price, 1e-7) {
+			return gerror.Newf("webhook获取标记价格错误：%s", symbol)
+		}
+
+		oQ = trader.Money.Val() * alert.Size / 100 / price
+		newAmount = lastAmount + oQ
+	case "close":
+		if lessThanOrEqualZero(lastAmount, 1e-7) {
+			return gerror.Newf("webhook平仓，无仓位：%s%s", symbol, positionSide)
+		}
+
+		size := alert.Size
+		if lessThanOrEqualZero(size, 1e-7) || size >= 100 {
+			size = 100
+			status = "CLOSE"
+		}
+
+		oQ = lastAmount * size / 100
+		newAmount = math.Max(lastAmount-oQ, 0)
+	default:
+		return gerror.Newf("webhook操作错误：%s", alert.Action)
+	}
+
+	tmpMsg := hedgeOrderInfo(symbol, positionSide, status, lastAmount, newAmount, oQ)
+	if nil == tmpMsg {
+		return gerror.Newf("webhook仓位错误：%s%s，%f，%f", symbol, positionSide, lastAmount, newAmount)
+	}
+
+	trader.Position.Set(symbol+positionSide, &TraderPosition{
+		Symbol:         symbol,
+		PositionSide:   positionSide,
+		PositionAmount: newAmount,
+	})
+
+	// 无真实订单，用时间作为来源订单id，用户执行时去重
+	log.Println("新仓位信息，webhook:", trader.Id, tmpMsg)
+	s.pushTraderSignal(trader, tmpMsg, gtime.TimestampNano(), 0)
+	return nil
+}
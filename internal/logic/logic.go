@@ -9,5 +9,6 @@ import (
 	_ "plat_order/internal/logic/gate"
 	_ "plat_order/internal/logic/listenandorder"
 	_ "plat_order/internal/logic/orderqueue"
+	_ "plat_order/internal/logic/simexchange"
 	_ "plat_order/internal/logic/user"
 )
@@ -2,17 +2,47 @@ package orderqueue
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/gogf/gf/v2/container/gmap"
 	"github.com/gogf/gf/v2/container/gqueue"
 	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/gogf/gf/v2/os/gtime"
 	"log"
 	"plat_order/internal/model/entity"
 	"plat_order/internal/service"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	queueStatusPending = 0 // 待执行
+	queueStatusAcked   = 1 // 已执行
+	queueStatusExpired = 2 // 过期未执行
+
+	replayExpire = 5 * time.Minute // 重启后超过该时间的开仓信号不再执行，配置queue.replayExpire，如10m
+	retention    = 72 * time.Hour  // 已执行和过期信号的保留时间，配置queue.retention
 )
 
 type (
 	sOrderQueue struct {
 		safeUserQueue *gmap.IntAnyMap
+		store         queueStore // 信号落库
+	}
+
+	// userQueue 用户队列，推送和绑定加锁，保证入队顺序与落库顺序一致
+	userQueue struct {
+		mu    sync.Mutex
+		queue *gqueue.Queue
+	}
+
+	// queueItem 队列元素，batch为落库批次，空为落库失败
+	queueItem struct {
+		batch  string
+		value  interface{}
+		replay bool // 重启后重放
 	}
 )
 
@@ -23,30 +53,75 @@ func init() {
 func New() *sOrderQueue {
 	return &sOrderQueue{
 		safeUserQueue: gmap.NewIntAnyMap(true),
+		store:         &dbStore{},
 	}
 }
 
-// BindUserAndQueue 绑定用户队列
+// BindUserAndQueue 绑定用户队列，并重放未执行的信号
 func (s *sOrderQueue) BindUserAndQueue(userId int) (err error) {
-	if v, ok := s.safeUserQueue.Get(userId).(*gqueue.Queue); ok {
-		if 0 < v.Len() {
+	uq := s.safeUserQueue.GetOrSetFuncLock(userId, func() interface{} {
+		return &userQueue{}
+	}).(*userQueue)
+
+	uq.mu.Lock()
+	defer uq.mu.Unlock()
+
+	if nil != uq.queue {
+		if 0 < uq.queue.Len() {
 			return gerror.Newf("BindUserAndQueue，队列len不为0，%d", userId)
 		}
 
-		// todo 仍然不是很安全，在这正确可能突然push进来数据
-		v.Close()
+		uq.queue.Close()
 	}
 
-	q := gqueue.New()
-	s.safeUserQueue.Set(userId, q)
+	uq.queue = gqueue.New()
+	s.replay(userId, uq.queue)
+
 	log.Println("新增协程：", userId)
 	return err
 }
 
+// replay 重放未执行的信号，过期的开仓信号标记后丢弃，平仓信号不论时间都重放，避免用户一直持有交易员已平的仓位
+func (s *sOrderQueue) replay(userId int, queue *gqueue.Queue) {
+	var (
+		ctx    = gctx.New()
+		expire = g.Cfg().MustGet(ctx, "queue.replayExpire", replayExpire).Duration()
+	)
+
+	items, err := s.store.Pending(ctx, userId)
+	if nil != err {
+		log.Println("重放队列，数据库查询错误：", userId, err)
+		return
+	}
+
+	for _, v := range items {
+		var msg *entity.OrderInfo
+		err = json.Unmarshal([]byte(v.Msg), &msg)
+		if nil != err || nil == msg {
+			log.Println("重放队列，解析错误：", userId, v.Id, v.Msg, err)
+			s.setStatus(ctx, userId, v.Batch, queueStatusExpired)
+			continue
+		}
+
+		if "CLOSE" != msg.Status && nil != v.CreatedAt && time.Since(v.CreatedAt.Time) > expire {
+			log.Println("重放队列，开仓信号过期，不再执行：", userId, v.Id, v.CreatedAt, v.Msg)
+			s.setStatus(ctx, userId, v.Batch, queueStatusExpired)
+			continue
+		}
+
+		log.Println("重放队列：", userId, v.Id, msg)
+		queue.Push(&queueItem{batch: v.Batch, value: msg, replay: true})
+	}
+}
+
 // UnBindUserAndQueue 解除绑定
 func (s *sOrderQueue) UnBindUserAndQueue(userId int) (err error) {
-	if v, ok := s.safeUserQueue.Get(userId).(*gqueue.Queue); ok {
-		v.Close()
+	if uq, ok := s.safeUserQueue.Get(userId).(*userQueue); ok {
+		uq.mu.Lock()
+		if nil != uq.queue {
+			uq.queue.Close()
+		}
+		uq.mu.Unlock()
 	}
 
 	s.safeUserQueue.Remove(userId)
@@ -55,38 +130,132 @@ func (s *sOrderQueue) UnBindUserAndQueue(userId int) (err error) {
 
 // PushAllQueue 向所有订单队列推送消息
 func (s *sOrderQueue) PushAllQueue(msg interface{}) {
-	s.safeUserQueue.Iterator(func(userId int, v interface{}) bool {
-		if queue, ok := v.(*gqueue.Queue); ok {
-			queue.Push(msg)
-		} else {
-			log.Println("PushAllQueue，无队列信息", userId)
+	s.PushQueues(s.safeUserQueue.Keys(), msg)
+}
+
+// PushQueue 向用户订单队列推送消息，先落库再入队
+func (s *sOrderQueue) PushQueue(userId int, msg interface{}) {
+	s.PushQueues([]int{userId}, msg)
+}
+
+// PushQueues 向多个用户订单队列推送同一消息，一次落库后按用户入队。
+// 按用户id顺序加锁，落库和入队期间持有，保证每个用户的入队顺序与落库顺序一致
+func (s *sOrderQueue) PushQueues(userIds []int, msg interface{}) {
+	sorted := make([]int, len(userIds))
+	copy(sorted, userIds)
+	sort.Ints(sorted)
+
+	var (
+		ids    = make([]int, 0, len(sorted))
+		queues = make([]*userQueue, 0, len(sorted))
+	)
+	for _, userId := range sorted {
+		uq, ok := s.safeUserQueue.Get(userId).(*userQueue)
+		if !ok {
+			log.Println("PushQueue，无队列信息", userId)
+			continue
 		}
 
-		return true
-	})
+		uq.mu.Lock()
+		defer uq.mu.Unlock()
+
+		if nil == uq.queue {
+			log.Println("PushQueue，无队列信息", userId)
+			continue
+		}
+
+		ids = append(ids, userId)
+		queues = append(queues, uq)
+	}
+
+	if 0 >= len(queues) {
+		return
+	}
+
+	batch := s.save(ids, msg)
+	for _, uq := range queues {
+		uq.queue.Push(&queueItem{batch: batch, value: msg})
+	}
+}
+
+// save 信号落库，失败时仍入队执行，但重启后无法重放。
+// 分多条语句写入时部分用户可能已落库，仍返回批次号，执行后按批次确认已写入的记录
+func (s *sOrderQueue) save(userIds []int, msg interface{}) string {
+	content, err := json.Marshal(msg)
+	if nil != err {
+		log.Println("队列落库，序列化错误：", userIds, msg, err)
+		return ""
+	}
+
+	batch, err := s.store.Insert(gctx.New(), userIds, string(content))
+	if nil != err {
+		log.Println("队列落库，数据库错误：", userIds, msg, err)
+	}
+
+	return batch
+}
+
+// setStatus 更新信号状态
+func (s *sOrderQueue) setStatus(ctx context.Context, userId int, batch string, status int) {
+	if 0 >= len(batch) {
+		return
+	}
+
+	err := s.store.SetStatus(ctx, userId, batch, status)
+	if nil != err {
+		log.Println("队列状态更新，数据库错误：", userId, batch, status, err)
+	}
+}
+
+// CleanQueue 删除超过保留时间的已执行和过期信号
+func (s *sOrderQueue) CleanQueue(ctx context.Context) {
+	before := gtime.Now().Add(-g.Cfg().MustGet(ctx, "queue.retention", retention).Duration())
+	total, err := s.store.Clean(ctx, before)
+	if nil != err {
+		log.Println("清理队列，数据库错误：", before, total, err)
+		return
+	}
+
+	if 0 < total {
+		log.Println("清理队列：", before, total)
+	}
 }
 
-// ListenQueue 监听队列
+// ListenQueue 监听队列，执行完成后确认
 func (s *sOrderQueue) ListenQueue(ctx context.Context, userId int, do func(context.Context, *entity.DoValue)) {
-	queue, ok := s.safeUserQueue.Get(userId).(*gqueue.Queue)
+	uq, ok := s.safeUserQueue.Get(userId).(*userQueue)
 	if !ok {
 		log.Println("ListenQueue，无队列信息", userId)
 		return
 	}
+
+	uq.mu.Lock()
+	queue := uq.queue
+	uq.mu.Unlock()
+
+	if nil == queue {
+		log.Println("ListenQueue，无队列信息", userId)
+		return
+	}
 	log.Println("开启协程：", userId, queue)
 
 	for {
-		queueItem := <-queue.C
-		if nil == queueItem { // 队列被关闭
+		tmpItem := <-queue.C
+		if nil == tmpItem { // 队列被关闭
 			log.Println("ListenQueue，监听通道被关闭", userId)
 			break
 		}
 
+		item := tmpItem.(*queueItem)
+
 		// 执行
 		do(ctx, &entity.DoValue{
 			UserId: userId,
-			Value:  queueItem,
+			Value:  item.value,
+			Replay: item.replay,
 		})
+
+		s.setStatus(ctx, userId, item.batch, queueStatusAcked)
 	}
 
 	log.Println("ListenQueue，结束监听", userId)
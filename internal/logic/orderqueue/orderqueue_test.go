@@ -0,0 +1,201 @@
+package orderqueue
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+	"github.com/gogf/gf/v2/os/gtime"
+	"plat_order/internal/model/entity"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore 内存落库，按写入顺序分配id
+type memoryStore struct {
+	mu      sync.Mutex
+	rows    []*entity.OrderQueue
+	inserts int
+}
+
+func (st *memoryStore) Insert(ctx context.Context, userIds []int, msg string) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.inserts++
+	batch := fmt.Sprintf("batch%d", st.inserts)
+	for _, userId := range userIds {
+		st.add(userId, batch, msg, gtime.Now())
+	}
+
+	return batch, nil
+}
+
+func (st *memoryStore) add(userId int, batch, msg string, createdAt *gtime.Time) {
+	st.rows = append(st.rows, &entity.OrderQueue{
+		Id:        uint64(len(st.rows) + 1),
+		UserId:    uint(userId),
+		Batch:     batch,
+		Msg:       msg,
+		Status:    queueStatusPending,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	})
+}
+
+func (st *memoryStore) Pending(ctx context.Context, userId int) ([]*entity.OrderQueue, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	res := make([]*entity.OrderQueue, 0)
+	for _, v := range st.rows {
+		if uint(userId) == v.UserId && queueStatusPending == v.Status {
+			tmp := *v
+			res = append(res, &tmp)
+		}
+	}
+
+	return res, nil
+}
+
+func (st *memoryStore) SetStatus(ctx context.Context, userId int, batch string, status int) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, v := range st.rows {
+		if uint(userId) == v.UserId && batch == v.Batch {
+			v.Status = uint(status)
+			v.UpdatedAt = gtime.Now()
+		}
+	}
+
+	return nil
+}
+
+func (st *memoryStore) Clean(ctx context.Context, before *gtime.Time) (int64, error) {
+	return 0, nil
+}
+
+// status 用户某批次信号的状态
+func (st *memoryStore) status(userId int, batch string) uint {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for _, v := range st.rows {
+		if uint(userId) == v.UserId && batch == v.Batch {
+			return v.Status
+		}
+	}
+
+	return 99
+}
+
+func newTestQueue(t *testing.T, store *memoryStore) *sOrderQueue {
+	adapter, err := gcfg.NewAdapterContent(`{}`)
+	if nil != err {
+		t.Fatalf("配置错误：%v", err)
+	}
+	g.Cfg().SetAdapter(adapter)
+
+	s := New()
+	s.store = store
+	return s
+}
+
+// TestReplay 重启后重放待执行信号，过期的开仓信号丢弃，平仓信号不论时间都重放
+func TestReplay(t *testing.T) {
+	var (
+		store = &memoryStore{}
+		old   = gtime.Now().Add(-time.Hour)
+	)
+	store.add(1, "openOld", `{"Symbol":"BTCUSDT","Status":"OPEN"}`, old)
+	store.add(1, "closeOld", `{"Symbol":"BTCUSDT","Status":"CLOSE"}`, old)
+	store.add(1, "invalid", `{`, gtime.Now())
+	store.add(1, "openNew", `{"Symbol":"ETHUSDT","Status":"OPEN"}`, gtime.Now())
+	store.add(2, "other", `{"Symbol":"BTCUSDT","Status":"OPEN"}`, gtime.Now())
+
+	s := newTestQueue(t, store)
+	if err := s.BindUserAndQueue(1); nil != err {
+		t.Fatalf("绑定队列错误：%v", err)
+	}
+
+	queue := s.safeUserQueue.Get(1).(*userQueue).queue
+	if 2 != queue.Len() {
+		t.Fatalf("重放数量%d，期望2", queue.Len())
+	}
+
+	for _, want := range []string{"closeOld", "openNew"} {
+		item := (<-queue.C).(*queueItem)
+		if want != item.batch || !item.replay {
+			t.Errorf("重放顺序错误：%s，期望%s", item.batch, want)
+		}
+	}
+
+	for batch, want := range map[string]uint{"openOld": queueStatusExpired, "invalid": queueStatusExpired, "closeOld": queueStatusPending, "openNew": queueStatusPending} {
+		if status := store.status(1, batch); want != status {
+			t.Errorf("%s状态%d，期望%d", batch, status, want)
+		}
+	}
+
+	if queueStatusPending != store.status(2, "other") {
+		t.Errorf("其他用户的信号被修改")
+	}
+}
+
+// TestPushAndAck 同一信号一次落库，执行后按用户确认，未执行的用户重启后重放
+func TestPushAndAck(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &memoryStore{}
+		s     = newTestQueue(t, store)
+		msg   = &entity.OrderInfo{Symbol: "BTCUSDT", Status: "OPEN", TraderId: 7, OrderId: 100}
+	)
+	for _, userId := range []int{1, 2} {
+		if err := s.BindUserAndQueue(userId); nil != err {
+			t.Fatalf("绑定队列错误：%v", err)
+		}
+	}
+
+	s.PushQueues([]int{2, 1, 3}, msg)
+	if 1 != store.inserts || 2 != len(store.rows) {
+		t.Fatalf("落库次数%d，记录数%d", store.inserts, len(store.rows))
+	}
+
+	done := make(chan *entity.DoValue, 1)
+	go s.ListenQueue(ctx, 1, func(ctx context.Context, doValue *entity.DoValue) {
+		done <- doValue
+	})
+
+	select {
+	case doValue := <-done:
+		if 1 != doValue.UserId || doValue.Replay || msg != doValue.Value {
+			t.Errorf("执行的信号错误：%+v", doValue)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("信号未执行")
+	}
+
+	for i := 0; queueStatusAcked != store.status(1, "batch1"); i++ {
+		if 100 <= i {
+			t.Fatalf("执行后未确认")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = s.UnBindUserAndQueue(1)
+
+	// 用户2未执行，重启后重放
+	restarted := newTestQueue(t, store)
+	if err := restarted.BindUserAndQueue(2); nil != err {
+		t.Fatalf("绑定队列错误：%v", err)
+	}
+
+	item := (<-restarted.safeUserQueue.Get(2).(*userQueue).queue.C).(*queueItem)
+	if "batch1" != item.batch || !item.replay || 7 != item.value.(*entity.OrderInfo).TraderId {
+		t.Errorf("重放的信号错误：%+v", item)
+	}
+
+	if err := restarted.BindUserAndQueue(1); nil != err || 0 != restarted.safeUserQueue.Get(1).(*userQueue).queue.Len() {
+		t.Errorf("已确认的信号被重放：%v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package orderqueue
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/gogf/gf/v2/util/guid"
+	"plat_order/internal/model/do"
+	"plat_order/internal/model/entity"
+)
+
+const (
+	queueInsertBatch = 500  // 每条insert语句最多写入的用户数
+	queueCleanBatch  = 5000 // 每条delete语句最多删除的记录数
+)
+
+// queueStore 队列信号落库，重启后按落库顺序重放
+type queueStore interface {
+	// Insert 同一信号每个用户写一条，一次写入，返回批次号用于确认
+	Insert(ctx context.Context, userIds []int, msg string) (string, error)
+	// Pending 用户待执行的信号，按落库顺序
+	Pending(ctx context.Context, userId int) ([]*entity.OrderQueue, error)
+	// SetStatus 更新用户某批次信号的状态
+	SetStatus(ctx context.Context, userId int, batch string, status int) error
+	// Clean 删除更新时间早于before的已执行和过期信号，返回删除数量
+	Clean(ctx context.Context, before *gtime.Time) (int64, error)
+}
+
+// dbStore 写入order_queue，见manifest/sql/order_queue.sql
+type dbStore struct{}
+
+func (st *dbStore) Insert(ctx context.Context, userIds []int, msg string) (string, error) {
+	var (
+		batch = guid.S()
+		rows  = make([]*do.OrderQueue, 0, len(userIds))
+	)
+	for _, userId := range userIds {
+		rows = append(rows, &do.OrderQueue{
+			UserId:    userId,
+			Batch:     batch,
+			Msg:       msg,
+			Status:    queueStatusPending,
+			CreatedAt: gtime.Now(),
+			UpdatedAt: gtime.Now(),
+		})
+	}
+
+	_, err := g.Model("order_queue").Ctx(ctx).Data(rows).Batch(queueInsertBatch).Insert()
+	return batch, err
+}
+
+func (st *dbStore) Pending(ctx context.Context, userId int) ([]*entity.OrderQueue, error) {
+	var items []*entity.OrderQueue
+	err := g.Model("order_queue").Ctx(ctx).
+		Where("user_id=? AND status=?", userId, queueStatusPending).
+		OrderAsc("id").
+		Scan(&items)
+
+	return items, err
+}
+
+func (st *dbStore) SetStatus(ctx context.Context, userId int, batch string, status int) error {
+	_, err := g.Model("order_queue").Ctx(ctx).
+		Data(g.Map{"status": status, "updated_at": gtime.Now()}).
+		Where("user_id=? AND batch=?", userId, batch).
+		Update()
+
+	return err
+}
+
+func (st *dbStore) Clean(ctx context.Context, before *gtime.Time) (int64, error) {
+	var total int64
+	for {
+		res, err := g.Model("order_queue").Ctx(ctx).
+			Where("status IN(?) AND updated_at<?", g.Slice{queueStatusAcked, queueStatusExpired}, before).
+			Limit(queueCleanBatch).
+			Delete()
+		if nil != err {
+			return total, err
+		}
+
+		affected, err := res.RowsAffected()
+		if nil != err {
+			return total, err
+		}
+
+		total += affected
+		if queueCleanBatch > affected {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package simexchange
+
+import (
+	"encoding/json"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/shopspring/decimal"
+	"plat_order/internal/model/entity"
+	"strings"
+)
+
+// simSnapshot 模拟账户持久化内容，价格不保存
+type simSnapshot struct {
+	OrderId   int64                                 `json:"orderId"`
+	Positions map[string]map[string]decimal.Decimal `json:"positions"`
+	Entries   map[string]map[string]decimal.Decimal `json:"entries"`
+	Balances  map[string]decimal.Decimal            `json:"balances"`
+	Realized  map[string]decimal.Decimal            `json:"realized"`
+	Fees      map[string]decimal.Decimal            `json:"fees"`
+	Fills     map[string]int                        `json:"fills"`
+}
+
+// SetPriceFeed 设置实时价格来源，下单和查询仓位时拉取最新价
+func (s *sSimExchange) SetPriceFeed(feed func(symbol string) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feed = feed
+}
+
+// OpenAccount 开通模拟账户，已存在的账户不变，返回是否新开
+func (s *sSimExchange) OpenAccount(apiKey string, balance float64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.balances[apiKey]; ok {
+		return false
+	}
+
+	s.balances[apiKey] = decimal.NewFromFloat(balance)
+	return true
+}
+
+// GetAccount 模拟账户资金和盈亏，单位USDT
+func (s *sSimExchange) GetAccount(apiKey string) *entity.SimAccount {
+	s.refreshPositionPrices(apiKey)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var unrealized decimal.Decimal
+	for k := range s.positions[apiKey] {
+		unrealized = unrealized.Add(s.unrealized(apiKey, k))
+	}
+
+	res := &entity.SimAccount{
+		ApiKey: apiKey,
+		Fills:  s.fills[apiKey],
+	}
+	res.Balance, _ = s.balances[apiKey].Float64()
+	res.Realized, _ = s.realized[apiKey].Float64()
+	res.Fees, _ = s.fees[apiKey].Float64()
+	res.Unrealized, _ = unrealized.Float64()
+	res.MarginBalance, _ = s.marginBalance(apiKey).Float64()
+	return res
+}
+
+// Load 从文件恢复模拟账户
+func (s *sSimExchange) Load(file string) error {
+	if !gfile.Exists(file) {
+		return nil
+	}
+
+	var snapshot *simSnapshot
+	err := json.Unmarshal(gfile.GetBytes(file), &snapshot)
+	if nil != err || nil == snapshot {
+		return err
+	}
+
+	if nil == snapshot.Positions {
+		snapshot.Positions = make(map[string]map[string]decimal.Decimal, 0)
+	}
+	if nil == snapshot.Entries {
+		snapshot.Entries = make(map[string]map[string]decimal.Decimal, 0)
+	}
+	if nil == snapshot.Balances {
+		snapshot.Balances = make(map[string]decimal.Decimal, 0)
+	}
+	if nil == snapshot.Realized {
+		snapshot.Realized = make(map[string]decimal.Decimal, 0)
+	}
+	if nil == snapshot.Fees {
+		snapshot.Fees = make(map[string]decimal.Decimal, 0)
+	}
+	if nil == snapshot.Fills {
+		snapshot.Fills = make(map[string]int, 0)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orderId = snapshot.OrderId
+	s.positions = snapshot.Positions
+	s.entries = snapshot.Entries
+	s.balances = snapshot.Balances
+	s.realized = snapshot.Realized
+	s.fees = snapshot.Fees
+	s.fills = snapshot.Fills
+	return nil
+}
+
+// Flush 模拟账户写入文件
+func (s *sSimExchange) Flush(file string) error {
+	s.mu.Lock()
+	content, err := json.Marshal(&simSnapshot{
+		OrderId:   s.orderId,
+		Positions: s.positions,
+		Entries:   s.entries,
+		Balances:  s.balances,
+		Realized:  s.realized,
+		Fees:      s.fees,
+		Fills:     s.fills,
+	})
+	s.mu.Unlock()
+
+	if nil != err {
+		return err
+	}
+
+	return gfile.PutBytes(file, content)
+}
+
+// refreshPrice 有实时价格来源时拉取最新价，在锁外请求
+func (s *sSimExchange) refreshPrice(symbol string) {
+	s.mu.Lock()
+	feed := s.feed
+	s.mu.Unlock()
+
+	if nil == feed {
+		return
+	}
+
+	price, err := decimal.NewFromString(feed(symbol))
+	if nil != err || !price.IsPositive() {
+		return
+	}
+
+	s.mu.Lock()
+	s.prices[symbol] = price
+	s.mu.Unlock()
+}
+
+// refreshPositionPrices 拉取账户持仓交易对的最新价
+func (s *sSimExchange) refreshPositionPrices(apiKey string) {
+	s.mu.Lock()
+	symbols := make(map[string]struct{}, 0)
+	for k, v := range s.positions[apiKey] {
+		if !v.IsZero() {
+			symbols[strings.Split(k, "&")[0]] = struct{}{}
+		}
+	}
+	s.mu.Unlock()
+
+	for symbol := range symbols {
+		s.refreshPrice(symbol)
+	}
+}
+
+// unrealized 仓位未实现盈亏，调用方持有锁
+func (s *sSimExchange) unrealized(apiKey string, key string) decimal.Decimal {
+	amount := s.positions[apiKey][key]
+	price, ok := s.prices[strings.Split(key, "&")[0]]
+	if amount.IsZero() || !ok {
+		return decimal.Zero
+	}
+
+	if strings.HasSuffix(key, "&SHORT") {
+		amount = amount.Neg()
+	}
+
+	return price.Sub(s.entries[apiKey][key]).Mul(amount)
+}
+
+// marginBalance 入金+已实现盈亏-手续费+未实现盈亏，调用方持有锁
+func (s *sSimExchange) marginBalance(apiKey string) decimal.Decimal {
+	res := s.balances[apiKey].Add(s.realized[apiKey]).Sub(s.fees[apiKey])
+	for k := range s.positions[apiKey] {
+		res = res.Add(s.unrealized(apiKey, k))
+	}
+
+	return res
+}
@@ -0,0 +1,103 @@
+package simexchange
+
+import (
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"math"
+	"plat_order/internal/logic/binance"
+	"plat_order/internal/model/entity"
+	"strconv"
+)
+
+// QueryBinanceOrder 查询订单
+func (s *sSimExchange) QueryBinanceOrder(symbol string, orderId int64, apiK, apiS string) (*entity.BinanceOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderId]
+	if !ok || symbol != order.Symbol {
+		return nil, binance.NewError(-2013, "Order does not exist.")
+	}
+
+	return order, nil
+}
+
+// QueryBinanceOrderByClientId 按clientOrderId查询订单
+func (s *sSimExchange) QueryBinanceOrderByClientId(symbol string, clientOrderId string, apiK, apiS string) (*entity.BinanceOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, order := range s.orders {
+		if 0 < len(clientOrderId) && clientOrderId == order.ClientOrderId && symbol == order.Symbol {
+			return order, nil
+		}
+	}
+
+	return nil, binance.NewError(-2013, "Order does not exist.")
+}
+
+// BinanceOrderFill 模拟交易所订单立即成交
+func (s *sSimExchange) BinanceOrderFill(order *entity.BinanceOrder, apiK, apiS string) (*entity.OrderFill, error) {
+	if nil == order || 0 >= order.OrderId {
+		return nil, gerror.New("invalid order")
+	}
+
+	executedQty, _ := strconv.ParseFloat(order.ExecutedQty, 64)
+	avgPrice, _ := strconv.ParseFloat(order.AvgPrice, 64)
+
+	return &entity.OrderFill{
+		Plat:        "binance",
+		OrderId:     strconv.FormatInt(order.OrderId, 10),
+		Symbol:      order.Symbol,
+		Status:      order.Status,
+		Final:       true,
+		ExecutedQty: executedQty,
+		AvgPrice:    avgPrice,
+	}, nil
+}
+
+// GetOrderGate 查询订单
+func (s *sSimExchange) GetOrderGate(apiK, apiS string, orderId int64) (gateapi.FuturesOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.gateOrders[orderId]
+	if !ok {
+		return gateapi.FuturesOrder{}, gerror.Newf("order does not exist: %d", orderId)
+	}
+
+	return order, nil
+}
+
+// GetOrderGateByText 按text查询订单，未找到时返回的订单id为0
+func (s *sSimExchange) GetOrderGateByText(apiK, apiS, contract, text string) (gateapi.FuturesOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, order := range s.gateOrders {
+		if 0 < len(text) && text == order.Text && contract == order.Contract {
+			return order, nil
+		}
+	}
+
+	return gateapi.FuturesOrder{}, nil
+}
+
+// GateOrderFill 模拟交易所订单立即成交
+func (s *sSimExchange) GateOrderFill(apiK, apiS string, order gateapi.FuturesOrder) (*entity.OrderFill, error) {
+	if 0 >= order.Id {
+		return nil, gerror.New("invalid order")
+	}
+
+	avgPrice, _ := strconv.ParseFloat(order.FillPrice, 64)
+
+	return &entity.OrderFill{
+		Plat:        "gate",
+		OrderId:     strconv.FormatInt(order.Id, 10),
+		Symbol:      gateSymbol(order.Contract),
+		Status:      order.Status + "_" + order.FinishAs,
+		Final:       true,
+		ExecutedQty: math.Abs(float64(order.Size - order.Left)),
+		AvgPrice:    avgPrice,
+	}, nil
+}
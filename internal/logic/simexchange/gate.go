@@ -0,0 +1,213 @@
+package simexchange
+
+import (
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/shopspring/decimal"
+	"sort"
+	"strings"
+)
+
+// gateSymbol gate合约转为币安交易对，BTC_USDT => BTCUSDT
+func gateSymbol(contract string) string {
+	return strings.ReplaceAll(contract, "_", "")
+}
+
+// gateContract 币安交易对转为gate合约，BTCUSDT => BTC_USDT
+func gateContract(symbol string) string {
+	return strings.TrimSuffix(symbol, "USDT") + "_USDT"
+}
+
+// GetGateContract 获取合约账号信息
+func (s *sSimExchange) GetGateContract(apiK, apiS string) (gateapi.FuturesAccount, error) {
+	s.refreshPositionPrices(apiK)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return gateapi.FuturesAccount{
+		Total:      s.marginBalance(apiK).String(),
+		Available:  s.marginBalance(apiK).String(),
+		Currency:   "USDT",
+		InDualMode: true,
+	}, nil
+}
+
+// GetListPositions 账户仓位，张数，双向持仓空仓为负数
+func (s *sSimExchange) GetListPositions(apiK, apiS string) ([]gateapi.Position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]gateapi.Position, 0)
+	for k, v := range s.positions[apiK] {
+		tmp := strings.Split(k, "&")
+		multiplier, ok := s.quanto[tmp[0]]
+		if !ok || !multiplier.IsPositive() {
+			continue
+		}
+
+		size := v.Div(multiplier).Round(0).IntPart()
+		mode := "single"
+		if "LONG" == tmp[1] {
+			mode = "dual_long"
+		} else if "SHORT" == tmp[1] {
+			mode = "dual_short"
+			size = -size
+		}
+
+		res = append(res, gateapi.Position{
+			Contract: gateContract(tmp[0]),
+			Size:     size,
+			Mode:     mode,
+		})
+	}
+
+	return res, nil
+}
+
+// PlaceOrderGate 双向持仓下单，size正数买负数卖，只减仓或autoSize时为平仓，autoSize且size为0时全平
+func (s *sSimExchange) PlaceOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, autoSize string, text string) (gateapi.FuturesOrder, error) {
+	symbol := gateSymbol(contract)
+	s.refreshPrice(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	price, multiplier, err := s.gatePrice(symbol)
+	if nil != err {
+		return gateapi.FuturesOrder{}, err
+	}
+
+	var (
+		positionSide string
+		delta        = decimal.NewFromInt(size).Mul(multiplier).Abs()
+	)
+	if "close_long" == autoSize || reduceOnly && 0 > size {
+		positionSide = "LONG"
+	} else if "close_short" == autoSize || reduceOnly && 0 < size {
+		positionSide = "SHORT"
+	} else if 0 < size {
+		positionSide = "LONG"
+	} else if 0 > size {
+		positionSide = "SHORT"
+	} else {
+		return gateapi.FuturesOrder{}, gerror.New("invalid size")
+	}
+
+	key := symbol + "&" + positionSide
+	current := s.positions[apiK][key]
+	if 0 < len(autoSize) || reduceOnly {
+		if 0 == size {
+			delta = current
+		}
+
+		if !delta.IsPositive() || delta.GreaterThan(current) {
+			return gateapi.FuturesOrder{}, gerror.New("reduce only order is rejected")
+		}
+
+		delta = delta.Neg()
+	}
+
+	s.fill(apiK, key, delta, price)
+
+	// 空仓增加为卖出
+	if "SHORT" == positionSide {
+		return s.gateOrder(contract, delta.Neg(), multiplier, price, text), nil
+	}
+
+	return s.gateOrder(contract, delta, multiplier, price, text), nil
+}
+
+// PlaceBothOrderGate 单向持仓下单，close时全平
+func (s *sSimExchange) PlaceBothOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, close bool, text string) (gateapi.FuturesOrder, error) {
+	symbol := gateSymbol(contract)
+	s.refreshPrice(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	price, multiplier, err := s.gatePrice(symbol)
+	if nil != err {
+		return gateapi.FuturesOrder{}, err
+	}
+
+	var (
+		key     = symbol + "&BOTH"
+		current = s.positions[apiK][key]
+		delta   = decimal.NewFromInt(size).Mul(multiplier)
+	)
+	if close {
+		delta = current.Neg()
+	}
+
+	if reduceOnly && (current.Sign()*delta.Sign() >= 0 || delta.Abs().GreaterThan(current.Abs())) {
+		return gateapi.FuturesOrder{}, gerror.New("reduce only order is rejected")
+	}
+
+	if delta.IsZero() {
+		return gateapi.FuturesOrder{}, gerror.New("invalid size")
+	}
+
+	s.fill(apiK, key, delta, price)
+
+	return s.gateOrder(contract, delta, multiplier, price, text), nil
+}
+
+// SetDual 模拟账户同时支持单向和双向持仓
+func (s *sSimExchange) SetDual(apiK, apiS string, dual bool) (bool, error) {
+	return true, nil
+}
+
+// ListGateContracts 设置了每张币数量的交易对
+func (s *sSimExchange) ListGateContracts() ([]gateapi.Contract, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]gateapi.Contract, 0, len(s.quanto))
+	for symbol, multiplier := range s.quanto {
+		res = append(res, gateapi.Contract{
+			Name:             gateContract(symbol),
+			Type:             "direct",
+			QuantoMultiplier: multiplier.String(),
+			OrderSizeMin:     1,
+			OrderSizeMax:     1000000,
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+// gateOrder 记录已成交订单，size为实际成交张数，调用方持有锁
+func (s *sSimExchange) gateOrder(contract string, delta decimal.Decimal, multiplier decimal.Decimal, price decimal.Decimal, text string) gateapi.FuturesOrder {
+	order := gateapi.FuturesOrder{
+		Id:        s.orderId,
+		Contract:  contract,
+		Text:      text,
+		Size:      delta.Div(multiplier).Round(0).IntPart(),
+		FillPrice: price.String(),
+		Status:    "finished",
+		FinishAs:  "filled",
+	}
+	s.gateOrders[order.Id] = order
+
+	return order
+}
+
+// gatePrice 最新价和每张币的数量，调用方持有锁
+func (s *sSimExchange) gatePrice(symbol string) (decimal.Decimal, decimal.Decimal, error) {
+	price, ok := s.prices[symbol]
+	if !ok {
+		return decimal.Zero, decimal.Zero, gerror.Newf("invalid symbol: %s", symbol)
+	}
+
+	multiplier, ok := s.quanto[symbol]
+	if !ok || !multiplier.IsPositive() {
+		return decimal.Zero, decimal.Zero, gerror.Newf("unknown quanto multiplier: %s", symbol)
+	}
+
+	return price, multiplier, nil
+}
@@ -0,0 +1,442 @@
+package simexchange
+
+import (
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"plat_order/internal/logic/binance"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sSimExchange 进程内模拟交易所，实现币安接口，市价单按最新价立即成交，用于离线重放、回测和模拟盘
+type sSimExchange struct {
+	mu         sync.Mutex
+	orderId    int64
+	prices     map[string]decimal.Decimal               // symbol => 最新价
+	positions  map[string]map[string]decimal.Decimal    // apiKey => symbol&positionSide => 仓位，双向持仓为正数
+	entries    map[string]map[string]decimal.Decimal    // apiKey => symbol&positionSide => 开仓均价
+	balances   map[string]decimal.Decimal               // apiKey => 入金
+	realized   map[string]decimal.Decimal               // apiKey => 已实现盈亏
+	quanto     map[string]decimal.Decimal               // symbol => gate每张币的数量
+	feeRate    decimal.Decimal                          // 吃单手续费率
+	fees       map[string]decimal.Decimal               // apiKey => 累计手续费，单位USDT
+	fills      map[string]int                           // apiKey => 成交笔数
+	feed       func(symbol string) string               // 实时价格来源，模拟盘使用，为空时只用SetPrice的价格
+	filters    map[string][]*entity.BinanceSymbolFilter // symbol => 交易规则，未设置的不校验
+	orders     map[int64]*entity.BinanceOrder           // 币安订单，供查询
+	gateOrders map[int64]gateapi.FuturesOrder           // gate订单，供查询
+}
+
+func init() {
+	service.RegisterSimExchange(New())
+}
+
+func New() *sSimExchange {
+	return &sSimExchange{
+		prices:     make(map[string]decimal.Decimal, 0),
+		positions:  make(map[string]map[string]decimal.Decimal, 0),
+		entries:    make(map[string]map[string]decimal.Decimal, 0),
+		balances:   make(map[string]decimal.Decimal, 0),
+		realized:   make(map[string]decimal.Decimal, 0),
+		quanto:     make(map[string]decimal.Decimal, 0),
+		fees:       make(map[string]decimal.Decimal, 0),
+		fills:      make(map[string]int, 0),
+		filters:    make(map[string][]*entity.BinanceSymbolFilter, 0),
+		orders:     make(map[int64]*entity.BinanceOrder, 0),
+		gateOrders: make(map[int64]gateapi.FuturesOrder, 0),
+	}
+}
+
+// Reset 清空价格、仓位、保证金、盈亏和手续费
+func (s *sSimExchange) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orderId = 0
+	s.prices = make(map[string]decimal.Decimal, 0)
+	s.positions = make(map[string]map[string]decimal.Decimal, 0)
+	s.entries = make(map[string]map[string]decimal.Decimal, 0)
+	s.balances = make(map[string]decimal.Decimal, 0)
+	s.realized = make(map[string]decimal.Decimal, 0)
+	s.quanto = make(map[string]decimal.Decimal, 0)
+	s.feeRate = decimal.Zero
+	s.fees = make(map[string]decimal.Decimal, 0)
+	s.fills = make(map[string]int, 0)
+	s.feed = nil
+	s.filters = make(map[string][]*entity.BinanceSymbolFilter, 0)
+	s.orders = make(map[int64]*entity.BinanceOrder, 0)
+	s.gateOrders = make(map[int64]gateapi.FuturesOrder, 0)
+}
+
+// SetFeeRate 设置吃单手续费率，成交时按成交额收取
+func (s *sSimExchange) SetFeeRate(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feeRate = decimal.NewFromFloat(rate)
+}
+
+// SetQuantoMultiplier 设置gate合约每张币的数量，symbol为币安格式
+func (s *sSimExchange) SetQuantoMultiplier(symbol string, multiplier float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quanto[symbol] = decimal.NewFromFloat(multiplier)
+}
+
+// SetFilters 设置交易对的交易规则，下单时按LOT_SIZE、MARKET_LOT_SIZE和MIN_NOTIONAL校验
+func (s *sSimExchange) SetFilters(symbol string, filters []*entity.BinanceSymbolFilter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.filters[symbol] = filters
+}
+
+// GetFees 账户累计手续费和成交笔数
+func (s *sSimExchange) GetFees(apiKey string) (float64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fee, _ := s.fees[apiKey].Float64()
+	return fee, s.fills[apiKey]
+}
+
+// SetPrice 更新最新价
+func (s *sSimExchange) SetPrice(symbol string, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prices[symbol] = decimal.NewFromFloat(price)
+}
+
+// SetBalance 设置账户保证金，单位USDT
+func (s *sSimExchange) SetBalance(apiKey string, balance float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.balances[apiKey] = decimal.NewFromFloat(balance)
+}
+
+// GetPositions 账户仓位，key为symbol&positionSide
+func (s *sSimExchange) GetPositions(apiKey string) map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make(map[string]float64, 0)
+	for k, v := range s.positions[apiKey] {
+		if v.IsZero() {
+			continue
+		}
+
+		res[k], _ = v.Float64()
+	}
+
+	return res
+}
+
+// GetBinancePositionSide 模拟账户统一双向持仓
+func (s *sSimExchange) GetBinancePositionSide(apiK, apiS string) string {
+	return "ALL"
+}
+
+// GetLatestPrice 获取价格
+func (s *sSimExchange) GetLatestPrice(symbol string) string {
+	s.refreshPrice(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if price, ok := s.prices[symbol]; ok {
+		return price.String()
+	}
+
+	return ""
+}
+
+// GetMarkPrice 模拟交易所标记价格即最新价
+func (s *sSimExchange) GetMarkPrice(symbol string) string {
+	return s.GetLatestPrice(symbol)
+}
+
+// GetWalletInfo 模拟账户只有合约钱包，余额按BTC计
+func (s *sSimExchange) GetWalletInfo(apiK, apiS string) []*entity.WalletInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	balance := decimal.Zero
+	if btcPrice, ok := s.prices["BTCUSDT"]; ok && btcPrice.IsPositive() {
+		balance = s.marginBalance(apiK).Div(btcPrice)
+	}
+
+	return []*entity.WalletInfo{
+		{
+			Activate:   true,
+			Balance:    balance.String(),
+			WalletName: "USDⓈ-M Futures",
+		},
+	}
+}
+
+// GetBinanceInfo 获取账户保证金，入金+已实现盈亏-手续费+未实现盈亏
+func (s *sSimExchange) GetBinanceInfo(apiK, apiS string) string {
+	s.refreshPositionPrices(apiK)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.marginBalance(apiK).String()
+}
+
+// GetBinanceAvailableBalance 可用保证金，按1倍杠杆扣除持仓占用
+func (s *sSimExchange) GetBinanceAvailableBalance(apiK, apiS string) string {
+	s.refreshPositionPrices(apiK)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	available := s.marginBalance(apiK)
+	for k, v := range s.positions[apiK] {
+		available = available.Sub(v.Abs().Mul(s.entries[apiK][k]))
+	}
+
+	return decimal.Max(available, decimal.Zero).String()
+}
+
+func (s *sSimExchange) RequestBinancePositionSide(positionSide string, apiKey string, secretKey string) (error, string, bool) {
+	return nil, "", true
+}
+
+// GetBinanceFuturesPairs 有价格的交易对
+func (s *sSimExchange) GetBinanceFuturesPairs() ([]*entity.BinanceSymbolInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]*entity.BinanceSymbolInfo, 0, len(s.prices))
+	for symbol := range s.prices {
+		res = append(res, &entity.BinanceSymbolInfo{
+			Symbol:            symbol,
+			Pair:              symbol,
+			ContractType:      "PERPETUAL",
+			Status:            "TRADING",
+			BaseAsset:         strings.TrimSuffix(symbol, "USDT"),
+			QuoteAsset:        "USDT",
+			MarginAsset:       "USDT",
+			PricePrecision:    2,
+			QuantityPrecision: 3,
+			Filters:           s.filters[symbol],
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Symbol < res[j].Symbol
+	})
+
+	return res, nil
+}
+
+// RequestBinanceOrder 按最新价立即成交，只减仓单不能超过持仓
+func (s *sSimExchange) RequestBinanceOrder(symbol string, side string, orderType string, positionSide string, quantity string, apiKey string, secretKey string, reduceOnly bool, clientOrderId string) (*entity.BinanceOrder, *entity.BinanceOrderInfo, error) {
+	s.refreshPrice(symbol)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	qty, err := decimal.NewFromString(quantity)
+	if nil != err || !qty.IsPositive() {
+		return rejectBinanceOrder(-1013, "Invalid quantity.")
+	}
+
+	price, ok := s.prices[symbol]
+	if !ok {
+		return rejectBinanceOrder(-1121, "Invalid symbol.")
+	}
+
+	if info := s.checkFilters(symbol, orderType, qty, price, reduceOnly); nil != info {
+		return rejectBinanceOrder(info.Code, info.Msg)
+	}
+
+	var (
+		key     = symbol + "&" + positionSide
+		current = s.positions[apiKey][key]
+		delta   = qty
+	)
+	switch positionSide {
+	case "LONG":
+		if "SELL" == side {
+			delta = qty.Neg()
+		}
+	case "SHORT":
+		if "BUY" == side {
+			delta = qty.Neg()
+		}
+	case "BOTH":
+		if "SELL" == side {
+			delta = qty.Neg()
+		}
+	default:
+		return rejectBinanceOrder(-4061, "Order's position side does not match user's setting.")
+	}
+
+	if reduceOnly {
+		reduce := "BOTH" == positionSide && current.Sign()*delta.Sign() < 0 || "BOTH" != positionSide && delta.IsNegative()
+		if !reduce || delta.Abs().GreaterThan(current.Abs()) {
+			return rejectBinanceOrder(-2022, "ReduceOnly Order is rejected.")
+		}
+	}
+
+	if "BOTH" != positionSide && current.Add(delta).IsNegative() {
+		return rejectBinanceOrder(-2022, "ReduceOnly Order is rejected.")
+	}
+
+	s.fill(apiKey, key, delta, price)
+
+	order := &entity.BinanceOrder{
+		OrderId:       s.orderId,
+		ExecutedQty:   qty.String(),
+		ClientOrderId: clientOrderId,
+		Symbol:        symbol,
+		AvgPrice:      price.String(),
+		CumQuote:      qty.Mul(price).String(),
+		Side:          side,
+		PositionSide:  positionSide,
+		Type:          orderType,
+		Status:        "FILLED",
+	}
+	s.orders[order.OrderId] = order
+
+	return order, &entity.BinanceOrderInfo{}, nil
+}
+
+// rejectBinanceOrder 下单被拒，与币安返回一致
+func rejectBinanceOrder(code int64, msg string) (*entity.BinanceOrder, *entity.BinanceOrderInfo, error) {
+	return nil, &entity.BinanceOrderInfo{Code: code, Msg: msg}, binance.NewError(code, msg)
+}
+
+// checkFilters 按交易规则校验数量，只减仓单不校验最小名义价值，调用方持有锁
+func (s *sSimExchange) checkFilters(symbol string, orderType string, qty decimal.Decimal, price decimal.Decimal, reduceOnly bool) *entity.BinanceOrderInfo {
+	for _, filter := range s.filters[symbol] {
+		switch filter.FilterType {
+		case "LOT_SIZE", "MARKET_LOT_SIZE":
+			if ("MARKET" == orderType) != ("MARKET_LOT_SIZE" == filter.FilterType) {
+				continue
+			}
+
+			minQty, _ := decimal.NewFromString(filter.MinQty)
+			maxQty, _ := decimal.NewFromString(filter.MaxQty)
+			stepSize, _ := decimal.NewFromString(filter.StepSize)
+			if qty.LessThan(minQty) {
+				return &entity.BinanceOrderInfo{Code: -4004, Msg: "Quantity less than min quantity."}
+			}
+			if maxQty.IsPositive() && qty.GreaterThan(maxQty) {
+				return &entity.BinanceOrderInfo{Code: -4005, Msg: "Quantity greater than max quantity."}
+			}
+			if stepSize.IsPositive() && !qty.Mod(stepSize).IsZero() {
+				return &entity.BinanceOrderInfo{Code: -1111, Msg: "Precision is over the maximum defined for this asset."}
+			}
+		case "MIN_NOTIONAL":
+			notional, _ := decimal.NewFromString(filter.Notional)
+			if !reduceOnly && qty.Mul(price).LessThan(notional) {
+				return &entity.BinanceOrderInfo{Code: -4164, Msg: "Order's notional must be no smaller than " + filter.Notional}
+			}
+		}
+	}
+
+	return nil
+}
+
+// fill 更新仓位、开仓均价和已实现盈亏，并收取手续费，调用方持有锁
+func (s *sSimExchange) fill(apiKey string, key string, delta decimal.Decimal, price decimal.Decimal) {
+	if _, ok := s.positions[apiKey]; !ok {
+		s.positions[apiKey] = make(map[string]decimal.Decimal, 0)
+	}
+	if _, ok := s.entries[apiKey]; !ok {
+		s.entries[apiKey] = make(map[string]decimal.Decimal, 0)
+	}
+
+	// 空仓以正数记录，换算为带方向的数量计算
+	sign := decimal.NewFromInt(1)
+	if strings.HasSuffix(key, "&SHORT") {
+		sign = sign.Neg()
+	}
+
+	var (
+		current     = s.positions[apiKey][key].Mul(sign)
+		signedDelta = delta.Mul(sign)
+		entry       = s.entries[apiKey][key]
+		currentAbs  = current.Abs()
+		deltaAbs    = signedDelta.Abs()
+	)
+	if current.IsZero() || current.Sign() == signedDelta.Sign() {
+		// 开仓或加仓，按数量加权均价
+		entry = entry.Mul(currentAbs).Add(price.Mul(deltaAbs)).Div(currentAbs.Add(deltaAbs))
+	} else {
+		// 减仓按开仓均价结算，反手的部分以成交价开仓
+		closed := decimal.Min(currentAbs, deltaAbs)
+		s.realized[apiKey] = s.realized[apiKey].Add(closed.Mul(price.Sub(entry)).Mul(decimal.NewFromInt(int64(current.Sign()))))
+		if deltaAbs.GreaterThan(currentAbs) {
+			entry = price
+		} else if deltaAbs.Equal(currentAbs) {
+			entry = decimal.Zero
+		}
+	}
+
+	s.entries[apiKey][key] = entry
+	s.positions[apiKey][key] = s.positions[apiKey][key].Add(delta)
+	s.fees[apiKey] = s.fees[apiKey].Add(delta.Abs().Mul(price).Mul(s.feeRate))
+	s.fills[apiKey]++
+	s.orderId++
+}
+
+// GetBinancePositionInfo 账户仓位，BOTH正负表示方向，SHORT为负数
+func (s *sSimExchange) GetBinancePositionInfo(apiK, apiS string) []*entity.BinancePosition {
+	s.refreshPositionPrices(apiK)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]*entity.BinancePosition, 0)
+	for k, v := range s.positions[apiK] {
+		tmp := strings.Split(k, "&")
+		amount := v
+		if "SHORT" == tmp[1] {
+			amount = v.Neg()
+		}
+
+		res = append(res, &entity.BinancePosition{
+			Symbol:           tmp[0],
+			PositionSide:     tmp[1],
+			PositionAmt:      amount.String(),
+			EntryPrice:       s.entries[apiK][k].String(),
+			UnrealizedProfit: s.unrealized(apiK, k).String(),
+			Leverage:         strconv.Itoa(1),
+		})
+	}
+
+	return res
+}
+
+// CreateListenKey 模拟交易所无推送
+func (s *sSimExchange) CreateListenKey(apiKey string) (string, error) {
+	return "", gerror.New("simulated exchange has no user data stream")
+}
+
+// RenewListenKey 模拟交易所无推送
+func (s *sSimExchange) RenewListenKey(apiKey string) error {
+	return gerror.New("simulated exchange has no user data stream")
+}
+
+// ConnectWebSocket 模拟交易所无推送
+func (s *sSimExchange) ConnectWebSocket(listenKey string) (*websocket.Conn, error) {
+	return nil, gerror.New("simulated exchange has no user data stream")
+}
+
+// GetRateLimits 模拟交易所不限频
+func (s *sSimExchange) GetRateLimits() []*entity.BinanceRateLimit {
+	return make([]*entity.BinanceRateLimit, 0)
+}
@@ -23,3 +23,15 @@ func (s *sUser) GetTradersApiIsOk(ctx context.Context) (users []*entity.User, er
 	err = g.Model("user").Ctx(ctx).Where("api_status=?", 1).Scan(&users)
 	return users, err
 }
+
+// GetTradersIsOk 获取可用的交易员
+func (s *sUser) GetTradersIsOk(ctx context.Context) (traders []*entity.Trader, err error) {
+	err = g.Model("trader").Ctx(ctx).Where("status=?", 1).Scan(&traders)
+	return traders, err
+}
+
+// GetUserTradersIsOk 获取跟单中的用户和交易员关系
+func (s *sUser) GetUserTradersIsOk(ctx context.Context) (userTraders []*entity.UserTrader, err error) {
+	err = g.Model("user_trader").Ctx(ctx).Where("status=?", 1).Scan(&userTraders)
+	return userTraders, err
+}
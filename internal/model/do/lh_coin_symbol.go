@@ -25,4 +25,5 @@ type LhCoinSymbol struct {
 	VolumePlace       interface{} //
 	SizeMultiplier    interface{} //
 	QuantoMultiplier  interface{} //
+	Status            interface{} // 交易所状态，同步写入，空为手动维护
 }
@@ -25,4 +25,5 @@ type User struct {
 	Plat       interface{} //
 	Dai        interface{} //
 	Ip         interface{} //
+	Paper      interface{} // 模拟盘：1
 }
@@ -0,0 +1,50 @@
+package entity
+
+// BacktestReq 回测参数
+type BacktestReq struct {
+	Journal           string          // 信号日志文件
+	Prices            string          // 标记价格文件，每行一条json，为空时使用推送中的成交价
+	TraderMoney       float64         // 交易员保证金
+	FeeRate           float64         // 吃单手续费率
+	QuantityPrecision int             // 币安默认数量精度
+	QuantoMultiplier  float64         // gate默认每张币的数量
+	Symbols           []*LhCoinSymbol // 交易对精度，Symbol为币安格式，未列出的使用默认值
+	Users             []*BacktestUser // 模拟用户
+}
+
+// BacktestUser 回测模拟用户
+type BacktestUser struct {
+	Plat  string  `json:"plat"`  // binance、gate
+	Money float64 `json:"money"` // 保证金
+	Num   float64 `json:"num"`   // 跟单系数
+}
+
+// BacktestPrice 标记价格
+type BacktestPrice struct {
+	Time   int64   `json:"time"`   // 毫秒时间戳
+	Symbol string  `json:"symbol"` // 币安交易对
+	Price  float64 `json:"price"`  // 价格
+}
+
+// BacktestResult 回测结果
+type BacktestResult struct {
+	Events  int                   `json:"events"`  // 重放的推送数量
+	Signals int                   `json:"signals"` // 交易员信号数量
+	Users   []*BacktestUserResult `json:"users"`   // 每个用户的结果
+}
+
+// BacktestUserResult 回测用户结果，金额单位USDT
+type BacktestUserResult struct {
+	Plat             string             `json:"plat"`
+	Money            float64            `json:"money"`
+	Num              float64            `json:"num"`
+	Signals          int                `json:"signals"`          // 收到的信号
+	Fills            int                `json:"fills"`            // 成交笔数
+	Skipped          int                `json:"skipped"`          // 应下单但数量取整为0或下单失败
+	Fees             float64            `json:"fees"`             // 手续费
+	RoundingLoss     float64            `json:"roundingLoss"`     // 每笔信号实际成交与理想数量之差的价值之和
+	TrackingError    float64            `json:"trackingError"`    // 每笔信号后实际仓位与理想仓位价值之差的均方根
+	MaxTrackingError float64            `json:"maxTrackingError"` // 最大跟踪误差
+	TrackingErrorPct float64            `json:"trackingErrorPct"` // 跟踪误差占保证金比例
+	Positions        map[string]float64 `json:"positions"`        // 结束时仓位
+}
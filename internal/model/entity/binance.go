@@ -22,6 +22,7 @@ type BinanceOrderInfo struct {
 // Asset 代表单个资产的保证金信息
 type Asset struct {
 	TotalMarginBalance string `json:"totalMarginBalance"` // 资产余额
+	AvailableBalance   string `json:"availableBalance"`   // 可用保证金
 }
 
 type LatestPrice struct {
@@ -48,15 +49,34 @@ type BinanceExchangeInfoResp struct {
 
 // BinanceSymbolInfo 结构体表示单个交易对的信息
 type BinanceSymbolInfo struct {
-	Symbol            string `json:"symbol"`
-	Pair              string `json:"pair"`
-	ContractType      string `json:"contractType"`
-	Status            string `json:"status"`
-	BaseAsset         string `json:"baseAsset"`
-	QuoteAsset        string `json:"quoteAsset"`
-	MarginAsset       string `json:"marginAsset"`
-	PricePrecision    int    `json:"pricePrecision"`
-	QuantityPrecision int    `json:"quantityPrecision"`
+	Symbol            string                 `json:"symbol"`
+	Pair              string                 `json:"pair"`
+	ContractType      string                 `json:"contractType"`
+	Status            string                 `json:"status"`
+	BaseAsset         string                 `json:"baseAsset"`
+	QuoteAsset        string                 `json:"quoteAsset"`
+	MarginAsset       string                 `json:"marginAsset"`
+	PricePrecision    int                    `json:"pricePrecision"`
+	QuantityPrecision int                    `json:"quantityPrecision"`
+	Filters           []*BinanceSymbolFilter `json:"filters"`
+}
+
+// BinanceSymbolFilter 交易规则，不同filterType使用不同字段
+type BinanceSymbolFilter struct {
+	FilterType string `json:"filterType"` // LOT_SIZE、MARKET_LOT_SIZE、MIN_NOTIONAL、PRICE_FILTER等
+	MinQty     string `json:"minQty"`     // 最小数量
+	MaxQty     string `json:"maxQty"`     // 最大数量
+	StepSize   string `json:"stepSize"`   // 数量步长
+	Notional   string `json:"notional"`   // 最小名义价值
+	MinPrice   string `json:"minPrice"`   // 最低价格
+	MaxPrice   string `json:"maxPrice"`   // 最高价格
+	TickSize   string `json:"tickSize"`   // 价格步长
+}
+
+// MarkPrice 标记价格
+type MarkPrice struct {
+	Symbol    string `json:"symbol"`
+	MarkPrice string `json:"markPrice"`
 }
 
 // BinancePosition 代表单个头寸（持仓）信息
@@ -83,6 +103,12 @@ type BinanceResponse struct {
 	Positions []*BinancePosition `json:"positions"` // 仓位信息
 }
 
+// StreamEvent 用户数据流推送的公共字段
+type StreamEvent struct {
+	EventType string `json:"e"` // 事件类型
+	EventTime int64  `json:"E"` // 事件时间
+}
+
 // AccountUpdateEvent represents the `ACCOUNT_UPDATE` event pushed via WebSocket
 type AccountUpdateEvent struct {
 	EventType string `json:"e"`
@@ -165,3 +191,15 @@ type Order struct {
 	PriceMatchingMode     string `json:"pm"`  // 价格匹配模式
 	GTDTime               int64  `json:"gtd"` // TIF为GTD的订单自动取消时间
 }
+
+// BinanceRateLimit 币安限频用量，IP权重按接口组计，下单数按API Key计
+type BinanceRateLimit struct {
+	Key         string `json:"key"`         // 接口组或API Key后4位
+	Type        string `json:"type"`        // weight或order
+	Interval    string `json:"interval"`    // 窗口，如1m、10s
+	Used        int    `json:"used"`        // 当前窗口已用
+	Limit       int    `json:"limit"`       // 窗口上限
+	Budget      int    `json:"budget"`      // 本地允许使用的上限，超过后排队等待
+	ResetAt     int64  `json:"resetAt"`     // 窗口重置时间，毫秒
+	BannedUntil int64  `json:"bannedUntil"` // 429、418后的禁止请求截止时间，毫秒
+}
@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"fmt"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+// BinanceError 币安接口返回的错误码，Class为consts中的错误分类
+type BinanceError struct {
+	Code  int64  `json:"code"`
+	Msg   string `json:"msg"`
+	Class string `json:"class"`
+}
+
+func (e *BinanceError) Error() string {
+	return fmt.Sprintf("binance error %s %d: %s", e.Class, e.Code, e.Msg)
+}
+
+// OrderFailure 用户下单失败统计，每次被拒或请求失败计一次，含重试
+type OrderFailure struct {
+	UserId      uint           `json:"userId"`
+	Total       int            `json:"total"`       // 失败次数
+	Consecutive int            `json:"consecutive"` // 连续失败次数，下单成功后清零
+	Classes     map[string]int `json:"classes"`     // 错误分类 => 次数
+	LastClass   string         `json:"lastClass"`
+	LastError   string         `json:"lastError"`
+	LastTime    *gtime.Time    `json:"lastTime"`
+}
@@ -0,0 +1,12 @@
+package entity
+
+// OrderFill 下单后确认的成交结果，binance数量为币的数量，gate为张数
+type OrderFill struct {
+	Plat        string  `json:"plat"`
+	OrderId     string  `json:"orderId"`
+	Symbol      string  `json:"symbol"`
+	Status      string  `json:"status"`      // 交易所订单状态
+	Final       bool    `json:"final"`       // 订单已结束，不会再有成交
+	ExecutedQty float64 `json:"executedQty"` // 已成交数量，正数
+	AvgPrice    float64 `json:"avgPrice"`    // 成交均价
+}
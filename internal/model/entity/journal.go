@@ -0,0 +1,22 @@
+package entity
+
+import "encoding/json"
+
+// JournalRecord 信号日志，每行一条json
+type JournalRecord struct {
+	Time     int64           `json:"time"`             // 毫秒时间戳
+	Kind     string          `json:"kind"`             // event原始推送，signal推送给用户的信号，webhook信号
+	TraderId uint            `json:"traderId"`         // 交易员
+	Event    json.RawMessage `json:"event,omitempty"`  // 原始推送
+	Signal   *OrderInfo      `json:"signal,omitempty"` // 信号
+}
+
+// ReplayResult 日志重放结果
+type ReplayResult struct {
+	Events          int                `json:"events"`          // 重放的推送数量
+	ExpectedSignals int                `json:"expectedSignals"` // 日志中的信号数量
+	ProducedSignals int                `json:"producedSignals"` // 重放产生的信号数量
+	Mismatches      []string           `json:"mismatches"`      // 信号不一致
+	Positions       map[string]float64 `json:"positions"`       // 模拟用户交易所仓位
+	Ledger          map[string]float64 `json:"ledger"`          // 模拟用户系统仓位
+}
@@ -20,4 +20,5 @@ type LhCoinSymbol struct {
 	VolumePlace       int     `json:"volumePlace"       ` //
 	SizeMultiplier    float64 `json:"sizeMultiplier"    ` //
 	QuantoMultiplier  float64 `json:"quantoMultiplier"  ` //
+	Status            string  `json:"status"            ` // 交易所状态，同步写入，空为手动维护
 }
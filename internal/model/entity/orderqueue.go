@@ -3,6 +3,7 @@ package entity
 type DoValue struct {
 	UserId int
 	Value  interface{}
+	Replay bool // 重启后从队列记录重放，可能已经下单
 }
 
 type OrderInfo struct {
@@ -13,4 +14,7 @@ type OrderInfo struct {
 	Status       string
 	Side         string
 	PositionSide string
+	TraderId     uint
+	OrderId      int64 // 交易员来源订单id，校正信号为0
+	TradeId      int64 // 交易员来源成交id
 }
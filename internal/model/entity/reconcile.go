@@ -0,0 +1,15 @@
+package entity
+
+import "github.com/gogf/gf/v2/os/gtime"
+
+// PositionMismatch 系统仓位与交易所仓位不一致
+type PositionMismatch struct {
+	TraderId      uint        `json:"traderId"`
+	UserId        uint        `json:"userId"`
+	Symbol        string      `json:"symbol"`
+	PositionSide  string      `json:"positionSide"`
+	SystemAmount  float64     `json:"systemAmount"`
+	AccountAmount float64     `json:"accountAmount"`
+	Corrected     bool        `json:"corrected"`
+	CheckedAt     *gtime.Time `json:"checkedAt"`
+}
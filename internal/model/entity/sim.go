@@ -0,0 +1,12 @@
+package entity
+
+// SimAccount 模拟账户资金，单位USDT
+type SimAccount struct {
+	ApiKey        string  `json:"apiKey"`
+	Balance       float64 `json:"balance"`       // 入金
+	Realized      float64 `json:"realized"`      // 已实现盈亏
+	Unrealized    float64 `json:"unrealized"`    // 未实现盈亏
+	Fees          float64 `json:"fees"`          // 累计手续费
+	MarginBalance float64 `json:"marginBalance"` // 保证金余额
+	Fills         int     `json:"fills"`         // 成交笔数
+}
@@ -23,4 +23,5 @@ type User struct {
 	Plat       string      `json:"plat"       ` //
 	Dai        int         `json:"dai"        ` //
 	Ip         string      `json:"ip"         ` //
+	Paper      int         `json:"paper"      ` // 模拟盘：1
 }
@@ -0,0 +1,11 @@
+package entity
+
+// WebhookAlert TradingView风格的webhook信号
+type WebhookAlert struct {
+	TraderId uint    `json:"traderId"` // 信号来源交易员
+	Token    string  `json:"token"`    // 签名token，也可放在请求头X-Webhook-Token
+	Symbol   string  `json:"symbol"`   // 交易对，例如BTCUSDT
+	Side     string  `json:"side"`     // long，short
+	Action   string  `json:"action"`   // open，close
+	Size     float64 `json:"size"`     // 开仓为虚拟保证金百分比，平仓为仓位百分比，默认全平
+}
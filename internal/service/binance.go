@@ -7,6 +7,8 @@ package service
 
 import (
 	"plat_order/internal/model/entity"
+
+	"github.com/gorilla/websocket"
 )
 
 type (
@@ -15,23 +17,35 @@ type (
 		GetBinancePositionSide(apiK, apiS string) string
 		// GetLatestPrice 获取价格
 		GetLatestPrice(symbol string) string
+		// GetMarkPrice 获取合约标记价格
+		GetMarkPrice(symbol string) string
 		// GetWalletInfo 获取钱包信息
 		GetWalletInfo(apiK, apiS string) []*entity.WalletInfo
 		// GetBinanceInfo 获取账户信息
 		GetBinanceInfo(apiK, apiS string) string
+		// GetBinanceAvailableBalance 获取可用于开仓的保证金
+		GetBinanceAvailableBalance(apiK, apiS string) string
 		RequestBinancePositionSide(positionSide string, apiKey string, secretKey string) (error, string, bool)
 		// GetBinanceFuturesPairs 获取 Binance U 本位合约交易对信息
 		GetBinanceFuturesPairs() ([]*entity.BinanceSymbolInfo, error)
-		// RequestBinanceOrder 请求下单
-		RequestBinanceOrder(symbol string, side string, orderType string, positionSide string, quantity string, apiKey string, secretKey string, reduceOnly bool) (*entity.BinanceOrder, *entity.BinanceOrderInfo, error)
+		// RequestBinanceOrder 请求下单，clientOrderId非空时作为newClientOrderId，被拒时返回按错误码归类的*entity.BinanceError
+		RequestBinanceOrder(symbol string, side string, orderType string, positionSide string, quantity string, apiKey string, secretKey string, reduceOnly bool, clientOrderId string) (*entity.BinanceOrder, *entity.BinanceOrderInfo, error)
 		// GetBinancePositionInfo 获取账户信息
 		GetBinancePositionInfo(apiK, apiS string) []*entity.BinancePosition
 		// CreateListenKey creates a new ListenKey for user data stream
-		CreateListenKey(apiKey string) error
+		CreateListenKey(apiKey string) (string, error)
 		// RenewListenKey renews the ListenKey for user data stream
 		RenewListenKey(apiKey string) error
-		// ConnectWebSocket safely connects to the WebSocket and updates conn
-		ConnectWebSocket() error
+		// ConnectWebSocket connects to the user data stream of the listen key
+		ConnectWebSocket(listenKey string) (*websocket.Conn, error)
+		// GetRateLimits 当前请求权重和下单数用量
+		GetRateLimits() []*entity.BinanceRateLimit
+		// QueryBinanceOrder 查询订单
+		QueryBinanceOrder(symbol string, orderId int64, apiK, apiS string) (*entity.BinanceOrder, error)
+		// QueryBinanceOrderByClientId 按clientOrderId查询订单，订单不存在时返回NOT_FOUND分类的错误
+		QueryBinanceOrderByClientId(symbol string, clientOrderId string, apiK, apiS string) (*entity.BinanceOrder, error)
+		// BinanceOrderFill 下单结果转为成交结果，订单未结束时查询订单状态，查询后仍未结束返回已成交部分和错误
+		BinanceOrderFill(order *entity.BinanceOrder, apiK, apiS string) (*entity.OrderFill, error)
 	}
 )
 
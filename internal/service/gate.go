@@ -5,20 +5,33 @@
 
 package service
 
-import "github.com/gateio/gateapi-go/v6"
+import (
+	"plat_order/internal/model/entity"
+
+	"github.com/gateio/gateapi-go/v6"
+)
 
 type (
 	IGate interface {
+		// GetOrderGate 查询订单
+		GetOrderGate(apiK, apiS string, orderId int64) (gateapi.FuturesOrder, error)
+		// GetOrderGateByText 按text查询最近的订单，未找到时返回的订单id为0。
+		// 按text只能查询挂单中的订单，已结束的订单从最近订单中查找
+		GetOrderGateByText(apiK, apiS, contract, text string) (gateapi.FuturesOrder, error)
+		// GateOrderFill 下单结果转为成交结果，订单未结束时查询订单状态，查询后仍未结束返回已成交部分和错误
+		GateOrderFill(apiK, apiS string, order gateapi.FuturesOrder) (*entity.OrderFill, error)
 		// GetGateContract 获取合约账号信息
 		GetGateContract(apiK, apiS string) (gateapi.FuturesAccount, error)
 		// GetListPositions 获取合约账号信息
 		GetListPositions(apiK, apiS string) ([]gateapi.Position, error)
-		// PlaceOrderGate places an order on the Gate.io API with dynamic parameters
-		PlaceOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, autoSize string) (gateapi.FuturesOrder, error)
-		// PlaceBothOrderGate places an order on the Gate.io API with dynamic parameters
-		PlaceBothOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, close bool) (gateapi.FuturesOrder, error)
+		// PlaceOrderGate places an order on the Gate.io API with dynamic parameters, text is the user defined order id prefixed with t-
+		PlaceOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, autoSize string, text string) (gateapi.FuturesOrder, error)
+		// PlaceBothOrderGate places an order on the Gate.io API with dynamic parameters, text is the user defined order id prefixed with t-
+		PlaceBothOrderGate(apiK, apiS, contract string, size int64, reduceOnly bool, close bool, text string) (gateapi.FuturesOrder, error)
 		// SetDual setDual
 		SetDual(apiK, apiS string, dual bool) (bool, error)
+		// ListGateContracts 获取usdt永续合约列表
+		ListGateContracts() ([]gateapi.Contract, error)
 	}
 )
 
@@ -12,32 +12,49 @@ import (
 
 type (
 	IListenAndOrder interface {
+		// Backtest 用模拟交易所回放交易员推送和标记价格，用户按OrderAtPlat同样的逻辑下单，统计跟踪误差、手续费和取整损失
+		Backtest(ctx context.Context, req *entity.BacktestReq) (*entity.BacktestResult, error)
+		// InitSignalDedup 读取去重配置，恢复持久化记录并定时写入
+		InitSignalDedup(ctx context.Context)
+		// InitJournal 打开信号日志，配置journal.file为空时不记录
+		InitJournal(ctx context.Context) (err error)
+		// Replay 用模拟交易所重放日志，一个模拟用户跟单全部交易员，对比重放产生的信号和日志中的信号
+		Replay(ctx context.Context, path string, traderMoney float64, userMoney float64, quantityPrecision int) (*entity.ReplayResult, error)
+		// LoadOrderMap 启动时从数据库恢复用户仓位
+		LoadOrderMap(ctx context.Context) (err error)
 		// SetSymbol 更新symbol
 		SetSymbol(ctx context.Context) (err error)
 		// PullAndSetBaseMoneyNewGuiTuAndUser 拉取binance保证金数据
 		PullAndSetBaseMoneyNewGuiTuAndUser(ctx context.Context)
 		// PullAndSetTraderUserPositionSide 获取并更新持仓方向
 		PullAndSetTraderUserPositionSide(ctx context.Context) (err error)
+		// SetTraders 初始化交易员，新增的开启监听，删除的停止监听，api变更的重新连接
+		SetTraders(ctx context.Context) (err error)
+		// RotateTraderKey 更换交易员api，先写数据库再更换内存中的api，与SetTraders互斥，
+		// 避免定时加载读到旧数据库记录后把api换回去
+		RotateTraderKey(ctx context.Context, traderId uint64, apiKey, apiSecret string) error
 		// SetUser 初始化用户
 		SetUser(ctx context.Context) (err error)
 		// HandleBothPositions 处理平仓
 		HandleBothPositions(ctx context.Context)
 		// OrderAtPlat 在平台下单
 		OrderAtPlat(ctx context.Context, doValue *entity.DoValue)
-		// Run 监控仓位 pulls binance data and orders
-		Run(ctx context.Context)
+		// Run 监控交易员仓位 pulls binance data and orders
+		Run(ctx context.Context, traderId uint)
 		// SetPositionSide set position side
 		SetPositionSide(apiKey, apiSecret string) (uint64, string)
 		// GetSystemUserNum get user num
 		GetSystemUserNum(ctx context.Context) map[string]float64
 		// CreateUser set user num
-		CreateUser(ctx context.Context, address, apiKey, apiSecret, plat string, needInit uint64, num float64) error
+		CreateUser(ctx context.Context, address, apiKey, apiSecret, plat string, needInit uint64, num float64, paper uint64) error
 		// SetSystemUserNum set user num
 		SetSystemUserNum(ctx context.Context, apiKey string, num float64) error
 		// SetApiStatus set user api status
 		SetApiStatus(ctx context.Context, apiKey string, status uint64, init uint64) uint64
 		// SetUseNewSystem set user num
 		SetUseNewSystem(ctx context.Context, apiKey string, useNewSystem uint64) error
+		// SetUserTrader set user trader
+		SetUserTrader(ctx context.Context, apiKey string, traderId uint64, num float64, status uint64) error
 		// GetSystemUserPositions get user positions
 		GetSystemUserPositions(ctx context.Context, apiKey string) map[string]float64
 		// GetBinanceUserPositions get binance user positions
@@ -45,7 +62,29 @@ type (
 		// CloseBinanceUserPositions close binance user positions
 		CloseBinanceUserPositions(ctx context.Context) uint64
 		// SetSystemUserPosition set user positions
-		SetSystemUserPosition(ctx context.Context, system uint64, allCloseGate uint64, apiKey string, symbol string, side string, positionSide string, num float64) uint64
+		SetSystemUserPosition(ctx context.Context, system uint64, allCloseGate uint64, apiKey string, symbol string, side string, positionSide string, num float64, traderId uint64) uint64
+		// InitPaper 模拟盘以币安合约标记价格成交，与跟单数量计算一致，配置paper.file时恢复并定时保存模拟账户
+		InitPaper(ctx context.Context)
+		// GetPaperAccount 模拟盘用户的资金和盈亏
+		GetPaperAccount(ctx context.Context, apiKey string) (*entity.SimAccount, error)
+		// SetSymbolFilters 拉取币安交易规则
+		SetSymbolFilters(ctx context.Context) (err error)
+		// ReconcileTraders 定时对比交易员接口仓位和系统仓位，配置reconcile.push开启时推送校正信息
+		ReconcileTraders(ctx context.Context)
+		// GetTraderMismatches 最近一次对比的交易员仓位差异
+		GetTraderMismatches(ctx context.Context) []*entity.PositionMismatch
+		// ReconcileUser 对比用户交易所仓位和系统仓位，report只报告，map校正系统仓位，order下单补齐交易所仓位
+		ReconcileUser(ctx context.Context, apiKey string, mode string) ([]*entity.PositionMismatch, error)
+		// ReconcileUsers 定时对比用户交易所仓位，模式为配置reconcile.userMode，默认只报告
+		ReconcileUsers(ctx context.Context)
+		// GetUserMismatches 最近一次对比的用户仓位差异
+		GetUserMismatches(ctx context.Context) []*entity.PositionMismatch
+		// GetOrderFailures 用户下单失败统计
+		GetOrderFailures(ctx context.Context) []*entity.OrderFailure
+		// SyncSymbols 从币安exchangeInfo和gate合约列表同步lh_coin_symbol，配置symbolSync.enable关闭时只更新交易规则
+		SyncSymbols(ctx context.Context) (err error)
+		// HandleWebhook 处理webhook信号，开仓按虚拟保证金百分比折算数量，平仓按仓位百分比
+		HandleWebhook(ctx context.Context, alert *entity.WebhookAlert) error
 	}
 )
 
@@ -18,6 +18,12 @@ type (
 		UnBindUserAndQueue(userId int) (err error)
 		// PushAllQueue 向所有订单队列推送消息
 		PushAllQueue(msg interface{})
+		// PushQueue 向用户订单队列推送消息
+		PushQueue(userId int, msg interface{})
+		// PushQueues 向多个用户订单队列推送同一消息，一次落库后按用户入队
+		PushQueues(userIds []int, msg interface{})
+		// CleanQueue 删除超过保留时间的已执行和过期信号
+		CleanQueue(ctx context.Context)
 		// ListenQueue 监听队列
 		ListenQueue(ctx context.Context, userId int, do func(context.Context, *entity.DoValue))
 	}
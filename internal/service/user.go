@@ -13,6 +13,10 @@ import (
 type (
 	IUser interface {
 		GetTradersApiIsOk(ctx context.Context) (users []*entity.User, err error)
+		// GetTradersIsOk 获取可用的交易员
+		GetTradersIsOk(ctx context.Context) (traders []*entity.Trader, err error)
+		// GetUserTradersIsOk 获取跟单中的用户和交易员关系
+		GetUserTradersIsOk(ctx context.Context) (userTraders []*entity.UserTrader, err error)
 	}
 )
 
@@ -0,0 +1,3 @@
+-- 交易对同步写入交易所状态，空为手动维护的交易对，symbolTradable视为可交易
+ALTER TABLE `lh_coin_symbol`
+    ADD COLUMN `status` varchar(16) NOT NULL DEFAULT '' COMMENT '交易所状态，同步写入，空为手动维护';
@@ -0,0 +1,15 @@
+-- 用户订单队列落库，同一信号推送给多个用户时一条语句写入，按(user_id,batch)确认，绑定队列时按id重放待执行的信号，
+-- CleanQueue定时删除超过保留时间的已执行和过期信号
+CREATE TABLE IF NOT EXISTS `order_queue` (
+    `id`         bigint unsigned  NOT NULL AUTO_INCREMENT,
+    `user_id`    int unsigned     NOT NULL COMMENT '用户id',
+    `batch`      char(32)         NOT NULL DEFAULT '' COMMENT '落库批次，同一信号推送给多个用户时相同',
+    `msg`        text             NOT NULL COMMENT '信号内容json',
+    `status`     tinyint unsigned NOT NULL DEFAULT 0 COMMENT '状态：待执行0，已执行1，过期2',
+    `created_at` datetime         NULL DEFAULT NULL,
+    `updated_at` datetime         NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_user_status` (`user_id`, `status`, `id`),
+    KEY `idx_user_batch` (`user_id`, `batch`),
+    KEY `idx_status_updated` (`status`, `updated_at`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '用户订单队列';
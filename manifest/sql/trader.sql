@@ -0,0 +1,14 @@
+-- 交易员
+CREATE TABLE IF NOT EXISTS `trader` (
+    `id`         int unsigned    NOT NULL AUTO_INCREMENT COMMENT '交易员id',
+    `name`       varchar(64)     NOT NULL DEFAULT '' COMMENT '交易员名称',
+    `api_key`    varchar(128)    NOT NULL DEFAULT '' COMMENT '交易员币安apikey',
+    `api_secret` varchar(128)    NOT NULL DEFAULT '' COMMENT '交易员币安apisecret，webhook为签名token',
+    `source`     varchar(16)     NOT NULL DEFAULT 'binance' COMMENT '信号来源：binance，webhook',
+    `equity`     decimal(30, 10) NOT NULL DEFAULT 0 COMMENT 'webhook虚拟保证金',
+    `status`     tinyint unsigned NOT NULL DEFAULT 1 COMMENT '状态：可用1',
+    `created_at` datetime        NULL DEFAULT NULL,
+    `updated_at` datetime        NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_status` (`status`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '交易员';
@@ -0,0 +1,3 @@
+-- 模拟盘用户，userBinance、userGate按此字段把下单路由到模拟交易所
+ALTER TABLE `user`
+    ADD COLUMN `paper` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '模拟盘：1';
@@ -0,0 +1,14 @@
+-- 用户跟单仓位，setOrderMap按唯一索引(user_id,trader_id,symbol,position_side)写入或更新
+CREATE TABLE IF NOT EXISTS `user_position` (
+    `id`            int unsigned    NOT NULL AUTO_INCREMENT,
+    `user_id`       int unsigned    NOT NULL COMMENT '用户id',
+    `trader_id`     int unsigned    NOT NULL COMMENT '交易员id',
+    `symbol`        varchar(32)     NOT NULL COMMENT '交易对',
+    `position_side` varchar(8)      NOT NULL COMMENT '持仓方向',
+    `amount`        decimal(30, 10) NOT NULL DEFAULT 0 COMMENT '仓位数量，binance为币的数量，gate为张数',
+    `avg_price`     decimal(30, 10) NOT NULL DEFAULT 0 COMMENT '开仓均价，按实际成交计算',
+    `created_at`    datetime        NULL DEFAULT NULL,
+    `updated_at`    datetime        NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_trader_symbol_side` (`user_id`, `trader_id`, `symbol`, `position_side`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '用户跟单仓位';
@@ -0,0 +1,13 @@
+-- 用户跟单交易员，一个用户对一个交易员只有一条记录
+CREATE TABLE IF NOT EXISTS `user_trader` (
+    `id`         int unsigned     NOT NULL AUTO_INCREMENT,
+    `user_id`    int unsigned     NOT NULL COMMENT '用户id',
+    `trader_id`  int unsigned     NOT NULL COMMENT '交易员id',
+    `num`        decimal(20, 10)  NOT NULL DEFAULT 0 COMMENT '跟单系数',
+    `status`     tinyint unsigned NOT NULL DEFAULT 1 COMMENT '状态：跟单1',
+    `created_at` datetime         NULL DEFAULT NULL,
+    `updated_at` datetime         NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_trader` (`user_id`, `trader_id`),
+    KEY `idx_trader_status` (`trader_id`, `status`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '用户跟单交易员';
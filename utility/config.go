@@ -0,0 +1,26 @@
+package utility
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// ConfigString 读取配置，未配置或没有配置文件时返回默认值，回放等命令可不带配置运行
+func ConfigString(ctx context.Context, key string, def string) string {
+	v, err := g.Cfg().Get(ctx, key, def)
+	if nil != err || nil == v || v.IsEmpty() {
+		return def
+	}
+
+	return v.String()
+}
+
+// ConfigFloat 读取浮点配置，未配置或没有配置文件时返回默认值
+func ConfigFloat(ctx context.Context, key string, def float64) float64 {
+	v, err := g.Cfg().Get(ctx, key, def)
+	if nil != err || nil == v || v.IsEmpty() {
+		return def
+	}
+
+	return v.Float64()
+}
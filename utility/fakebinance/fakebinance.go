@@ -0,0 +1,560 @@
+// Package fakebinance 本地币安合约模拟服务，用于无网络环境下的集成测试。
+//
+// 用法：
+//
+//	fake := fakebinance.New()
+//	defer fake.Close()
+//	fake.AddAccount("traderKey", "traderSecret", 10000)
+//	fake.SetPrice("BTCUSDT", 50000)
+//	service.RegisterBinance(binance.NewWithBaseURL(fake.URL(), fake.URL(), fake.WsURL()))
+//
+// 交易员账户下单后，服务向该账户的用户数据流推送ORDER_TRADE_UPDATE和ACCOUNT_UPDATE，
+// 跟单用户的下单按同一撮合逻辑成交，可通过Positions查看结果。
+package fakebinance
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"plat_order/internal/logic/simexchange"
+	"plat_order/internal/model/entity"
+	"plat_order/internal/service"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server 本地币安合约模拟服务
+type Server struct {
+	server   *httptest.Server
+	sim      service.ISimExchange
+	upgrader websocket.Upgrader
+
+	mu         sync.Mutex
+	secrets    map[string]string                   // apiKey => apiSecret
+	listenKeys map[string]string                   // listenKey => apiKey
+	conns      map[string]map[*websocket.Conn]bool // apiKey => 用户数据流连接
+	tradeId    int64
+
+	minute      int64          // 当前计数的分钟
+	weight      int            // 本分钟请求数，每个请求按权重1计
+	orderCount  map[string]int // apiKey => 本分钟下单数
+	weightLimit int            // 超过后返回429，0不限制
+
+	failCode  int64  // 下单返回的错误码
+	failMsg   string // 下单返回的错误信息
+	failTimes int    // 剩余返回错误的下单次数
+
+	delay      time.Duration // 下单成交后延迟响应，模拟请求超时
+	delayTimes int           // 剩余延迟响应的下单次数
+}
+
+// New 启动模拟服务
+func New() *Server {
+	f := &Server{
+		sim:        simexchange.New(),
+		secrets:    make(map[string]string, 0),
+		listenKeys: make(map[string]string, 0),
+		conns:      make(map[string]map[*websocket.Conn]bool, 0),
+		orderCount: make(map[string]int, 0),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/time", f.handleTime)
+	mux.HandleFunc("/fapi/v1/time", f.handleTime)
+	mux.HandleFunc("/api/v3/ticker/price", f.handleTicker)
+	mux.HandleFunc("/fapi/v1/ticker/price", f.handleTicker)
+	mux.HandleFunc("/fapi/v1/premiumIndex", f.handleMarkPrice)
+	mux.HandleFunc("/fapi/v1/exchangeInfo", f.handleExchangeInfo)
+	mux.HandleFunc("/fapi/v1/listenKey", f.handleListenKey)
+	mux.HandleFunc("/ws/", f.handleWebSocket)
+	mux.HandleFunc("/fapi/v1/order", f.signed(f.handleOrder))
+	mux.HandleFunc("/fapi/v2/account", f.signed(f.handleAccount))
+	mux.HandleFunc("/fapi/v1/positionSide/dual", f.signed(f.handlePositionSide))
+	mux.HandleFunc("/sapi/v1/asset/wallet/balance", f.signed(f.handleWalletBalance))
+
+	f.server = httptest.NewServer(f.withUsage(mux))
+	return f
+}
+
+// URL 合约和现货接口地址
+func (f *Server) URL() string {
+	return f.server.URL
+}
+
+// WsURL 用户数据流地址
+func (f *Server) WsURL() string {
+	return "ws" + strings.TrimPrefix(f.server.URL, "http") + "/ws/"
+}
+
+// Close 关闭服务和全部连接
+func (f *Server) Close() {
+	f.mu.Lock()
+	for _, conns := range f.conns {
+		for conn := range conns {
+			_ = conn.Close()
+		}
+	}
+	f.conns = make(map[string]map[*websocket.Conn]bool, 0)
+	f.mu.Unlock()
+
+	f.server.Close()
+}
+
+// AddAccount 开通账户，签名按apiSecret校验
+func (f *Server) AddAccount(apiKey, apiSecret string, balance float64) {
+	f.mu.Lock()
+	f.secrets[apiKey] = apiSecret
+	f.mu.Unlock()
+
+	f.sim.SetBalance(apiKey, balance)
+}
+
+// SetPrice 更新最新价，市价单按最新价成交
+func (f *Server) SetPrice(symbol string, price float64) {
+	f.sim.SetPrice(symbol, price)
+}
+
+// SetFilters 设置交易对的交易规则，exchangeInfo返回并在下单时校验
+func (f *Server) SetFilters(symbol string, filters []*entity.BinanceSymbolFilter) {
+	f.sim.SetFilters(symbol, filters)
+}
+
+// Positions 账户仓位，key为symbol&positionSide
+func (f *Server) Positions(apiKey string) map[string]float64 {
+	return f.sim.GetPositions(apiKey)
+}
+
+// PlaceOrder 直接为账户下市价单，等同于调用/fapi/v1/order，成交后推送用户数据
+func (f *Server) PlaceOrder(apiKey, symbol, side, positionSide, quantity string, reduceOnly bool) (*entity.BinanceOrder, error) {
+	order, info, err := f.sim.RequestBinanceOrder(symbol, side, "MARKET", positionSide, quantity, apiKey, "", reduceOnly, "")
+	if nil != err {
+		return nil, fmt.Errorf("%d %s", info.Code, info.Msg)
+	}
+
+	f.pushOrder(apiKey, order)
+	return order, nil
+}
+
+// Push 向账户的用户数据流推送任意消息，如listenKeyExpired
+func (f *Server) Push(apiKey string, message interface{}) {
+	content, err := json.Marshal(message)
+	if nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for conn := range f.conns[apiKey] {
+		_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+		if err = conn.WriteMessage(websocket.TextMessage, content); nil != err {
+			_ = conn.Close()
+			delete(f.conns[apiKey], conn)
+		}
+	}
+}
+
+// pushOrder 推送市价单的NEW和成交，以及成交后的账户仓位
+func (f *Server) pushOrder(apiKey string, order *entity.BinanceOrder) {
+	f.mu.Lock()
+	f.tradeId++
+	tradeId := f.tradeId
+	f.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	newOrder := map[string]interface{}{
+		"s": order.Symbol, "c": order.ClientOrderId, "S": order.Side, "o": "MARKET", "f": "GTC",
+		"q": order.ExecutedQty, "p": "0", "ap": "0", "sp": "0", "x": "NEW", "X": "NEW",
+		"i": order.OrderId, "l": "0", "z": "0", "L": "0", "T": now, "t": 0,
+		"ot": "MARKET", "ps": order.PositionSide,
+	}
+	f.Push(apiKey, map[string]interface{}{"e": "ORDER_TRADE_UPDATE", "E": now, "T": now, "o": newOrder})
+
+	filled := make(map[string]interface{}, len(newOrder))
+	for k, v := range newOrder {
+		filled[k] = v
+	}
+	filled["x"] = "TRADE"
+	filled["X"] = "FILLED"
+	filled["ap"] = order.AvgPrice
+	filled["l"] = order.ExecutedQty
+	filled["z"] = order.ExecutedQty
+	filled["L"] = order.AvgPrice
+	filled["t"] = tradeId
+	f.Push(apiKey, map[string]interface{}{"e": "ORDER_TRADE_UPDATE", "E": now, "T": now, "o": filled})
+
+	positions := make([]map[string]interface{}, 0)
+	for _, v := range f.sim.GetBinancePositionInfo(apiKey, "") {
+		if v.Symbol != order.Symbol || v.PositionSide != order.PositionSide {
+			continue
+		}
+
+		positions = append(positions, map[string]interface{}{
+			"s": v.Symbol, "pa": v.PositionAmt, "ep": v.EntryPrice, "up": v.UnrealizedProfit,
+			"mt": "cross", "iw": "0", "ps": v.PositionSide,
+		})
+	}
+	f.Push(apiKey, map[string]interface{}{"e": "ACCOUNT_UPDATE", "E": now, "T": now, "a": map[string]interface{}{"m": "ORDER", "P": positions}})
+}
+
+// SetWeightLimit 每分钟请求数上限，超过后返回429，0不限制
+func (f *Server) SetWeightLimit(limit int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.weightLimit = limit
+}
+
+// FailOrders 之后times笔下单返回指定错误码，不成交
+func (f *Server) FailOrders(code int64, msg string, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failCode = code
+	f.failMsg = msg
+	f.failTimes = times
+}
+
+// DelayOrders 之后times笔下单照常成交，但延迟delay后才响应
+func (f *Server) DelayOrders(delay time.Duration, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = delay
+	f.delayTimes = times
+}
+
+// withUsage 按分钟计数，返回X-MBX-USED-WEIGHT-1M和X-MBX-ORDER-COUNT-1M
+func (f *Server) withUsage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-MBX-APIKEY")
+		minute := time.Now().Unix() / 60
+
+		f.mu.Lock()
+		if minute != f.minute {
+			f.minute = minute
+			f.weight = 0
+			f.orderCount = make(map[string]int, 0)
+		}
+		f.weight++
+		weight := f.weight
+		limited := 0 < f.weightLimit && weight > f.weightLimit
+		if http.MethodPost == r.Method && "/fapi/v1/order" == r.URL.Path && 0 < len(apiKey) {
+			f.orderCount[apiKey]++
+			w.Header().Set("X-MBX-ORDER-COUNT-1M", strconv.Itoa(f.orderCount[apiKey]))
+		}
+		f.mu.Unlock()
+
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", strconv.Itoa(weight))
+		if limited {
+			w.Header().Set("Retry-After", strconv.FormatInt(60-time.Now().Unix()%60, 10))
+			writeError(w, http.StatusTooManyRequests, -1003, "Too many requests.")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// signed 校验X-MBX-APIKEY和签名
+func (f *Server) signed(handler func(w http.ResponseWriter, r *http.Request, apiKey string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-MBX-APIKEY")
+		f.mu.Lock()
+		secret, ok := f.secrets[apiKey]
+		f.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action.")
+			return
+		}
+
+		// 签名参数为查询字符串拼接请求体
+		body, err := ioutil.ReadAll(r.Body)
+		if nil != err {
+			writeError(w, http.StatusBadRequest, -1102, "Malformed request.")
+			return
+		}
+
+		raw := r.URL.RawQuery
+		if 0 < len(body) {
+			if 0 < len(raw) {
+				raw += "&"
+			}
+			raw += string(body)
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err = r.ParseForm(); nil != err {
+			writeError(w, http.StatusBadRequest, -1102, "Malformed request.")
+			return
+		}
+
+		if !verifySignature(raw, secret) {
+			writeError(w, http.StatusBadRequest, -1022, "Signature for this request is not valid.")
+			return
+		}
+
+		handler(w, r, apiKey)
+	}
+}
+
+// verifySignature 签名可按原始顺序或按参数名排序计算
+func verifySignature(raw string, secret string) bool {
+	values, err := url.ParseQuery(raw)
+	if nil != err {
+		return false
+	}
+
+	signature := values.Get("signature")
+	if 0 >= len(signature) {
+		return false
+	}
+
+	parts := make([]string, 0)
+	for _, v := range strings.Split(raw, "&") {
+		if !strings.HasPrefix(v, "signature=") {
+			parts = append(parts, v)
+		}
+	}
+	values.Del("signature")
+
+	for _, payload := range []string{strings.Join(parts, "&"), values.Encode()} {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(payload))
+		if hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *Server) handleTime(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, map[string]int64{"serverTime": time.Now().UnixMilli()})
+}
+
+func (f *Server) handleTicker(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	price := f.sim.GetLatestPrice(symbol)
+	if 0 >= len(price) {
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+
+	writeJson(w, &entity.LatestPrice{Symbol: symbol, Price: price})
+}
+
+func (f *Server) handleMarkPrice(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	price := f.sim.GetMarkPrice(symbol)
+	if 0 >= len(price) {
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+
+	writeJson(w, &entity.MarkPrice{Symbol: symbol, MarkPrice: price})
+}
+
+func (f *Server) handleExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	symbols, _ := f.sim.GetBinanceFuturesPairs()
+	writeJson(w, &entity.BinanceExchangeInfoResp{Symbols: symbols})
+}
+
+func (f *Server) handleListenKey(w http.ResponseWriter, r *http.Request) {
+	apiKey := r.Header.Get("X-MBX-APIKEY")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.secrets[apiKey]; !ok {
+		writeError(w, http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action.")
+		return
+	}
+
+	// 同一账户只有一个有效listenKey，续期和删除直接返回
+	listenKey := "lk_" + apiKey
+	switch r.Method {
+	case http.MethodPost:
+		f.listenKeys[listenKey] = apiKey
+		writeJson(w, map[string]string{"listenKey": listenKey})
+	case http.MethodPut:
+		if _, ok := f.listenKeys[listenKey]; !ok {
+			writeError(w, http.StatusBadRequest, -1125, "This listenKey does not exist.")
+			return
+		}
+
+		writeJson(w, map[string]string{"listenKey": listenKey})
+	case http.MethodDelete:
+		delete(f.listenKeys, listenKey)
+		writeJson(w, map[string]string{})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	listenKey := strings.TrimPrefix(r.URL.Path, "/ws/")
+	f.mu.Lock()
+	apiKey, ok := f.listenKeys[listenKey]
+	f.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusBadRequest, -1125, "This listenKey does not exist.")
+		return
+	}
+
+	conn, err := f.upgrader.Upgrade(w, r, nil)
+	if nil != err {
+		return
+	}
+
+	f.mu.Lock()
+	if _, ok = f.conns[apiKey]; !ok {
+		f.conns[apiKey] = make(map[*websocket.Conn]bool, 0)
+	}
+	f.conns[apiKey][conn] = true
+	f.mu.Unlock()
+
+	// 读取以处理ping和关闭
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); nil != err {
+				f.mu.Lock()
+				delete(f.conns[apiKey], conn)
+				f.mu.Unlock()
+				_ = conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+func (f *Server) handleOrder(w http.ResponseWriter, r *http.Request, apiKey string) {
+	if http.MethodGet == r.Method {
+		var (
+			order *entity.BinanceOrder
+			err   error
+		)
+		if clientOrderId := r.Form.Get("origClientOrderId"); 0 < len(clientOrderId) {
+			order, err = f.sim.QueryBinanceOrderByClientId(r.Form.Get("symbol"), clientOrderId, apiKey, "")
+		} else {
+			orderId, _ := strconv.ParseInt(r.Form.Get("orderId"), 10, 64)
+			order, err = f.sim.QueryBinanceOrder(r.Form.Get("symbol"), orderId, apiKey, "")
+		}
+		if nil != err {
+			writeError(w, http.StatusBadRequest, -2013, "Order does not exist.")
+			return
+		}
+
+		writeOrder(w, order, false)
+		return
+	}
+
+	if http.MethodPost != r.Method {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if "MARKET" != r.Form.Get("type") {
+		writeError(w, http.StatusBadRequest, -1116, "Invalid orderType.")
+		return
+	}
+
+	f.mu.Lock()
+	if 0 < f.failTimes {
+		f.failTimes--
+		code, msg := f.failCode, f.failMsg
+		f.mu.Unlock()
+
+		writeError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+	f.mu.Unlock()
+
+	reduceOnly, _ := strconv.ParseBool(r.Form.Get("reduceOnly"))
+	order, info, err := f.sim.RequestBinanceOrder(
+		r.Form.Get("symbol"),
+		r.Form.Get("side"),
+		"MARKET",
+		r.Form.Get("positionSide"),
+		r.Form.Get("quantity"),
+		apiKey,
+		"",
+		reduceOnly,
+		r.Form.Get("newClientOrderId"),
+	)
+	if nil != err {
+		writeError(w, http.StatusBadRequest, info.Code, info.Msg)
+		return
+	}
+
+	f.pushOrder(apiKey, order)
+
+	f.mu.Lock()
+	var delay time.Duration
+	if 0 < f.delayTimes {
+		f.delayTimes--
+		delay = f.delay
+	}
+	f.mu.Unlock()
+	time.Sleep(delay)
+
+	writeOrder(w, order, reduceOnly)
+}
+
+// writeOrder 与币安下单、查询订单一致的响应
+func writeOrder(w http.ResponseWriter, order *entity.BinanceOrder, reduceOnly bool) {
+	writeJson(w, map[string]interface{}{
+		"orderId":       order.OrderId,
+		"clientOrderId": order.ClientOrderId,
+		"symbol":        order.Symbol,
+		"status":        order.Status,
+		"executedQty":   order.ExecutedQty,
+		"avgPrice":      order.AvgPrice,
+		"cumQuote":      order.CumQuote,
+		"side":          order.Side,
+		"positionSide":  order.PositionSide,
+		"type":          order.Type,
+		"origType":      order.Type,
+		"reduceOnly":    reduceOnly,
+		"updateTime":    time.Now().UnixMilli(),
+	})
+}
+
+func (f *Server) handleAccount(w http.ResponseWriter, r *http.Request, apiKey string) {
+	writeJson(w, map[string]interface{}{
+		"totalMarginBalance": f.sim.GetBinanceInfo(apiKey, ""),
+		"availableBalance":   f.sim.GetBinanceAvailableBalance(apiKey, ""),
+		"positions":          f.sim.GetBinancePositionInfo(apiKey, ""),
+	})
+}
+
+func (f *Server) handlePositionSide(w http.ResponseWriter, r *http.Request, apiKey string) {
+	if http.MethodPost == r.Method {
+		// 模拟账户统一双向持仓
+		writeError(w, http.StatusBadRequest, -4059, "No need to change position side.")
+		return
+	}
+
+	writeJson(w, map[string]bool{"dualSidePosition": true})
+}
+
+func (f *Server) handleWalletBalance(w http.ResponseWriter, r *http.Request, apiKey string) {
+	writeJson(w, f.sim.GetWalletInfo(apiKey, ""))
+}
+
+func writeJson(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, code int64, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": msg})
+}